@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	authhandler "github.com/ocenb/marketplace/internal/handlers/auth"
+	"github.com/ocenb/marketplace/internal/models"
+	authservice "github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+const commandTimeout = 5 * time.Minute
+
+// runCreateAdmin registers an administrator account. The password is only read
+// from stdin, so it does not end up in the shell history or the process list.
+// With -promote the admin role is granted to an existing account instead, such
+// as one created before the command assigned roles.
+func runCreateAdmin(args []string) int {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	login := flags.String("login", "", "login of the new administrator")
+	email := flags.String("email", "", "email of the new administrator, used for password recovery")
+	promote := flags.Bool("promote", false, "grant the admin role to the existing account with the login")
+	_ = flags.Parse(args)
+
+	if *promote {
+		return promoteAdmin(*login)
+	}
+
+	password, err := readLine("Password: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to read password:", err)
+		return 1
+	}
+
+	request := authhandler.RegisterRequest{Login: *login, Email: *email, Password: password}
+	if err := validator.New().Struct(request); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid credentials:", err)
+		return 2
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	user, err := a.authService.Register(ctx, request.Login, request.Email, request.Password, models.RoleAdmin)
+	if err != nil {
+		if errors.Is(err, authservice.ErrUserAlreadyExists) || errors.Is(err, authservice.ErrEmailAlreadyExists) {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		a.log.Error("Failed to create admin", utils.ErrLog(err))
+		return 1
+	}
+
+	a.log.Info("Admin created", slog.Int64("user_id", user.ID), slog.String("login", user.Login))
+	return 0
+}
+
+func promoteAdmin(login string) int {
+	if login == "" {
+		fmt.Fprintln(os.Stderr, "-promote requires -login")
+		return 2
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	user, err := a.userService.GetByLogin(ctx, login)
+	if err != nil {
+		a.log.Error("Failed to find user", slog.String("login", login), utils.ErrLog(err))
+		return 1
+	}
+
+	// Actor 0 marks the change as made from the command line in the audit log.
+	if _, err := a.authService.ChangeRole(ctx, 0, user.ID, models.RoleAdmin); err != nil {
+		a.log.Error("Failed to promote user to admin", utils.ErrLog(err))
+		return 1
+	}
+
+	a.log.Info("User promoted to admin", slog.Int64("user_id", user.ID), slog.String("login", user.Login))
+	return 0
+}
+
+// runRevokeTokens signs a user out on every device. The user can be given by
+// ID or by login.
+func runRevokeTokens(args []string) int {
+	flags := flag.NewFlagSet("revoke-tokens", flag.ExitOnError)
+	userRef := flags.String("user", "", "ID or login of the user")
+	_ = flags.Parse(args)
+
+	if *userRef == "" {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	userID, err := resolveUserID(ctx, a, *userRef)
+	if err != nil {
+		a.log.Error("Failed to find user", slog.String("user", *userRef), utils.ErrLog(err))
+		return 1
+	}
+
+	if _, err := a.authService.RevokeUserTokens(ctx, userID); err != nil {
+		a.log.Error("Failed to revoke tokens", utils.ErrLog(err))
+		return 1
+	}
+
+	return 0
+}
+
+func runCleanupTokens(args []string) int {
+	flags := flag.NewFlagSet("cleanup-tokens", flag.ExitOnError)
+	_ = flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	if _, err := a.authService.CleanupExpiredTokens(ctx); err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+func runReindexSearch(args []string) int {
+	flags := flag.NewFlagSet("reindex-search", flag.ExitOnError)
+	_ = flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := a.listingService.ReindexSearch(ctx); err != nil {
+		a.log.Error("Failed to rebuild search index", utils.ErrLog(err))
+		return 1
+	}
+
+	a.log.Info("Search index rebuilt", slog.Duration("duration", time.Since(start)))
+	return 0
+}
+
+// runCheckSearches checks the saved searches once, without waiting for the
+// next scheduled check of a running server.
+func runCheckSearches(args []string) int {
+	flags := flag.NewFlagSet("check-searches", flag.ExitOnError)
+	_ = flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	if _, err := a.listingService.CheckSavedSearches(ctx); err != nil {
+		a.log.Error("Failed to check saved searches", utils.ErrLog(err))
+		return 1
+	}
+
+	return 0
+}
+
+func resolveUserID(ctx context.Context, a *app, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+
+	user, err := a.userService.GetByLogin(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
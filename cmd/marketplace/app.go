@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/config"
+	"github.com/ocenb/marketplace/internal/jwtkeys"
+	"github.com/ocenb/marketplace/internal/logger"
+	"github.com/ocenb/marketplace/internal/mailer"
+	"github.com/ocenb/marketplace/internal/metrics"
+	"github.com/ocenb/marketplace/internal/notifier"
+	"github.com/ocenb/marketplace/internal/oidc"
+	auditrepo "github.com/ocenb/marketplace/internal/repos/audit"
+	authrepo "github.com/ocenb/marketplace/internal/repos/auth"
+	categoryrepo "github.com/ocenb/marketplace/internal/repos/category"
+	listingrepo "github.com/ocenb/marketplace/internal/repos/listing"
+	userrepo "github.com/ocenb/marketplace/internal/repos/user"
+	auditservice "github.com/ocenb/marketplace/internal/services/audit"
+	authservice "github.com/ocenb/marketplace/internal/services/auth"
+	categoryservice "github.com/ocenb/marketplace/internal/services/category"
+	listingservice "github.com/ocenb/marketplace/internal/services/listing"
+	userservice "github.com/ocenb/marketplace/internal/services/user"
+	"github.com/ocenb/marketplace/internal/storage/postgres"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// oidcRequestTimeout bounds the requests to the OIDC provider made while
+// handling a login.
+const oidcRequestTimeout = 10 * time.Second
+
+// app holds the dependencies shared by the server and the maintenance
+// subcommands, so that both go through the same services.
+type app struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	postgres *sql.DB
+	metrics  *metrics.Metrics
+
+	revocations     *authservice.RevocationCache
+	authService     authservice.AuthServiceInterface
+	userService     userservice.UserServiceInterface
+	categoryService categoryservice.CategoryServiceInterface
+	listingService  listingservice.ListingServiceInterface
+}
+
+// connect sets up logging and the database connection only. It is all the
+// migrate command needs; newApp builds the services on top of it.
+func connect(cfg *config.Config) (*app, error) {
+	log := logger.New(cfg)
+
+	log.Info("Connecting to database",
+		slog.String("host", cfg.Postgres.Host),
+		slog.String("port", cfg.Postgres.Port),
+		slog.String("database", cfg.Postgres.Name),
+	)
+	db, err := postgres.New(cfg)
+	if err != nil {
+		log.Error("Failed to connect to postgres", utils.ErrLog(err))
+		return nil, err
+	}
+
+	return &app{cfg: cfg, log: log, postgres: db}, nil
+}
+
+func newApp() (*app, error) {
+	a, err := connect(config.MustLoad())
+	if err != nil {
+		return nil, err
+	}
+	cfg, log, db := a.cfg, a.log, a.postgres
+	defer func() {
+		if err != nil {
+			_ = db.Close()
+		}
+	}()
+
+	metricsInstance := metrics.NewMetrics("marketplace")
+
+	fileMailer, err := mailer.NewFileMailer(cfg.Mailer.OutboxDir, cfg.Mailer.From, log)
+	if err != nil {
+		log.Error("Failed to create mailer", utils.ErrLog(err))
+		return nil, err
+	}
+
+	keyRing, err := jwtkeys.Load(cfg.JWT.SigningKeyFile, cfg.JWT.VerificationKeyFiles, cfg.JWT.JWTSecret)
+	if err != nil {
+		log.Error("Failed to load JWT keys", utils.ErrLog(err))
+		return nil, err
+	}
+
+	authRepo := authrepo.New(db, log)
+	auditRepo := auditrepo.New(db)
+	userRepo := userrepo.New(db)
+	listingRepo := listingrepo.New(db, log)
+	categoryRepo := categoryrepo.New(db, log)
+
+	// The revocation cache is only needed, and started, for stateless
+	// token validation.
+	var revocations *authservice.RevocationCache
+	if cfg.JWT.ValidationMode == config.ValidationModeStateless {
+		revocations = authservice.NewRevocationCache(cfg, log, authRepo, metricsInstance)
+	}
+
+	// OIDC login is optional; without an issuer its endpoints respond with 404.
+	var oidcClient *oidc.Client
+	if cfg.OIDC.IssuerURL != "" {
+		oidcClient = oidc.NewClient(oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		}, &http.Client{Timeout: oidcRequestTimeout})
+	}
+
+	// Saved search matches are emailed or, by default, only logged.
+	searchNotifier := notifier.NewLogNotifier(log)
+	if cfg.SavedSearch.Notifier == config.NotifierMail {
+		searchNotifier = notifier.NewMailNotifier(fileMailer, log)
+	}
+
+	userService := userservice.New(userRepo)
+	auditService := auditservice.New(auditRepo)
+	categoryService := categoryservice.New(categoryRepo)
+	listingService := listingservice.New(cfg, log, listingRepo, categoryService, userService, auditService, searchNotifier, metricsInstance)
+	authService := authservice.New(cfg, log, authRepo, userService, listingService, auditService, fileMailer, keyRing, revocations, oidcClient)
+
+	a.metrics = metricsInstance
+	a.revocations = revocations
+	a.authService = authService
+	a.userService = userService
+	a.categoryService = categoryService
+	a.listingService = listingService
+
+	return a, nil
+}
+
+func (a *app) close() {
+	a.log.Info("Closing database connection")
+	if err := a.postgres.Close(); err != nil {
+		a.log.Error("Failed to close postgres connection", utils.ErrLog(err))
+	}
+}
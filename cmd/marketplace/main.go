@@ -2,34 +2,59 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	_ "github.com/ocenb/marketplace/docs"
-	"github.com/ocenb/marketplace/internal/config"
 	authhandler "github.com/ocenb/marketplace/internal/handlers/auth"
+	categoryhandler "github.com/ocenb/marketplace/internal/handlers/category"
 	listinghandler "github.com/ocenb/marketplace/internal/handlers/listing"
+	userhandler "github.com/ocenb/marketplace/internal/handlers/user"
 	"github.com/ocenb/marketplace/internal/http/server"
-	"github.com/ocenb/marketplace/internal/logger"
 	"github.com/ocenb/marketplace/internal/metrics"
 	"github.com/ocenb/marketplace/internal/middlewares"
-	authrepo "github.com/ocenb/marketplace/internal/repos/auth"
-	listingrepo "github.com/ocenb/marketplace/internal/repos/listing"
-	userrepo "github.com/ocenb/marketplace/internal/repos/user"
+	"github.com/ocenb/marketplace/internal/models"
 	authservice "github.com/ocenb/marketplace/internal/services/auth"
 	listingservice "github.com/ocenb/marketplace/internal/services/listing"
-	userservice "github.com/ocenb/marketplace/internal/services/user"
-	"github.com/ocenb/marketplace/internal/storage/postgres"
 	"github.com/ocenb/marketplace/internal/utils"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
+const usage = `Usage: marketplace [command] [flags]
+
+Commands:
+  serve           run the HTTP API (default)
+  migrate         manage database migrations
+  create-admin    create an administrator account
+  revoke-tokens   revoke all tokens of a user
+  cleanup-tokens  delete expired tokens
+  reindex-search  rebuild the listing full-text search index
+  check-searches  notify users about new listings matching saved searches
+  seed            create demo listings for local development
+
+Run "marketplace <command> -h" for command flags.
+`
+
+var commands = map[string]func(args []string) int{
+	"serve":          serve,
+	"migrate":        runMigrate,
+	"create-admin":   runCreateAdmin,
+	"revoke-tokens":  runRevokeTokens,
+	"cleanup-tokens": runCleanupTokens,
+	"reindex-search": runReindexSearch,
+	"check-searches": runCheckSearches,
+	"seed":           runSeed,
+}
+
 // @title Marketplace API
 // @version 1.0
 
@@ -38,53 +63,75 @@ import (
 // @name Authorization
 // @description Type "Bearer" + your JWT token in the input box below."
 func main() {
-	cfg := config.MustLoad()
-	log := logger.New(cfg)
-
-	log.Info("Connecting to database",
-		slog.String("host", cfg.Postgres.Host),
-		slog.String("port", cfg.Postgres.Port),
-		slog.String("database", cfg.Postgres.Name),
-	)
-	postgres, err := postgres.New(cfg)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+
+	os.Exit(command(args))
+}
+
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	migrate := flags.Bool("migrate", false, "apply pending database migrations before starting")
+	_ = flags.Parse(args)
+
+	a, err := newApp()
 	if err != nil {
-		log.Error("Failed to connect to postgres", utils.ErrLog(err))
-		os.Exit(1)
+		return 1
 	}
-	defer func() {
-		log.Info("Closing database connection")
-		err := postgres.Close()
-		if err != nil {
-			log.Error("Failed to close postgres connection", utils.ErrLog(err))
+	defer a.close()
+
+	cfg, log := a.cfg, a.log
+
+	if *migrate {
+		if err := migrateUp(a); err != nil {
+			log.Error("Failed to apply migrations", utils.ErrLog(err))
+			return 1
 		}
-	}()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if a.revocations != nil {
+		if err := a.revocations.Start(ctx); err != nil {
+			log.Error("Failed to start revocation cache", utils.ErrLog(err))
+			return 1
+		}
+		log.Info("Stateless token validation enabled")
+	}
 
 	validator := validator.New()
 
-	metricsInstance := metrics.NewMetrics("marketplace")
 	metricsServer := metrics.NewServer(cfg.Server.MetricsPort, log)
 	metricsServer.Start()
 
-	authRepo := authrepo.New(postgres)
-	userRepo := userrepo.New(postgres)
-	listingRepo := listingrepo.New(postgres, log)
-
-	userService := userservice.New(userRepo)
-	authService := authservice.New(cfg, log, authRepo, userService)
-	listingService := listingservice.New(listingRepo, metricsInstance)
-
-	authHandler := authhandler.New(authService, log, validator)
-	listingHandler := listinghandler.New(listingService, log, validator)
+	authHandler := authhandler.New(a.authService, log, validator)
+	listingHandler := listinghandler.New(a.listingService, log, validator)
+	categoryHandler := categoryhandler.New(a.categoryService, log)
+	userHandler := userhandler.New(a.userService, log, validator)
 
 	httpServer := server.NewHttpServer(log, cfg)
-	httpServer.AddMetricsMiddleware(metricsInstance)
+	httpServer.AddMetricsMiddleware(a.metrics)
 
 	router := httpServer.Router()
 	authRouter := router.Group(func(r chi.Router) {
-		r.Use(middlewares.AuthMiddleware(log, authService))
+		r.Use(middlewares.AuthMiddleware(log, a.authService))
 	})
+	// API keys are accepted by authRouter; account management, moderation and
+	// administration require a user session.
+	sessionRouter := authRouter.With(middlewares.RequireSession(log))
+	moderatorRouter := sessionRouter.With(middlewares.RequireRole(log, models.RoleModerator))
+	adminRouter := sessionRouter.With(middlewares.RequireRole(log, models.RoleAdmin))
 	optionalAuthRouter := router.Group(func(r chi.Router) {
-		r.Use(middlewares.OptionalAuthMiddleware(log, authService))
+		r.Use(middlewares.OptionalAuthMiddleware(log, a.authService))
 	})
 
 	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -97,14 +144,17 @@ func main() {
 	router.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
-	authHandler.RegisterRoutes(router)
-	listingHandler.RegisterRoutes(optionalAuthRouter, authRouter)
+	authHandler.RegisterRoutes(router, sessionRouter, adminRouter)
+	categoryHandler.RegisterRoutes(router)
+	userHandler.RegisterRoutes(router, sessionRouter)
+	listingHandler.RegisterRoutes(optionalAuthRouter, authRouter, moderatorRouter)
 
-	go runTokenCleanup(authService, log)
+	go runTokenCleanup(a.authService, log)
+	go runSavedSearchChecks(ctx, a.listingService, cfg.SavedSearch.CheckInterval, log)
 
 	if err := httpServer.Start(); err != nil {
 		log.Error("Failed to start HTTP server", utils.ErrLog(err))
-		os.Exit(1)
+		return 1
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -112,18 +162,19 @@ func main() {
 	<-stop
 
 	log.Info("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
 
-	if err := httpServer.Stop(ctx); err != nil {
+	if err := httpServer.Stop(shutdownCtx); err != nil {
 		log.Error("HTTP server shutdown error", utils.ErrLog(err))
 	}
 
-	if err := metricsServer.Stop(ctx); err != nil {
+	if err := metricsServer.Stop(shutdownCtx); err != nil {
 		log.Error("Metrics server shutdown error", utils.ErrLog(err))
 	}
 
 	log.Info("Server gracefully stopped")
+	return 0
 }
 
 func runTokenCleanup(authService authservice.AuthServiceInterface, log *slog.Logger) {
@@ -131,8 +182,34 @@ func runTokenCleanup(authService authservice.AuthServiceInterface, log *slog.Log
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
 
-	authService.CleanupExpiredTokens()
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, _ = authService.CleanupExpiredTokens(ctx)
+	}
+
+	cleanup()
 	for range ticker.C {
-		authService.CleanupExpiredTokens()
+		cleanup()
+	}
+}
+
+// runSavedSearchChecks notifies users about new listings matching their saved
+// searches until the context is cancelled. Several servers may run it at once,
+// every new match is announced by one of them.
+func runSavedSearchChecks(ctx context.Context, listingService listingservice.ListingServiceInterface, interval time.Duration, log *slog.Logger) {
+	log.Info("Saved search checks scheduled", slog.Duration("interval", interval))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			_, _ = listingService.CheckSavedSearches(checkCtx)
+			cancel()
+		}
 	}
 }
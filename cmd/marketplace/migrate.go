@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/config"
+	"github.com/ocenb/marketplace/internal/storage/migrator"
+	"github.com/ocenb/marketplace/internal/storage/postgres/migrations"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+const migrateUsage = `Usage: marketplace migrate <command> [args]
+
+Commands:
+  status          show applied and pending migrations
+  up              apply all pending migrations
+  down [steps]    roll back the last N migrations (default 1)
+  force <version> mark migrations up to version as applied without running them
+`
+
+// runMigrate executes the migrate subcommand and returns the process exit code.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// Migrations only need the database, so the command works before the
+	// keys, mailer and providers of the services are configured.
+	a, err := connect(config.MustLoadDatabase())
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	log := a.log
+
+	m, err := migrator.New(a.postgres, log, migrations.FS)
+	if err != nil {
+		log.Error("Failed to load migrations", utils.ErrLog(err))
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch flags.Arg(0) {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Error("Failed to get migrations status", utils.ErrLog(err))
+			return 1
+		}
+		printMigrationStatus(statuses)
+
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			log.Error("Failed to apply migrations", utils.ErrLog(err))
+			return 1
+		}
+		log.Info("Migrations applied", slog.Int("count", applied))
+
+	case "down":
+		steps := 1
+		if flags.NArg() > 1 {
+			steps, err = strconv.Atoi(flags.Arg(1))
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive integer")
+				return 2
+			}
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Error("Failed to roll back migrations", utils.ErrLog(err))
+			return 1
+		}
+		log.Info("Migrations rolled back", slog.Int("count", rolledBack))
+
+	case "force":
+		if flags.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "force requires a version")
+			return 2
+		}
+		version, err := strconv.ParseInt(flags.Arg(1), 10, 64)
+		if err != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, "version must be a non-negative integer")
+			return 2
+		}
+		if err := m.Force(ctx, version); err != nil {
+			log.Error("Failed to force migration version", utils.ErrLog(err))
+			return 1
+		}
+
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	return 0
+}
+
+// migrateUp applies pending migrations during server startup.
+func migrateUp(a *app) error {
+	m, err := migrator.New(a.postgres, a.log, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	a.log.Info("Database schema is up to date", slog.Int("applied", applied))
+
+	return nil
+}
+
+func printMigrationStatus(statuses []migrator.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/ocenb/marketplace/internal/models"
+	authservice "github.com/ocenb/marketplace/internal/services/auth"
+	listingservice "github.com/ocenb/marketplace/internal/services/listing"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// runSeed fills the database with demo listings spread over every leaf
+// category. The category taxonomy itself is created by the migrations.
+func runSeed(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	login := flags.String("login", "demo", "login of the demo seller, created if missing")
+	count := flags.Int("count", 20, "number of listings to create")
+	_ = flags.Parse(args)
+
+	if *count < 1 {
+		fmt.Fprintln(flags.Output(), "count must be positive")
+		return 2
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return 1
+	}
+	defer a.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	sellerID, err := ensureSeller(ctx, a, *login)
+	if err != nil {
+		a.log.Error("Failed to prepare demo seller", utils.ErrLog(err))
+		return 1
+	}
+
+	tree, err := a.categoryService.GetTree(ctx)
+	if err != nil {
+		a.log.Error("Failed to get categories", utils.ErrLog(err))
+		return 1
+	}
+	leaves := leafCategories(tree)
+	if len(leaves) == 0 {
+		a.log.Error("No categories to seed, run migrations first")
+		return 1
+	}
+
+	for i := range *count {
+		category := leaves[i%len(leaves)]
+
+		schema, err := a.categoryService.GetAttributes(ctx, category.ID)
+		if err != nil {
+			a.log.Error("Failed to get category attributes", slog.Int64("category_id", category.ID), utils.ErrLog(err))
+			return 1
+		}
+
+		_, err = a.listingService.Create(ctx, sellerID, listingservice.CreateParams{
+			Title:       fmt.Sprintf("%s #%d", category.Name, i+1),
+			Description: fmt.Sprintf("Демонстрационное объявление в категории «%s».", category.Name),
+			Price:       int64(i+1) * 1000,
+			CategoryID:  category.ID,
+			Attributes:  sampleAttributes(schema),
+			Status:      models.ListingStatusActive,
+		})
+		if err != nil {
+			a.log.Error("Failed to create demo listing", slog.Int64("category_id", category.ID), utils.ErrLog(err))
+			return 1
+		}
+	}
+
+	a.log.Info("Demo data seeded", slog.Int64("seller_id", sellerID), slog.Int("listings", *count))
+	return 0
+}
+
+// ensureSeller returns the demo seller, registering it if it is missing. A new
+// seller gets a random password, printed once, so that seeding a shared
+// database does not leave an account with publicly known credentials.
+func ensureSeller(ctx context.Context, a *app, login string) (int64, error) {
+	password := rand.Text()
+	user, err := a.authService.Register(ctx, login, "", password, models.RoleUser)
+	if err == nil {
+		fmt.Printf("Demo seller %s created with password %s\n", login, password)
+		return user.ID, nil
+	}
+	if !errors.Is(err, authservice.ErrUserAlreadyExists) {
+		return 0, err
+	}
+
+	existing, err := a.userService.GetByLogin(ctx, login)
+	if err != nil {
+		return 0, err
+	}
+
+	return existing.ID, nil
+}
+
+func leafCategories(categories []*models.Category) []*models.Category {
+	var leaves []*models.Category
+	for _, category := range categories {
+		if len(category.Children) == 0 {
+			leaves = append(leaves, category)
+			continue
+		}
+		leaves = append(leaves, leafCategories(category.Children)...)
+	}
+	return leaves
+}
+
+// sampleAttributes builds a value for every attribute of the schema that passes
+// the category validation. Values are passed the way they arrive from JSON.
+func sampleAttributes(schema []models.CategoryAttribute) map[string]any {
+	attributes := make(map[string]any, len(schema))
+	for _, attribute := range schema {
+		switch attribute.Type {
+		case models.AttributeTypeEnum:
+			if len(attribute.Options) > 0 {
+				attributes[attribute.Key] = attribute.Options[0]
+			}
+		case models.AttributeTypeInt:
+			value := int64(0)
+			if attribute.Min != nil {
+				value = *attribute.Min
+			}
+			attributes[attribute.Key] = float64(value)
+		case models.AttributeTypeBool:
+			attributes[attribute.Key] = false
+		case models.AttributeTypeString:
+			attributes[attribute.Key] = "demo"
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,49 @@
+// Command oidc-provider serves the stand-in OpenID Connect provider from
+// internal/oidc/oidctest, for integration tests and local development of the
+// OIDC login flow. It reads the OIDC_* variables the API itself uses.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ocenb/marketplace/internal/oidc/oidctest"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+func main() {
+	addr := flag.String("addr", ":9096", "address to listen on")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		log.Error("OIDC_ISSUER_URL is not set")
+		os.Exit(1)
+	}
+
+	provider, err := oidctest.New(issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"))
+	if err != nil {
+		log.Error("Failed to create provider", utils.ErrLog(err))
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           provider,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	log.Info("Stand-in OIDC provider listening", slog.String("addr", *addr), slog.String("issuer", issuer))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Server failed", utils.ErrLog(err))
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -15,6 +17,12 @@ type Config struct {
 	JWT         JWTConfig
 	Server      ServerConfig
 	Postgres    PostgresConfig
+	Feed        FeedConfig
+	Mailer      MailerConfig
+	Security    SecurityConfig
+	OIDC        OIDCConfig
+	Account     AccountConfig
+	SavedSearch SavedSearchConfig
 }
 
 type LogConfig struct {
@@ -23,11 +31,34 @@ type LogConfig struct {
 }
 
 type JWTConfig struct {
-	JWTSecret     string        `env:"JWT_SECRET" env-required:"true"`
-	TokenLiveTime time.Duration `env:"TOKEN_LIVE_TIME" env-default:"24h"`
-	BCryptCost    int           `env:"BCRYPT_COST" env-default:"12"`
+	JWTSecret             string        `env:"JWT_SECRET"`
+	SigningKeyFile        string        `env:"JWT_SIGNING_KEY_FILE"`
+	VerificationKeyFiles  []string      `env:"JWT_VERIFICATION_KEY_FILES" env-separator:","`
+	Issuer                string        `env:"JWT_ISSUER" env-default:"marketplace"`
+	TokenLiveTime         time.Duration `env:"TOKEN_LIVE_TIME" env-default:"15m"`
+	RefreshTokenLiveTime  time.Duration `env:"REFRESH_TOKEN_LIVE_TIME" env-default:"720h"`
+	BCryptCost            int           `env:"BCRYPT_COST" env-default:"12"`
+	PasswordResetLiveTime time.Duration `env:"PASSWORD_RESET_LIVE_TIME" env-default:"1h"`
+	ChallengeLiveTime     time.Duration `env:"TWO_FACTOR_CHALLENGE_LIVE_TIME" env-default:"5m"`
+	TOTPIssuer            string        `env:"TOTP_ISSUER" env-default:"Marketplace"`
+
+	ValidationMode            string        `env:"JWT_VALIDATION_MODE" env-default:"stateful"`
+	RevocationRefreshInterval time.Duration `env:"JWT_REVOCATION_REFRESH_INTERVAL" env-default:"1m"`
 }
 
+// Token validation modes. Stateful validation looks every access token up in
+// the database; stateless validation trusts the signature and expiry and only
+// checks an in-memory set of revoked sessions.
+const (
+	ValidationModeStateful  = "stateful"
+	ValidationModeStateless = "stateless"
+)
+
+// ServerConfig configures the HTTP server. The X-Forwarded-For and X-Real-IP
+// headers are only trusted on requests from TrustedProxies, CIDR ranges of
+// the reverse proxies in front of the server; otherwise the client address is
+// the address of the connection. Anyone can set these headers, so trusting
+// them from everywhere would let clients evade the per-IP limits.
 type ServerConfig struct {
 	ServerPort        string        `env:"SERVER_PORT" env-default:"8080"`
 	MetricsPort       string        `env:"METRICS_PORT" env-default:"9000"`
@@ -35,8 +66,94 @@ type ServerConfig struct {
 	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" env-default:"10s"`
 	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" env-default:"60s"`
 	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" env-default:"5s"`
+	TrustedProxies    []string      `env:"HTTP_TRUSTED_PROXIES" env-separator:","`
+
+	TrustedProxyPrefixes []netip.Prefix
+}
+
+type FeedConfig struct {
+	CursorSecret string `env:"FEED_CURSOR_SECRET" env-required:"true"`
+}
+
+type MailerConfig struct {
+	OutboxDir        string `env:"MAILER_OUTBOX_DIR" env-default:"./outbox"`
+	From             string `env:"MAILER_FROM" env-default:"Marketplace <noreply@marketplace.local>"`
+	PasswordResetURL string `env:"PASSWORD_RESET_URL" env-default:"http://localhost:8080/reset-password"`
+}
+
+// SecurityConfig limits failed logins, including rejected two-factor codes.
+// After LoginFreeFailures failures of a login, or LoginIPFreeFailures failures
+// from an IP, every further failure blocks the login or IP for an
+// exponentially growing delay starting at LoginBackoffBase; reaching the
+// maximum locks it out for LoginLockoutDuration. An IP is allowed more
+// failures, as it may be shared by many users. Failures are forgotten after
+// LoginFailureWindow without new ones.
+//
+// Password reset requests are limited to PasswordResetIPMaxRequests per
+// client IP and PasswordResetMaxRequests per email within
+// PasswordResetWindow.
+type SecurityConfig struct {
+	LoginMaxFailures     int           `env:"LOGIN_MAX_FAILURES" env-default:"5"`
+	LoginIPMaxFailures   int           `env:"LOGIN_IP_MAX_FAILURES" env-default:"20"`
+	LoginFreeFailures    int           `env:"LOGIN_FREE_FAILURES" env-default:"2"`
+	LoginIPFreeFailures  int           `env:"LOGIN_IP_FREE_FAILURES" env-default:"10"`
+	LoginBackoffBase     time.Duration `env:"LOGIN_BACKOFF_BASE" env-default:"1s"`
+	LoginFailureWindow   time.Duration `env:"LOGIN_FAILURE_WINDOW" env-default:"15m"`
+	LoginLockoutDuration time.Duration `env:"LOGIN_LOCKOUT_DURATION" env-default:"15m"`
+
+	PasswordResetMaxRequests   int           `env:"PASSWORD_RESET_MAX_REQUESTS" env-default:"3"`
+	PasswordResetIPMaxRequests int           `env:"PASSWORD_RESET_IP_MAX_REQUESTS" env-default:"10"`
+	PasswordResetWindow        time.Duration `env:"PASSWORD_RESET_WINDOW" env-default:"1h"`
 }
 
+// OIDCConfig configures login through an external OpenID Connect provider.
+// The login is disabled while IssuerURL is empty. RedirectURL must be
+// registered with the provider and lead to /auth/oidc/callback, directly or
+// through a frontend page that forwards the code and state.
+type OIDCConfig struct {
+	IssuerURL     string        `env:"OIDC_ISSUER_URL"`
+	ClientID      string        `env:"OIDC_CLIENT_ID"`
+	ClientSecret  string        `env:"OIDC_CLIENT_SECRET"`
+	RedirectURL   string        `env:"OIDC_REDIRECT_URL"`
+	Scopes        []string      `env:"OIDC_SCOPES" env-separator:"," env-default:"openid,email,profile"`
+	StateLiveTime time.Duration `env:"OIDC_STATE_LIVE_TIME" env-default:"10m"`
+}
+
+// AccountConfig configures account deletion. DeletionListingPolicy decides
+// whether the listings of a deleted account are deleted with it or kept
+// without a link to the account. Accounts without a password, created through
+// OIDC, confirm the deletion with a session younger than ReauthWindow instead
+// of the password.
+type AccountConfig struct {
+	DeletionListingPolicy string        `env:"ACCOUNT_DELETION_LISTING_POLICY" env-default:"delete"`
+	ReauthWindow          time.Duration `env:"ACCOUNT_REAUTH_WINDOW" env-default:"5m"`
+}
+
+// Listing policies for account deletion.
+const (
+	ListingPolicyDelete    = "delete"
+	ListingPolicyAnonymize = "anonymize"
+)
+
+// SavedSearchConfig configures saved searches. Every CheckInterval the
+// listings that became active since the previous check are matched against
+// the saved searches, and their owners are notified through Notifier. The
+// checks look MatchOverlap further back, so that listings whose transaction
+// committed after a check are not missed.
+type SavedSearchConfig struct {
+	MaxPerUser    int           `env:"SAVED_SEARCH_MAX_PER_USER" env-default:"20"`
+	CheckInterval time.Duration `env:"SAVED_SEARCH_CHECK_INTERVAL" env-default:"5m"`
+	MatchOverlap  time.Duration `env:"SAVED_SEARCH_MATCH_OVERLAP" env-default:"1m"`
+	Notifier      string        `env:"SAVED_SEARCH_NOTIFIER" env-default:"log"`
+}
+
+// Notifiers for saved search matches. The log notifier only writes the
+// notifications to the application log; the mail notifier emails them.
+const (
+	NotifierLog  = "log"
+	NotifierMail = "mail"
+)
+
 type PostgresConfig struct {
 	Host            string        `env:"POSTGRES_HOST" env-required:"true"`
 	Port            string        `env:"POSTGRES_PORT" env-required:"true"`
@@ -63,22 +180,90 @@ func MustLoad() *Config {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	cfg.Postgres.Url = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.Name,
-		cfg.Postgres.SSLMode,
-	)
+	if cfg.JWT.ValidationMode != ValidationModeStateful && cfg.JWT.ValidationMode != ValidationModeStateless {
+		log.Fatalf("Invalid JWT_VALIDATION_MODE %q: must be %q or %q",
+			cfg.JWT.ValidationMode, ValidationModeStateful, ValidationModeStateless)
+	}
+	if cfg.JWT.ValidationMode == ValidationModeStateless && cfg.JWT.RevocationRefreshInterval <= 0 {
+		log.Fatalf("JWT_REVOCATION_REFRESH_INTERVAL must be positive in stateless mode")
+	}
+
+	if cfg.Account.DeletionListingPolicy != ListingPolicyDelete && cfg.Account.DeletionListingPolicy != ListingPolicyAnonymize {
+		log.Fatalf("Invalid ACCOUNT_DELETION_LISTING_POLICY %q: must be %q or %q",
+			cfg.Account.DeletionListingPolicy, ListingPolicyDelete, ListingPolicyAnonymize)
+	}
+
+	if cfg.SavedSearch.Notifier != NotifierLog && cfg.SavedSearch.Notifier != NotifierMail {
+		log.Fatalf("Invalid SAVED_SEARCH_NOTIFIER %q: must be %q or %q",
+			cfg.SavedSearch.Notifier, NotifierLog, NotifierMail)
+	}
+
+	if cfg.SavedSearch.CheckInterval <= 0 {
+		log.Fatalf("SAVED_SEARCH_CHECK_INTERVAL must be positive")
+	}
+	if cfg.SavedSearch.MatchOverlap < 0 {
+		log.Fatalf("SAVED_SEARCH_MATCH_OVERLAP must not be negative")
+	}
+
+	if cfg.OIDC.IssuerURL != "" && (cfg.OIDC.ClientID == "" || cfg.OIDC.RedirectURL == "") {
+		log.Fatalf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
+	for _, proxy := range cfg.Server.TrustedProxies {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(proxy))
+		if err != nil {
+			log.Fatalf("Invalid HTTP_TRUSTED_PROXIES entry %q: %v", proxy, err)
+		}
+		cfg.Server.TrustedProxyPrefixes = append(cfg.Server.TrustedProxyPrefixes, prefix)
+	}
+
+	cfg.Postgres.Url = cfg.Postgres.url()
 
 	return &cfg
 }
 
+// MustLoadDatabase loads only the logging and database settings, for commands
+// such as migrate that do not start the services and should not depend on
+// their secrets, keys and providers.
+func MustLoadDatabase() *Config {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	var dbCfg struct {
+		Environment string `env:"ENVIRONMENT" env-default:"local"`
+		Log         LogConfig
+		Postgres    PostgresConfig
+	}
+
+	err = cleanenv.ReadEnv(&dbCfg)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	cfg := Config{Environment: dbCfg.Environment, Log: dbCfg.Log, Postgres: dbCfg.Postgres}
+	cfg.Postgres.Url = cfg.Postgres.url()
+
+	return &cfg
+}
+
+func (c PostgresConfig) url() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		c.User,
+		c.Password,
+		c.Host,
+		c.Port,
+		c.Name,
+		c.SSLMode,
+	)
+}
+
 type JWTConfigForTest struct {
-	JWTSecret     string
-	BCryptCost    int
-	TokenLiveTime time.Duration
+	JWTSecret            string
+	BCryptCost           int
+	TokenLiveTime        time.Duration
+	RefreshTokenLiveTime time.Duration
 }
 
 type ConfigForTest struct {
@@ -88,9 +273,10 @@ type ConfigForTest struct {
 func NewConfigForTest() *ConfigForTest {
 	return &ConfigForTest{
 		JWT: JWTConfigForTest{
-			JWTSecret:     "test-secret",
-			BCryptCost:    10,
-			TokenLiveTime: 24 * time.Hour,
+			JWTSecret:            "test-secret",
+			BCryptCost:           10,
+			TokenLiveTime:        15 * time.Minute,
+			RefreshTokenLiveTime: 720 * time.Hour,
 		},
 	}
 }
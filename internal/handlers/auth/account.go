@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatZIP  = "zip"
+)
+
+// DeleteAccountRequest confirms the deletion with the password and, if
+// two-factor authentication is enabled, a TOTP or recovery code. Accounts
+// created through OIDC have no password and send neither.
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"max=72"`
+	Code     string `json:"code" validate:"max=32"`
+}
+
+// @Summary Delete the current account
+// @Description Deletes the account of the current user after re-entering the password, and a TOTP or recovery code if two-factor authentication is enabled. Accounts created through OIDC have no password; they confirm the deletion from a session started by a login in the last few minutes (ACCOUNT_REAUTH_WINDOW). All sessions are revoked; listings are deleted or kept without the author, depending on the server configuration.
+// @Param request body DeleteAccountRequest true "Password and two-factor code"
+// @Security BearerAuth
+// @Success 204 "Account deleted successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Re-authentication required"
+// @Failure 429 {object} httputil.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me [delete]
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.DeleteAccount"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	sessionID, ok := utils.GetSessionFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req DeleteAccountRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.authService.DeleteAccount(r.Context(), userID, sessionID, req.Password, req.Code, clientInfo(r))
+	if err != nil {
+		var throttled *auth.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			log.Info("Account deletion throttled", slog.Int64("user_id", userID), slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+			return
+		case errors.Is(err, auth.ErrWrongPassword) || errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			log.Info("Account deletion failed", slog.Int64("user_id", userID), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+			return
+		case errors.Is(err, auth.ErrReauthenticationRequired):
+			log.Info("Account deletion needs re-authentication", slog.Int64("user_id", userID))
+			httputil.WriteJSON(w, httputil.ErrorResponse{Message: err.Error()}, http.StatusForbidden, log)
+			return
+		}
+		log.Error("Internal error during account deletion", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Account deleted successfully", slog.Int64("user_id", userID))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Export the current account's data
+// @Description Downloads the personal data stored about the current user: profile, listings, favorites, saved searches, sessions, API keys and linked external accounts. With format=zip every part is a separate JSON file in a ZIP archive.
+// @Produce json,application/zip
+// @Param format query string false "Archive format" Enums(json, zip) default(json)
+// @Security BearerAuth
+// @Success 200 {object} models.AccountExport "Account data"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/export [get]
+func (h *AuthHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ExportAccount"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+	if format != exportFormatJSON && format != exportFormatZIP {
+		httputil.BadRequestError(w, log, "Invalid 'format' parameter, must be 'json' or 'zip'")
+		return
+	}
+
+	export, err := h.authService.ExportAccount(r.Context(), userID)
+	if err != nil {
+		log.Error("Failed to export account data", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	filename := "marketplace-export-" + strconv.FormatInt(userID, 10) + "-" + export.ExportedAt.Format("20060102")
+
+	if format == exportFormatJSON {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		httputil.WriteJSON(w, export, http.StatusOK, log)
+		return
+	}
+
+	archive, err := exportArchive(export)
+	if err != nil {
+		log.Error("Failed to build export archive", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive); err != nil {
+		log.Error("Failed to write export archive", utils.ErrLog(err))
+	}
+}
+
+// exportArchive puts every part of the export into its own JSON file of a ZIP
+// archive.
+func exportArchive(export *models.AccountExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", struct {
+			ExportedAt       time.Time          `json:"exported_at"`
+			Profile          models.CurrentUser `json:"profile"`
+			TwoFactorEnabled bool               `json:"two_factor_enabled"`
+		}{export.ExportedAt, export.Profile, export.TwoFactorEnabled}},
+		{"listings.json", export.Listings},
+		{"favorites.json", export.Favorites},
+		{"saved_searches.json", export.SavedSearches},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.APIKeys},
+		{"identities.json", export.Identities},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(fw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
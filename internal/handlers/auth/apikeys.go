@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes,omitempty" validate:"omitempty,dive,oneof=listings:read listings:write"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse contains the key itself, which is shown only once.
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+// @Summary Create an API key
+// @Description Creates a personal API key for scripts. Send it in the X-API-Key header or as "Authorization: ApiKey <key>". The key is returned only once. Without scopes the key gets every scope (listings:read, listings:write); without expires_at it does not expire.
+// @Param request body CreateAPIKeyRequest true "API key settings"
+// @Security BearerAuth
+// @Success 201 {object} CreateAPIKeyResponse "API key created successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/api-keys [post]
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.CreateAPIKey"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	apiKey, key, err := h.authService.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) || errors.Is(err, auth.ErrInvalidExpiry) {
+			log.Info("API key creation rejected", utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+			return
+		}
+		log.Error("Failed to create API key", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("API key created successfully", slog.Int64("key_id", apiKey.ID))
+
+	httputil.WriteJSON(w, CreateAPIKeyResponse{Key: key, APIKey: *apiKey}, http.StatusCreated, log)
+}
+
+// @Summary List API keys
+// @Description Returns the current user's API keys that are neither revoked nor expired.
+// @Security BearerAuth
+// @Success 200 {array} models.APIKey "Active API keys"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/api-keys [get]
+func (h *AuthHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.GetAPIKeys"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	keys, err := h.authService.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		log.Error("Failed to get API keys", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, keys, http.StatusOK, log)
+}
+
+// @Summary Revoke an API key
+// @Param id path int true "API key ID"
+// @Security BearerAuth
+// @Success 204 "API key revoked successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "API key not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/api-keys/{id} [delete]
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.RevokeAPIKey"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid API key 'id' parameter")
+		return
+	}
+
+	err = h.authService.RevokeAPIKey(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			log.Info("API key not found", slog.Int64("key_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Failed to revoke API key", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("API key revoked successfully", slog.Int64("key_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
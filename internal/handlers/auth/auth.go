@@ -3,7 +3,10 @@ package auth
 import (
 	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
@@ -16,11 +19,33 @@ import (
 type AuthHandlerInterface interface {
 	Register(w http.ResponseWriter, r *http.Request)
 	Login(w http.ResponseWriter, r *http.Request)
-	RegisterRoutes(r chi.Router)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	GetSessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+	RevokeOtherSessions(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	ForgotPassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+	LoginTwoFactor(w http.ResponseWriter, r *http.Request)
+	OIDCLogin(w http.ResponseWriter, r *http.Request)
+	OIDCCallback(w http.ResponseWriter, r *http.Request)
+	SetupTwoFactor(w http.ResponseWriter, r *http.Request)
+	ConfirmTwoFactor(w http.ResponseWriter, r *http.Request)
+	DisableTwoFactor(w http.ResponseWriter, r *http.Request)
+	JWKS(w http.ResponseWriter, r *http.Request)
+	ChangeRole(w http.ResponseWriter, r *http.Request)
+	CreateAPIKey(w http.ResponseWriter, r *http.Request)
+	GetAPIKeys(w http.ResponseWriter, r *http.Request)
+	RevokeAPIKey(w http.ResponseWriter, r *http.Request)
+	DeleteAccount(w http.ResponseWriter, r *http.Request)
+	ExportAccount(w http.ResponseWriter, r *http.Request)
+	RegisterRoutes(noAuthRouter, sessionRouter, adminRouter chi.Router)
 }
 
 type RegisterRequest struct {
 	Login    string `json:"login" validate:"required,alphanum,min=3,max=50"`
+	Email    string `json:"email,omitempty" validate:"omitempty,email,max=255"`
 	Password string `json:"password" validate:"required,min=8,max=72"`
 }
 
@@ -29,9 +54,26 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required,max=72"`
 }
 
+// LoginResponse carries either the tokens of the new session or, for accounts
+// with two-factor authentication, a challenge to complete at /auth/login/2fa.
 type LoginResponse struct {
-	Token string            `json:"token"`
-	User  models.UserPublic `json:"user"`
+	Token              string            `json:"token,omitempty"`
+	RefreshToken       string            `json:"refresh_token,omitempty"`
+	ExpiresAt          *time.Time        `json:"expires_at,omitempty"`
+	TwoFactorRequired  bool              `json:"two_factor_required,omitempty"`
+	ChallengeToken     string            `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt *time.Time        `json:"challenge_expires_at,omitempty"`
+	User               models.UserPublic `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=128"`
+}
+
+type RefreshResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 type AuthHandler struct {
@@ -65,9 +107,9 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("Registration request validated successfully", slog.String("login", req.Login))
 
-	newUser, err := h.authService.Register(r.Context(), req.Login, req.Password)
+	newUser, err := h.authService.Register(r.Context(), req.Login, req.Email, req.Password, models.RoleUser)
 	if err != nil {
-		if errors.Is(err, auth.ErrUserAlreadyExists) {
+		if errors.Is(err, auth.ErrUserAlreadyExists) || errors.Is(err, auth.ErrEmailAlreadyExists) {
 			log.Info("Registration failed", slog.String("login", req.Login), utils.ErrLog(err))
 			httputil.ConflictError(w, log, err.Error())
 			return
@@ -84,10 +126,12 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary User login
+// @Description Repeated failed attempts block the login and the client IP for a growing delay and finally lock them out; blocked attempts get 429 with a Retry-After header.
 // @Param credentials body LoginRequest true "User login credentials"
-// @Success 200 {object} models.UserPublic "Login successful"
+// @Success 200 {object} LoginResponse "Login successful"
 // @Failure 400 {object} httputil.ErrorResponse "Bad request"
 // @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 429 {object} httputil.ErrorResponse "Too many failed attempts"
 // @Failure 500 {object} httputil.ErrorResponse "Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -100,8 +144,15 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("Login request validated successfully", slog.String("login", req.Login))
 
-	user, token, err := h.authService.Login(r.Context(), req.Login, req.Password)
+	result, err := h.authService.Login(r.Context(), req.Login, req.Password, clientInfo(r))
 	if err != nil {
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			log.Info("Login throttled", slog.String("login", req.Login), slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+			return
+		}
 		if errors.Is(err, auth.ErrInvalidCredentials) || errors.Is(err, auth.ErrUserNotFound) {
 			log.Info("Login failed", slog.String("login", req.Login), utils.ErrLog(err))
 			httputil.UnauthorizedError(w, log, auth.ErrInvalidCredentials.Error())
@@ -112,15 +163,101 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("User logged in successfully", slog.String("login", req.Login))
+	if result.ChallengeToken != "" {
+		log.Info("Two-factor authentication required", slog.String("login", req.Login))
+	} else {
+		log.Info("User logged in successfully", slog.String("login", req.Login))
+	}
+
+	httputil.WriteJSON(w, newLoginResponse(result), http.StatusOK, log)
+}
+
+func newLoginResponse(result *models.LoginResult) LoginResponse {
+	response := LoginResponse{User: *result.User}
+
+	if result.Tokens != nil {
+		response.Token = result.Tokens.AccessToken
+		response.RefreshToken = result.Tokens.RefreshToken
+		response.ExpiresAt = &result.Tokens.ExpiresAt
+	} else {
+		response.TwoFactorRequired = true
+		response.ChallengeToken = result.ChallengeToken
+		response.ChallengeExpiresAt = &result.ChallengeExpiresAt
+	}
+
+	return response
+}
+
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access and refresh token pair. Each refresh token can be used only once; reusing it revokes the whole session.
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} RefreshResponse "Tokens refreshed"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.Refresh"))
+
+	var req RefreshRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	tokens, err := h.authService.Refresh(r.Context(), req.RefreshToken, clientInfo(r))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			log.Info("Refresh failed", utils.ErrLog(err))
+			httputil.UnauthorizedError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during token refresh", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
 
-	httputil.WriteJSON(w, LoginResponse{
-		Token: token,
-		User:  *user,
+	httputil.WriteJSON(w, RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
 	}, http.StatusOK, log)
 }
 
-func (h *AuthHandler) RegisterRoutes(noAuthRouter chi.Router) {
+// @Summary JSON Web Key Set
+// @Description Public keys that verify access tokens, identified by the kid token header. Empty while tokens are signed with a shared secret.
+// @Success 200 {object} jwtkeys.JWKS "Key set"
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.JWKS"))
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httputil.WriteJSON(w, h.authService.JWKS(), http.StatusOK, log)
+}
+
+func (h *AuthHandler) RegisterRoutes(noAuthRouter, sessionRouter, adminRouter chi.Router) {
+	noAuthRouter.Get("/.well-known/jwks.json", h.JWKS)
 	noAuthRouter.Post("/auth/register", h.Register)
 	noAuthRouter.Post("/auth/login", h.Login)
+	noAuthRouter.Post("/auth/login/2fa", h.LoginTwoFactor)
+	noAuthRouter.Get("/auth/oidc/login", h.OIDCLogin)
+	noAuthRouter.Get("/auth/oidc/callback", h.OIDCCallback)
+	noAuthRouter.Post("/auth/refresh", h.Refresh)
+	noAuthRouter.Post("/auth/password/forgot", h.ForgotPassword)
+	noAuthRouter.Post("/auth/password/reset", h.ResetPassword)
+
+	sessionRouter.Post("/auth/logout", h.Logout)
+	sessionRouter.Get("/auth/sessions", h.GetSessions)
+	sessionRouter.Delete("/auth/sessions", h.RevokeOtherSessions)
+	sessionRouter.Delete("/auth/sessions/{id}", h.RevokeSession)
+	sessionRouter.Post("/auth/password", h.ChangePassword)
+	sessionRouter.Post("/auth/2fa/setup", h.SetupTwoFactor)
+	sessionRouter.Post("/auth/2fa/confirm", h.ConfirmTwoFactor)
+	sessionRouter.Post("/auth/2fa/disable", h.DisableTwoFactor)
+	sessionRouter.Post("/auth/api-keys", h.CreateAPIKey)
+	sessionRouter.Get("/auth/api-keys", h.GetAPIKeys)
+	sessionRouter.Delete("/auth/api-keys/{id}", h.RevokeAPIKey)
+	sessionRouter.Delete("/me", h.DeleteAccount)
+	sessionRouter.Get("/me/export", h.ExportAccount)
+
+	adminRouter.Put("/admin/users/{id}/role", h.ChangeRole)
 }
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ocenb/marketplace/internal/oidc"
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+// @Summary Start OIDC login
+// @Description Redirects to the configured OpenID Connect provider (authorization code flow with PKCE). The provider redirects back to OIDC_REDIRECT_URL with a code and state for /auth/oidc/callback.
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} httputil.ErrorResponse "OIDC login is not configured"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Failure 502 {object} httputil.ErrorResponse "Provider unavailable"
+// @Router /auth/oidc/login [get]
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.OIDCLogin"))
+
+	authURL, err := h.authService.StartOIDCLogin(r.Context())
+	if err != nil {
+		if errors.Is(err, auth.ErrOIDCNotConfigured) {
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		if errors.Is(err, oidc.ErrDiscovery) {
+			log.Error("OIDC provider unavailable", utils.ErrLog(err))
+			httputil.BadGatewayError(w, log, "oidc provider unavailable")
+			return
+		}
+		log.Error("Failed to start OIDC login", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// @Summary Complete OIDC login
+// @Description Exchanges the code from the provider for the user's identity and logs in the linked account, creating one on the first login. Responds like /auth/login, including the two-factor challenge. An existing account with the same email is not linked automatically (409).
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the authorization request"
+// @Success 200 {object} LoginResponse "Login successful"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 404 {object} httputil.ErrorResponse "OIDC login is not configured"
+// @Failure 409 {object} httputil.ErrorResponse "Email already used by another account"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Failure 502 {object} httputil.ErrorResponse "Provider unavailable"
+// @Router /auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.OIDCCallback"))
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Info("OIDC provider returned an error", slog.String("error", providerErr))
+		httputil.UnauthorizedError(w, log, "oidc login failed: "+providerErr)
+		return
+	}
+
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" || len(state) > 128 {
+		httputil.BadRequestError(w, log, "Invalid 'code' or 'state' parameter")
+		return
+	}
+
+	result, err := h.authService.CompleteOIDCLogin(r.Context(), code, state, clientInfo(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCNotConfigured):
+			httputil.NotFoundError(w, log, err.Error())
+		case errors.Is(err, auth.ErrInvalidOIDCState) || errors.Is(err, auth.ErrOIDCLoginFailed):
+			log.Info("OIDC login failed", utils.ErrLog(err))
+			httputil.UnauthorizedError(w, log, err.Error())
+		case errors.Is(err, auth.ErrEmailAlreadyExists):
+			log.Info("OIDC login failed", utils.ErrLog(err))
+			httputil.ConflictError(w, log, err.Error())
+		case errors.Is(err, oidc.ErrDiscovery):
+			log.Error("OIDC provider unavailable", utils.ErrLog(err))
+			httputil.BadGatewayError(w, log, "oidc provider unavailable")
+		default:
+			log.Error("Internal error during OIDC login", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	httputil.WriteJSON(w, newLoginResponse(result), http.StatusOK, log)
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// @Summary Change password
+// @Description Changes the password of the current user. All other sessions are revoked. Wrong current passwords count as failed logins; blocked attempts get 429 with a Retry-After header.
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Security BearerAuth
+// @Success 204 "Password changed successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 429 {object} httputil.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/password [post]
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ChangePassword"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+	sessionID, ok := utils.GetSessionFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.authService.ChangePassword(r.Context(), userID, sessionID, req.CurrentPassword, req.NewPassword, clientInfo(r))
+	if err != nil {
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			log.Info("Password change throttled", slog.Int64("user_id", userID), slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+			return
+		}
+		if errors.Is(err, auth.ErrWrongPassword) {
+			log.Info("Password change failed", slog.Int64("user_id", userID), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during password change", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Password changed successfully", slog.Int64("user_id", userID))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Request a password reset
+// @Description Sends a password reset link to the email if it belongs to an account. The response does not reveal whether it does. Requests are limited per client IP and per email; requests over the email limit are accepted but not mailed.
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 202 "Reset requested"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 429 {object} httputil.ErrorResponse "Too many reset requests"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ForgotPassword"))
+
+	var req ForgotPasswordRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.authService.RequestPasswordReset(r.Context(), req.Email, clientInfo(r))
+	if err != nil {
+		var throttled *auth.PasswordResetThrottledError
+		if errors.As(err, &throttled) {
+			log.Info("Password reset request throttled", slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during password reset request", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, nil, http.StatusAccepted, log)
+}
+
+// @Summary Reset password
+// @Description Sets a new password using the token from the reset email. The token can be used once; all sessions of the account are revoked.
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 204 "Password reset successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ResetPassword"))
+
+	var req ResetPasswordRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.authService.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidResetToken) {
+			log.Info("Password reset failed", utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during password reset", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Password reset successfully")
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+// @Summary Change a user's role
+// @Description Available to administrators. Demoting a user signs them out on every device; a promotion applies from the user's next token refresh.
+// @Param id path int true "User ID"
+// @Param request body ChangeRoleRequest true "New role"
+// @Security BearerAuth
+// @Success 200 {object} models.UserPublic "Role changed successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "User not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/role [put]
+func (h *AuthHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ChangeRole"))
+
+	actorID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid user 'id' parameter")
+		return
+	}
+
+	var req ChangeRoleRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	user, err := h.authService.ChangeRole(r.Context(), actorID, id, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			log.Info("User not found", slog.Int64("user_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+		case errors.Is(err, auth.ErrCannotChangeOwnRole), errors.Is(err, auth.ErrInvalidRole):
+			log.Info("Role change rejected", slog.Int64("user_id", id), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+		default:
+			log.Error("Failed to change role", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	log.Info("Role changed successfully", slog.Int64("user_id", id), slog.String("role", user.Role))
+
+	httputil.WriteJSON(w, user, http.StatusOK, log)
+}
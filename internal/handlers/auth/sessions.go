@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+const (
+	maxUserAgentLength = 512
+	maxIPLength        = 64
+)
+
+// @Summary Log out
+// @Description Revokes the session of the current token, including its refresh tokens.
+// @Security BearerAuth
+// @Success 204 "Logged out successfully"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.Logout"))
+
+	sessionID, ok := utils.GetSessionFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	err := h.authService.Logout(r.Context(), sessionID)
+	if err != nil {
+		log.Error("Internal error during logout", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("User logged out successfully", slog.Int64("session_id", sessionID))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary List active sessions
+// @Description Returns the active sessions of the current user. The session of the current token is marked with current=true.
+// @Security BearerAuth
+// @Success 200 {array} models.Session "Active sessions"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/sessions [get]
+func (h *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.GetSessions"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+	sessionID, ok := utils.GetSessionFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	sessions, err := h.authService.GetSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		log.Error("Failed to get sessions", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, sessions, http.StatusOK, log)
+}
+
+// @Summary Revoke a session
+// @Description Signs out the device of one of the current user's sessions.
+// @Param id path int true "Session ID"
+// @Security BearerAuth
+// @Success 204 "Session revoked successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 404 {object} httputil.ErrorResponse "Session not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.RevokeSession"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid session 'id' parameter")
+		return
+	}
+
+	err = h.authService.RevokeSession(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			log.Info("Session not found", slog.Int64("session_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Failed to revoke session", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Session revoked successfully", slog.Int64("session_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Revoke other sessions
+// @Description Signs out every device of the current user except the one making the request.
+// @Security BearerAuth
+// @Success 204 "Sessions revoked successfully"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/sessions [delete]
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.RevokeOtherSessions"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+	sessionID, ok := utils.GetSessionFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	_, err := h.authService.RevokeOtherSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		log.Error("Failed to revoke sessions", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// clientInfo describes the device making the request. RemoteAddr already
+// holds the client address resolved by middlewares.RealIPMiddleware.
+func clientInfo(r *http.Request) models.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	return models.ClientInfo{
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IP:        truncate(ip, maxIPLength),
+	}
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxLen], "")
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ocenb/marketplace/internal/services/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=128"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type ConfirmTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required,max=72"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// @Summary Complete two-factor login
+// @Description Exchanges the challenge token returned by /auth/login and a TOTP or recovery code for the session tokens. Rejected codes count as failed logins and are throttled like wrong passwords.
+// @Param request body LoginTwoFactorRequest true "Challenge token and code"
+// @Success 200 {object} LoginResponse "Login successful"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 429 {object} httputil.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.LoginTwoFactor"))
+
+	var req LoginTwoFactorRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	result, err := h.authService.CompleteLogin(r.Context(), req.ChallengeToken, req.Code, clientInfo(r))
+	if err != nil {
+		var throttled *auth.LoginThrottledError
+		if errors.As(err, &throttled) {
+			log.Info("Two-factor login throttled", slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidChallenge) || errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+			log.Info("Two-factor login failed", utils.ErrLog(err))
+			httputil.UnauthorizedError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during two-factor login", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("User logged in successfully", slog.Int64("user_id", result.User.ID))
+
+	httputil.WriteJSON(w, newLoginResponse(result), http.StatusOK, log)
+}
+
+// @Summary Set up two-factor authentication
+// @Description Generates a TOTP secret and an otpauth URI for an authenticator app. Two-factor authentication is enabled after the first code is confirmed.
+// @Security BearerAuth
+// @Success 200 {object} models.TOTPSetup "TOTP secret"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 409 {object} httputil.ErrorResponse "Already enabled"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.SetupTwoFactor"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	setup, err := h.authService.SetupTOTP(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrTOTPAlreadyEnabled) {
+			httputil.ConflictError(w, log, err.Error())
+			return
+		}
+		log.Error("Failed to set up two-factor authentication", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, setup, http.StatusOK, log)
+}
+
+// @Summary Confirm two-factor authentication
+// @Description Enables two-factor authentication with the first code from the authenticator app. Returns recovery codes that are shown only once.
+// @Param request body ConfirmTwoFactorRequest true "TOTP code"
+// @Security BearerAuth
+// @Success 200 {object} ConfirmTwoFactorResponse "Two-factor authentication enabled"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 409 {object} httputil.ErrorResponse "Not set up or already enabled"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.ConfirmTwoFactor"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req ConfirmTwoFactorRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			httputil.BadRequestError(w, log, err.Error())
+		case errors.Is(err, auth.ErrTOTPNotSetUp), errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+			httputil.ConflictError(w, log, err.Error())
+		default:
+			log.Error("Failed to confirm two-factor authentication", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	log.Info("Two-factor authentication enabled", slog.Int64("user_id", userID))
+
+	httputil.WriteJSON(w, ConfirmTwoFactorResponse{RecoveryCodes: recoveryCodes}, http.StatusOK, log)
+}
+
+// @Summary Disable two-factor authentication
+// @Description Disables two-factor authentication. Requires the password and a TOTP or recovery code. Wrong passwords and codes count as failed logins; blocked attempts get 429 with a Retry-After header.
+// @Param request body DisableTwoFactorRequest true "Password and code"
+// @Security BearerAuth
+// @Success 204 "Two-factor authentication disabled"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 409 {object} httputil.ErrorResponse "Not enabled"
+// @Failure 429 {object} httputil.ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("AuthHandler.DisableTwoFactor"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.authService.DisableTOTP(r.Context(), userID, req.Password, req.Code, clientInfo(r))
+	if err != nil {
+		var throttled *auth.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			log.Info("Disabling two-factor authentication throttled", slog.Int64("user_id", userID), slog.Duration("retry_after", throttled.RetryAfter))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(throttled.RetryAfter.Seconds())))))
+			httputil.TooManyRequestsError(w, log, err.Error())
+		case errors.Is(err, auth.ErrWrongPassword), errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			httputil.BadRequestError(w, log, err.Error())
+		case errors.Is(err, auth.ErrTOTPNotSetUp):
+			httputil.ConflictError(w, log, err.Error())
+		default:
+			log.Error("Failed to disable two-factor authentication", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	log.Info("Two-factor authentication disabled", slog.Int64("user_id", userID))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
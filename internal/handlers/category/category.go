@@ -0,0 +1,93 @@
+package category
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ocenb/marketplace/internal/services/category"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type CategoryHandlerInterface interface {
+	GetTree(w http.ResponseWriter, r *http.Request)
+	GetAttributes(w http.ResponseWriter, r *http.Request)
+	RegisterRoutes(r chi.Router)
+}
+
+type CategoryHandler struct {
+	categoryService category.CategoryServiceInterface
+	log             *slog.Logger
+}
+
+func New(categoryService category.CategoryServiceInterface, log *slog.Logger) CategoryHandlerInterface {
+	return &CategoryHandler{
+		categoryService,
+		log,
+	}
+}
+
+// @Summary Get the category tree
+// @Description Returns root categories with nested subcategories. Listings can only be placed in categories without children.
+// @Success 200 {array} models.Category "Successfully retrieved categories"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /categories [get]
+func (h *CategoryHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("CategoryHandler.GetTree"))
+
+	tree, err := h.categoryService.GetTree(r.Context())
+	if err != nil {
+		log.Error("Internal error during Get categories", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Successfully retrieved categories", slog.Int("roots", len(tree)))
+
+	httputil.WriteJSON(w, tree, http.StatusOK, log)
+}
+
+// @Summary Get the attribute schema of a category
+// @Description Returns attributes that listings in the category may or must specify, including attributes inherited from parent categories.
+// @Param id path int true "Category ID"
+// @Success 200 {array} models.CategoryAttribute "Successfully retrieved category attributes"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 404 {object} httputil.ErrorResponse "Category not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /categories/{id}/attributes [get]
+func (h *CategoryHandler) GetAttributes(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("CategoryHandler.GetAttributes"))
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid category 'id' parameter")
+		return
+	}
+
+	attributes, err := h.categoryService.GetAttributes(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, category.ErrCategoryNotFound) {
+			log.Info("Category not found", slog.Int64("category_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during Get category attributes", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Successfully retrieved category attributes",
+		slog.Int64("category_id", id),
+		slog.Int("count", len(attributes)),
+	)
+
+	httputil.WriteJSON(w, attributes, http.StatusOK, log)
+}
+
+func (h *CategoryHandler) RegisterRoutes(noAuthRouter chi.Router) {
+	noAuthRouter.Get("/categories", h.GetTree)
+	noAuthRouter.Get("/categories/{id}/attributes", h.GetAttributes)
+}
@@ -0,0 +1,136 @@
+package listing
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ocenb/marketplace/internal/services/listing"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+// @Summary Add a listing to favorites
+// @Description Saves a listing of another user to the current user's favorites. Adding a listing that is already a favorite has no effect.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 204 "Listing added to favorites"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/favorite [post]
+func (h *ListingHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.AddFavorite"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	err := h.listingService.AddFavorite(r.Context(), userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, listing.ErrListingNotFound):
+			log.Info("Listing not found", slog.Int64("listing_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+		case errors.Is(err, listing.ErrFavoriteOwnListing):
+			log.Info("Add favorite rejected", slog.Int64("listing_id", id), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+		default:
+			log.Error("Internal error during Add favorite", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	log.Info("Listing added to favorites", slog.Int64("listing_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Remove a listing from favorites
+// @Description Removing a listing that is not a favorite has no effect.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 204 "Listing removed from favorites"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/favorite [delete]
+func (h *ListingHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.RemoveFavorite"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	err := h.listingService.RemoveFavorite(r.Context(), userID, id)
+	if err != nil {
+		log.Error("Internal error during Remove favorite", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Listing removed from favorites", slog.Int64("listing_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Get my favorites
+// @Description Returns the current user's favorite listings that are still publicly visible, with the same filtering, sorting and pagination as /listing/feed.
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param limit query int false "Number of items per page" default(10) minimum(1) maximum(100)
+// @Param q query string false "Full-text search query over title and description" maxlength(200)
+// @Param sortBy query string false "Sort by field (createdAt, price or relevance), relevance requires q and is the default when q is set" Enums(createdAt, price, relevance) default(createdAt)
+// @Param sortOrder query string false "Sort order (asc or desc), relevance is always sorted most relevant first" Enums(asc, desc) default(desc)
+// @Param minPrice query integer false "Minimum price in kopecks" minimum(0)
+// @Param maxPrice query integer false "Maximum price in kopecks" minimum(0)
+// @Param category query integer false "Category ID, listings from its subcategories are included" minimum(1)
+// @Param attr.{key} query string false "Attribute filter: attr.{key}=value for equality, attr.{key}_gte / attr.{key}_lte for numeric ranges"
+// @Param status query string false "Only listings in this status" Enums(active, reserved, sold)
+// @Param cursor query string false "Opaque cursor from next_cursor of a previous response"
+// @Security BearerAuth
+// @Success 200 {object} models.ListingsFeed "Successfully retrieved favorites"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/favorites [get]
+func (h *ListingHandler) GetFavorites(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.GetFavorites"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	params, ok := parseFeedParams(w, r, log)
+	if !ok {
+		return
+	}
+
+	feed, err := h.listingService.GetFavorites(r.Context(), userID, params)
+	if err != nil {
+		h.handleFeedError(w, log, err)
+		return
+	}
+
+	httputil.WriteJSON(w, feed, http.StatusOK, log)
+}
@@ -1,13 +1,21 @@
 package listing
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/ocenb/marketplace/internal/middlewares"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/category"
 	"github.com/ocenb/marketplace/internal/services/listing"
 	"github.com/ocenb/marketplace/internal/utils"
 	"github.com/ocenb/marketplace/internal/utils/httputil"
@@ -16,23 +24,43 @@ import (
 type ListingHandlerInterface interface {
 	Create(w http.ResponseWriter, r *http.Request)
 	GetFeed(w http.ResponseWriter, r *http.Request)
-	RegisterRoutes(optionalAuthRouter, authRouter chi.Router)
+	GetSellerFeed(w http.ResponseWriter, r *http.Request)
+	GetMyListings(w http.ResponseWriter, r *http.Request)
+	GetFavorites(w http.ResponseWriter, r *http.Request)
+	AddFavorite(w http.ResponseWriter, r *http.Request)
+	RemoveFavorite(w http.ResponseWriter, r *http.Request)
+	CreateSavedSearch(w http.ResponseWriter, r *http.Request)
+	GetSavedSearches(w http.ResponseWriter, r *http.Request)
+	DeleteSavedSearch(w http.ResponseWriter, r *http.Request)
+	GetByID(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	Publish(w http.ResponseWriter, r *http.Request)
+	Reserve(w http.ResponseWriter, r *http.Request)
+	MarkSold(w http.ResponseWriter, r *http.Request)
+	Archive(w http.ResponseWriter, r *http.Request)
+	ModerateArchive(w http.ResponseWriter, r *http.Request)
+	ModerateDelete(w http.ResponseWriter, r *http.Request)
+	RegisterRoutes(optionalAuthRouter, authRouter, moderatorRouter chi.Router)
 }
 
 type CreateListingRequest struct {
-	Title       string `json:"title" validate:"required,min=5,max=200"`
-	Description string `json:"description" validate:"max=1000"`
-	ImageURL    string `json:"image_url" validate:"required,url"`
-	Price       int64  `json:"price" validate:"required,min=0,max=100000000000"`
+	Title       string         `json:"title" validate:"required,min=5,max=200"`
+	Description string         `json:"description" validate:"max=1000"`
+	ImageURL    string         `json:"image_url" validate:"required,url"`
+	Price       int64          `json:"price" validate:"required,min=0,max=100000000000"`
+	CategoryID  int64          `json:"category_id" validate:"required,min=1"`
+	Attributes  map[string]any `json:"attributes,omitempty"`
+	Status      string         `json:"status,omitempty" validate:"omitempty,oneof=draft active"`
 }
 
-type GetFeedParams struct {
-	Page      int    `validate:"omitempty,min=1"`
-	Limit     int    `validate:"omitempty,min=1,max=100"`
-	SortBy    string `validate:"omitempty,oneof=createdAt price"`
-	SortOrder string `validate:"omitempty,oneof=asc desc"`
-	MinPrice  int64  `validate:"omitempty,min=0"`
-	MaxPrice  int64  `validate:"omitempty,min=0,gtefield=MinPrice"`
+type UpdateListingRequest struct {
+	Title       *string        `json:"title" validate:"omitnil,min=5,max=200"`
+	Description *string        `json:"description" validate:"omitnil,max=1000"`
+	ImageURL    *string        `json:"image_url" validate:"omitnil,url"`
+	Price       *int64         `json:"price" validate:"omitnil,min=0,max=100000000000"`
+	CategoryID  *int64         `json:"category_id" validate:"omitnil,min=1"`
+	Attributes  map[string]any `json:"attributes"`
 }
 
 type ListingHandler struct {
@@ -50,6 +78,7 @@ func New(listingService listing.ListingServiceInterface, log *slog.Logger, valid
 }
 
 // @Summary Create a new listing
+// @Description New listings are published immediately unless status is set to "draft".
 // @Param listing body CreateListingRequest true "Listing creation data"
 // @Security BearerAuth
 // @Success 201 {object} models.Listing "Listing created successfully"
@@ -81,8 +110,21 @@ func (h *ListingHandler) Create(w http.ResponseWriter, r *http.Request) {
 		slog.String("title", req.Title),
 	)
 
-	newListing, err := h.listingService.Create(r.Context(), userID, req.Title, req.Description, req.ImageURL, req.Price)
+	newListing, err := h.listingService.Create(r.Context(), userID, listing.CreateParams{
+		Title:       req.Title,
+		Description: req.Description,
+		ImageURL:    req.ImageURL,
+		Price:       req.Price,
+		CategoryID:  req.CategoryID,
+		Attributes:  req.Attributes,
+		Status:      req.Status,
+	})
 	if err != nil {
+		if isCategoryValidationError(err) {
+			log.Info("Create listing failed", slog.Int64("category_id", req.CategoryID), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, fmt.Sprintf("Validation failed: %s", err.Error()))
+			return
+		}
 		log.Error("Internal error during Create listing", utils.ErrLog(err))
 		httputil.InternalError(w, log)
 		return
@@ -91,6 +133,7 @@ func (h *ListingHandler) Create(w http.ResponseWriter, r *http.Request) {
 	log.Info("Listing created successfully",
 		slog.Int64("listing_id", newListing.ID),
 		slog.String("title", newListing.Title),
+		slog.String("status", newListing.Status),
 		slog.Time("created_at", newListing.CreatedAt),
 	)
 
@@ -98,15 +141,22 @@ func (h *ListingHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get a feed of listings
+// @Description Returns active listings. Authenticated users may pass another status to see their own listings in that status.
 // @Param page query int false "Page number" default(1) minimum(1)
 // @Param limit query int false "Number of items per page" default(10) minimum(1) maximum(100)
-// @Param sortBy query string false "Sort by field (createdAt or price)" Enums(createdAt, price) default(createdAt)
-// @Param sortOrder query string false "Sort order (asc or desc)" Enums(asc, desc) default(desc)
+// @Param q query string false "Full-text search query over title and description" maxlength(200)
+// @Param sortBy query string false "Sort by field (createdAt, price or relevance), relevance requires q and is the default when q is set" Enums(createdAt, price, relevance) default(createdAt)
+// @Param sortOrder query string false "Sort order (asc or desc), relevance is always sorted most relevant first" Enums(asc, desc) default(desc)
 // @Param minPrice query integer false "Minimum price in kopecks" minimum(0)
 // @Param maxPrice query integer false "Maximum price in kopecks" minimum(0)
+// @Param category query integer false "Category ID, listings from its subcategories are included" minimum(1)
+// @Param attr.{key} query string false "Attribute filter: attr.{key}=value for equality, attr.{key}_gte / attr.{key}_lte for numeric ranges"
+// @Param status query string false "Listing status, non-active statuses are limited to the caller's own listings" Enums(draft, active, reserved, sold, archived) default(active)
+// @Param cursor query string false "Opaque cursor from next_cursor of a previous response, switches the feed to keyset pagination and overrides page, sortBy and sortOrder"
 // @Security BearerAuth
 // @Success 200 {object} models.ListingsFeed "Successfully retrieved listing feed"
 // @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
 // @Failure 500 {object} httputil.ErrorResponse "Internal server error"
 // @Router /listing/feed [get]
 func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +164,438 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 
 	userID, _ := utils.GetInfoFromContext(r.Context(), log)
 
-	params := GetFeedParams{
+	params, ok := parseFeedParams(w, r, log)
+	if !ok {
+		return
+	}
+
+	feed, err := h.listingService.GetFeed(r.Context(), userID, params)
+	if err != nil {
+		h.handleFeedError(w, log, err)
+		return
+	}
+
+	log.Info("Successfully retrieved listing feed", slog.Int("total", feed.Total))
+
+	httputil.WriteJSON(w, feed, http.StatusOK, log)
+}
+
+// @Summary Get the listings of a seller
+// @Description Returns the feed restricted to one seller, with the same filtering, sorting and pagination as /listing/feed. Sellers may pass another status to see their own listings in that status.
+// @Param id path int true "Seller ID"
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param limit query int false "Number of items per page" default(10) minimum(1) maximum(100)
+// @Param q query string false "Full-text search query over title and description" maxlength(200)
+// @Param sortBy query string false "Sort by field (createdAt, price or relevance), relevance requires q and is the default when q is set" Enums(createdAt, price, relevance) default(createdAt)
+// @Param sortOrder query string false "Sort order (asc or desc), relevance is always sorted most relevant first" Enums(asc, desc) default(desc)
+// @Param minPrice query integer false "Minimum price in kopecks" minimum(0)
+// @Param maxPrice query integer false "Maximum price in kopecks" minimum(0)
+// @Param category query integer false "Category ID, listings from its subcategories are included" minimum(1)
+// @Param attr.{key} query string false "Attribute filter: attr.{key}=value for equality, attr.{key}_gte / attr.{key}_lte for numeric ranges"
+// @Param status query string false "Listing status, non-active statuses are available to the seller only" Enums(draft, active, reserved, sold, archived) default(active)
+// @Param cursor query string false "Opaque cursor from next_cursor of a previous response"
+// @Security BearerAuth
+// @Success 200 {object} models.ListingsFeed "Successfully retrieved seller listings"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Seller not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /users/{id}/listings [get]
+func (h *ListingHandler) GetSellerFeed(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.GetSellerFeed"))
+
+	userID, _ := utils.GetInfoFromContext(r.Context(), log)
+
+	sellerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || sellerID < 1 {
+		httputil.BadRequestError(w, log, "Invalid user 'id' parameter")
+		return
+	}
+
+	params, ok := parseFeedParams(w, r, log)
+	if !ok {
+		return
+	}
+
+	feed, err := h.listingService.GetSellerFeed(r.Context(), userID, sellerID, params)
+	if err != nil {
+		if errors.Is(err, listing.ErrSellerNotFound) {
+			log.Info("Seller not found", slog.Int64("seller_id", sellerID))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		h.handleFeedError(w, log, err)
+		return
+	}
+
+	log.Info("Successfully retrieved seller listings", slog.Int64("seller_id", sellerID), slog.Int("total", feed.Total))
+
+	httputil.WriteJSON(w, feed, http.StatusOK, log)
+}
+
+// @Summary Get my listings
+// @Description Returns the caller's listings in every status (or in the status passed) with the same filtering, sorting and pagination as /listing/feed, plus the number of listings in each status.
+// @Param page query int false "Page number" default(1) minimum(1)
+// @Param limit query int false "Number of items per page" default(10) minimum(1) maximum(100)
+// @Param q query string false "Full-text search query over title and description" maxlength(200)
+// @Param sortBy query string false "Sort by field (createdAt, price or relevance), relevance requires q and is the default when q is set" Enums(createdAt, price, relevance) default(createdAt)
+// @Param sortOrder query string false "Sort order (asc or desc), relevance is always sorted most relevant first" Enums(asc, desc) default(desc)
+// @Param minPrice query integer false "Minimum price in kopecks" minimum(0)
+// @Param maxPrice query integer false "Maximum price in kopecks" minimum(0)
+// @Param category query integer false "Category ID, listings from its subcategories are included" minimum(1)
+// @Param attr.{key} query string false "Attribute filter: attr.{key}=value for equality, attr.{key}_gte / attr.{key}_lte for numeric ranges"
+// @Param status query string false "Only listings in this status" Enums(draft, active, reserved, sold, archived)
+// @Param cursor query string false "Opaque cursor from next_cursor of a previous response"
+// @Security BearerAuth
+// @Success 200 {object} models.ListingsDashboard "Successfully retrieved listings"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/listings [get]
+func (h *ListingHandler) GetMyListings(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.GetMyListings"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	params, ok := parseFeedParams(w, r, log)
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.listingService.GetMyListings(r.Context(), userID, params)
+	if err != nil {
+		h.handleFeedError(w, log, err)
+		return
+	}
+
+	httputil.WriteJSON(w, dashboard, http.StatusOK, log)
+}
+
+func (h *ListingHandler) handleFeedError(w http.ResponseWriter, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, listing.ErrStatusFilterRequiresAuth):
+		log.Info("Get listing feed failed", utils.ErrLog(err))
+		httputil.UnauthorizedError(w, log, err.Error())
+	case errors.Is(err, listing.ErrStatusFilterForbidden):
+		log.Info("Get listing feed failed", utils.ErrLog(err))
+		httputil.ForbiddenError(w, log)
+	case errors.Is(err, listing.ErrInvalidCursor):
+		log.Info("Get listing feed failed", utils.ErrLog(err))
+		httputil.BadRequestError(w, log, "Invalid 'cursor' parameter")
+	case errors.Is(err, listing.ErrRelevanceRequiresQuery), errors.Is(err, listing.ErrStatusNotPublic),
+		errors.Is(err, listing.ErrCursorFiltersMismatch):
+		log.Info("Get listing feed failed", utils.ErrLog(err))
+		httputil.BadRequestError(w, log, err.Error())
+	default:
+		log.Error("Internal error during Get listing feed", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+	}
+}
+
+// @Summary Get a listing by ID
+// @Description Drafts and archived listings are visible only to their owner.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Successfully retrieved listing"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id} [get]
+func (h *ListingHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.GetByID"))
+
+	userID, _ := utils.GetInfoFromContext(r.Context(), log)
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	foundListing, err := h.listingService.GetByID(r.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, listing.ErrListingNotFound) {
+			log.Info("Listing not found", slog.Int64("listing_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during Get listing", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Successfully retrieved listing", slog.Int64("listing_id", foundListing.ID))
+
+	httputil.WriteJSON(w, foundListing, http.StatusOK, log)
+}
+
+// @Summary Update a listing
+// @Description Partially updates a listing. Only the listing owner can update it.
+// @Param id path int true "Listing ID"
+// @Param listing body UpdateListingRequest true "Fields to update"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing updated successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id} [patch]
+func (h *ListingHandler) Update(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.Update"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	var req UpdateListingRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+	if req.Title == nil && req.Description == nil && req.ImageURL == nil && req.Price == nil &&
+		req.CategoryID == nil && req.Attributes == nil {
+		httputil.BadRequestError(w, log, "No fields to update")
+		return
+	}
+	if req.ImageURL != nil {
+		err := httputil.ValidateImage(log, *req.ImageURL)
+		if err != nil {
+			log.Error("Failed to validate image", utils.ErrLog(err))
+			httputil.BadRequestError(w, log, fmt.Sprintf("Validation failed: %s", err.Error()))
+			return
+		}
+	}
+
+	log.Debug("Update listing request validated successfully", slog.Int64("listing_id", id))
+
+	updatedListing, err := h.listingService.Update(r.Context(), userID, id, listing.UpdateParams{
+		Title:       req.Title,
+		Description: req.Description,
+		ImageURL:    req.ImageURL,
+		Price:       req.Price,
+		CategoryID:  req.CategoryID,
+		Attributes:  req.Attributes,
+	})
+	if err != nil {
+		if isCategoryValidationError(err) {
+			log.Info("Update listing failed", slog.Int64("listing_id", id), utils.ErrLog(err))
+			httputil.BadRequestError(w, log, fmt.Sprintf("Validation failed: %s", err.Error()))
+			return
+		}
+		h.handleOwnershipError(w, log, err, id, "Update listing")
+		return
+	}
+
+	log.Info("Listing updated successfully",
+		slog.Int64("listing_id", updatedListing.ID),
+		slog.Time("updated_at", updatedListing.UpdatedAt),
+	)
+
+	httputil.WriteJSON(w, updatedListing, http.StatusOK, log)
+}
+
+// @Summary Delete a listing
+// @Description Deletes a listing. Only the listing owner can delete it.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 204 "Listing deleted successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id} [delete]
+func (h *ListingHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.Delete"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	err := h.listingService.Delete(r.Context(), userID, id)
+	if err != nil {
+		h.handleOwnershipError(w, log, err, id, "Delete listing")
+		return
+	}
+
+	log.Info("Listing deleted successfully", slog.Int64("listing_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
+
+// @Summary Publish a listing
+// @Description Moves a draft, reserved or archived listing to the active status.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing published successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 409 {object} httputil.ErrorResponse "Transition not allowed"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/publish [post]
+func (h *ListingHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "ListingHandler.Publish", models.ListingStatusActive)
+}
+
+// @Summary Reserve a listing
+// @Description Moves an active listing to the reserved status.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing reserved successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 409 {object} httputil.ErrorResponse "Transition not allowed"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/reserve [post]
+func (h *ListingHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "ListingHandler.Reserve", models.ListingStatusReserved)
+}
+
+// @Summary Mark a listing as sold
+// @Description Moves an active or reserved listing to the sold status.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing marked as sold successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 409 {object} httputil.ErrorResponse "Transition not allowed"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/sell [post]
+func (h *ListingHandler) MarkSold(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "ListingHandler.MarkSold", models.ListingStatusSold)
+}
+
+// @Summary Archive a listing
+// @Description Moves a listing in any status except archived to the archived status.
+// @Param id path int true "Listing ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing archived successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 409 {object} httputil.ErrorResponse "Transition not allowed"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /listing/{id}/archive [post]
+func (h *ListingHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "ListingHandler.Archive", models.ListingStatusArchived)
+}
+
+// RegisterRoutes registers the listing routes. Requests made with an API key
+// need the listings:read or listings:write scope.
+func (h *ListingHandler) RegisterRoutes(optionalAuthRouter, authRouter, moderatorRouter chi.Router) {
+	writeRouter := authRouter.With(middlewares.RequireScope(h.log, models.ScopeListingsWrite))
+	readRouter := optionalAuthRouter.With(middlewares.RequireScope(h.log, models.ScopeListingsRead))
+	ownRouter := authRouter.With(middlewares.RequireScope(h.log, models.ScopeListingsRead))
+
+	writeRouter.Post("/listing", h.Create)
+	writeRouter.Patch("/listing/{id}", h.Update)
+	writeRouter.Delete("/listing/{id}", h.Delete)
+	writeRouter.Post("/listing/{id}/publish", h.Publish)
+	writeRouter.Post("/listing/{id}/reserve", h.Reserve)
+	writeRouter.Post("/listing/{id}/sell", h.MarkSold)
+	writeRouter.Post("/listing/{id}/archive", h.Archive)
+	writeRouter.Post("/listing/{id}/favorite", h.AddFavorite)
+	writeRouter.Delete("/listing/{id}/favorite", h.RemoveFavorite)
+	writeRouter.Post("/me/saved-searches", h.CreateSavedSearch)
+	writeRouter.Delete("/me/saved-searches/{id}", h.DeleteSavedSearch)
+	readRouter.Get("/listing/feed", h.GetFeed)
+	readRouter.Get("/listing/{id}", h.GetByID)
+	readRouter.Get("/users/{id}/listings", h.GetSellerFeed)
+	ownRouter.Get("/me/listings", h.GetMyListings)
+	ownRouter.Get("/me/favorites", h.GetFavorites)
+	ownRouter.Get("/me/saved-searches", h.GetSavedSearches)
+	moderatorRouter.Post("/moderation/listing/{id}/archive", h.ModerateArchive)
+	moderatorRouter.Delete("/moderation/listing/{id}", h.ModerateDelete)
+}
+
+func (h *ListingHandler) changeStatus(w http.ResponseWriter, r *http.Request, op, status string) {
+	log := h.log.With(utils.OpLog(op))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	updatedListing, err := h.listingService.ChangeStatus(r.Context(), userID, id, status)
+	if err != nil {
+		if errors.Is(err, listing.ErrInvalidStatusTransition) {
+			log.Info("Listing status change rejected",
+				slog.Int64("listing_id", id),
+				slog.String("status", status),
+			)
+			httputil.ConflictError(w, log, err.Error())
+			return
+		}
+		h.handleOwnershipError(w, log, err, id, "Change listing status")
+		return
+	}
+
+	log.Info("Listing status changed successfully",
+		slog.Int64("listing_id", updatedListing.ID),
+		slog.String("status", updatedListing.Status),
+	)
+
+	httputil.WriteJSON(w, updatedListing, http.StatusOK, log)
+}
+
+func (h *ListingHandler) handleOwnershipError(w http.ResponseWriter, log *slog.Logger, err error, id int64, action string) {
+	switch {
+	case errors.Is(err, listing.ErrListingNotFound):
+		log.Info(action+" failed: listing not found", slog.Int64("listing_id", id))
+		httputil.NotFoundError(w, log, err.Error())
+	case errors.Is(err, listing.ErrNotListingOwner):
+		log.Info(action+" failed: caller is not the owner", slog.Int64("listing_id", id))
+		httputil.ForbiddenError(w, log)
+	default:
+		log.Error("Internal error during "+action, utils.ErrLog(err))
+		httputil.InternalError(w, log)
+	}
+}
+
+func parseListingID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid listing 'id' parameter")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func parseFeedParams(w http.ResponseWriter, r *http.Request, log *slog.Logger) (models.FeedParams, bool) {
+	params := models.FeedParams{
 		Page:      1,
 		Limit:     10,
 		SortBy:    "createdAt",
@@ -126,7 +607,7 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 			params.Page = val
 		} else {
 			httputil.BadRequestError(w, log, "Invalid 'page' parameter")
-			return
+			return params, false
 		}
 	}
 
@@ -135,16 +616,25 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 			params.Limit = val
 		} else {
 			httputil.BadRequestError(w, log, "Invalid 'limit' parameter (must be 1-100)")
-			return
+			return params, false
 		}
 	}
 
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		if utf8.RuneCountInString(q) > 200 {
+			httputil.BadRequestError(w, log, "Invalid 'q' parameter (must be at most 200 characters)")
+			return params, false
+		}
+		params.Query = q
+		params.SortBy = "relevance"
+	}
+
 	if sb := r.URL.Query().Get("sortBy"); sb != "" {
-		if sb == "createdAt" || sb == "price" {
+		if sb == "createdAt" || sb == "price" || (sb == "relevance" && params.Query != "") {
 			params.SortBy = sb
 		} else {
-			httputil.BadRequestError(w, log, "Invalid 'sortBy' parameter (must be 'createdAt' or 'price')")
-			return
+			httputil.BadRequestError(w, log, "Invalid 'sortBy' parameter (must be 'createdAt', 'price' or 'relevance' with 'q')")
+			return params, false
 		}
 	}
 
@@ -153,7 +643,7 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 			params.SortOrder = so
 		} else {
 			httputil.BadRequestError(w, log, "Invalid 'sortOrder' parameter (must be 'asc' or 'desc')")
-			return
+			return params, false
 		}
 	}
 
@@ -162,7 +652,7 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 			params.MinPrice = val
 		} else {
 			httputil.BadRequestError(w, log, "Invalid 'minPrice' parameter")
-			return
+			return params, false
 		}
 	}
 
@@ -171,33 +661,94 @@ func (h *ListingHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
 			params.MaxPrice = val
 		} else {
 			httputil.BadRequestError(w, log, "Invalid 'maxPrice' parameter")
-			return
+			return params, false
 		}
 	}
 
 	if params.MaxPrice > 0 && params.MinPrice > 0 && params.MaxPrice < params.MinPrice {
 		httputil.BadRequestError(w, log, "'maxPrice' cannot be less than 'minPrice'")
-		return
+		return params, false
 	}
 
-	feed, err := h.listingService.GetFeed(r.Context(), userID, params.Page,
-		params.Limit,
-		params.SortBy,
-		params.SortOrder,
-		params.MinPrice,
-		params.MaxPrice)
-	if err != nil {
-		log.Error("Internal error during Get listing feed", utils.ErrLog(err))
-		httputil.InternalError(w, log)
-		return
+	if st := r.URL.Query().Get("status"); st != "" {
+		switch st {
+		case models.ListingStatusDraft, models.ListingStatusActive, models.ListingStatusReserved,
+			models.ListingStatusSold, models.ListingStatusArchived:
+			params.Status = st
+		default:
+			httputil.BadRequestError(w, log, "Invalid 'status' parameter (must be 'draft', 'active', 'reserved', 'sold' or 'archived')")
+			return params, false
+		}
 	}
 
-	log.Info("Successfully retrieved listing feed", slog.Int("total", feed.Total))
+	if c := r.URL.Query().Get("category"); c != "" {
+		if val, err := strconv.ParseInt(c, 10, 64); err == nil && val >= 1 {
+			params.CategoryID = val
+		} else {
+			httputil.BadRequestError(w, log, "Invalid 'category' parameter")
+			return params, false
+		}
+	}
 
-	httputil.WriteJSON(w, feed, http.StatusOK, log)
+	for name, values := range r.URL.Query() {
+		key, isAttribute := strings.CutPrefix(name, "attr.")
+		if !isAttribute {
+			continue
+		}
+
+		filter, err := parseAttributeFilter(key, values[len(values)-1])
+		if err != nil {
+			httputil.BadRequestError(w, log, fmt.Sprintf("Invalid '%s' parameter: %s", name, err.Error()))
+			return params, false
+		}
+		params.Attributes = append(params.Attributes, filter)
+	}
+	// Query parameters come from a map, sort them to keep the generated SQL stable.
+	slices.SortFunc(params.Attributes, func(a, b models.AttributeFilter) int {
+		return strings.Compare(a.Key+a.Op, b.Key+b.Op)
+	})
+
+	params.Cursor = r.URL.Query().Get("cursor")
+
+	return params, true
+}
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// parseAttributeFilter parses a feed filter from an attr.{key} query
+// parameter, where the key may end with _gte or _lte for numeric ranges.
+func parseAttributeFilter(key, value string) (models.AttributeFilter, error) {
+	filter := models.AttributeFilter{Key: key, Op: models.AttributeFilterEq, Value: value}
+
+	if base, ok := strings.CutSuffix(key, "_gte"); ok {
+		filter.Key, filter.Op = base, models.AttributeFilterGte
+	} else if base, ok := strings.CutSuffix(key, "_lte"); ok {
+		filter.Key, filter.Op = base, models.AttributeFilterLte
+	}
+
+	return filter, checkAttributeFilter(filter)
+}
+
+// checkAttributeFilter checks the key and value of a feed attribute filter.
+func checkAttributeFilter(filter models.AttributeFilter) error {
+	value := filter.Value
+	if !attributeKeyPattern.MatchString(filter.Key) {
+		return errors.New("invalid attribute key")
+	}
+	if value == "" || utf8.RuneCountInString(value) > 200 {
+		return errors.New("value must be 1-200 characters")
+	}
+	if filter.Op != models.AttributeFilterEq {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("range value must be an integer")
+		}
+	}
+
+	return nil
 }
 
-func (h *ListingHandler) RegisterRoutes(optionalAuthRouter, authRouter chi.Router) {
-	authRouter.Post("/listing", h.Create)
-	optionalAuthRouter.Get("/listing/feed", h.GetFeed)
+func isCategoryValidationError(err error) bool {
+	return errors.Is(err, category.ErrCategoryNotFound) ||
+		errors.Is(err, category.ErrCategoryNotLeaf) ||
+		errors.Is(err, category.ErrInvalidAttributes)
 }
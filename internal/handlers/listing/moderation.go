@@ -0,0 +1,111 @@
+package listing
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ocenb/marketplace/internal/services/listing"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type ModerateListingRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500"`
+}
+
+// @Summary Take down a listing
+// @Description Available to moderators. Archives a listing of any user; the action and its reason are recorded in the audit log.
+// @Param id path int true "Listing ID"
+// @Param request body ModerateListingRequest true "Moderation reason"
+// @Security BearerAuth
+// @Success 200 {object} models.Listing "Listing archived successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 409 {object} httputil.ErrorResponse "Listing is already archived"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /moderation/listing/{id}/archive [post]
+func (h *ListingHandler) ModerateArchive(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.ModerateArchive"))
+
+	moderatorID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	var req ModerateListingRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	archivedListing, err := h.listingService.ArchiveAsModerator(r.Context(), moderatorID, id, req.Reason)
+	if err != nil {
+		if errors.Is(err, listing.ErrInvalidStatusTransition) {
+			log.Info("Listing is already archived", slog.Int64("listing_id", id))
+			httputil.ConflictError(w, log, err.Error())
+			return
+		}
+		h.handleOwnershipError(w, log, err, id, "Moderate listing")
+		return
+	}
+
+	log.Info("Listing archived by moderator",
+		slog.Int64("listing_id", id),
+		slog.Int64("moderator_id", moderatorID),
+	)
+
+	httputil.WriteJSON(w, archivedListing, http.StatusOK, log)
+}
+
+// @Summary Remove a listing
+// @Description Available to moderators. Deletes a listing of any user; the action and its reason are recorded in the audit log.
+// @Param id path int true "Listing ID"
+// @Param request body ModerateListingRequest true "Moderation reason"
+// @Security BearerAuth
+// @Success 204 "Listing deleted successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Listing not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /moderation/listing/{id} [delete]
+func (h *ListingHandler) ModerateDelete(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.ModerateDelete"))
+
+	moderatorID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, ok := parseListingID(w, r, log)
+	if !ok {
+		return
+	}
+
+	var req ModerateListingRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+
+	err := h.listingService.DeleteAsModerator(r.Context(), moderatorID, id, req.Reason)
+	if err != nil {
+		h.handleOwnershipError(w, log, err, id, "Moderate listing")
+		return
+	}
+
+	log.Info("Listing deleted by moderator",
+		slog.Int64("listing_id", id),
+		slog.Int64("moderator_id", moderatorID),
+	)
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
@@ -0,0 +1,160 @@
+package listing
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/listing"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+// SavedSearchQuery holds the feed filters and sort order of a saved search,
+// with the same meaning as the /listing/feed query parameters.
+type SavedSearchQuery struct {
+	Query      string                   `json:"q,omitempty" validate:"max=200"`
+	SortBy     string                   `json:"sort_by,omitempty" validate:"omitempty,oneof=createdAt price relevance"`
+	SortOrder  string                   `json:"sort_order,omitempty" validate:"omitempty,oneof=asc desc"`
+	MinPrice   int64                    `json:"min_price,omitempty" validate:"min=0"`
+	MaxPrice   int64                    `json:"max_price,omitempty" validate:"omitempty,min=0,gtefield=MinPrice"`
+	CategoryID int64                    `json:"category_id,omitempty" validate:"omitempty,min=1"`
+	Attributes []models.AttributeFilter `json:"attributes,omitempty" validate:"max=20,dive"`
+}
+
+type CreateSavedSearchRequest struct {
+	Name  string           `json:"name" validate:"required,max=100"`
+	Query SavedSearchQuery `json:"query"`
+}
+
+// @Summary Save a search
+// @Description Saves the feed filters and sort order under a name. New active listings of other users matching a saved search are announced to its owner periodically.
+// @Param request body CreateSavedSearchRequest true "Saved search"
+// @Security BearerAuth
+// @Success 201 {object} models.SavedSearch "Search saved successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 409 {object} httputil.ErrorResponse "Saved search limit reached"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/saved-searches [post]
+func (h *ListingHandler) CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.CreateSavedSearch"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req CreateSavedSearchRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+	for _, filter := range req.Query.Attributes {
+		if err := checkAttributeFilter(filter); err != nil {
+			httputil.BadRequestError(w, log, fmt.Sprintf("Invalid attribute filter '%s': %s", filter.Key, err.Error()))
+			return
+		}
+	}
+
+	savedSearch, err := h.listingService.CreateSavedSearch(r.Context(), userID, req.Name, models.FeedParams{
+		Query:      req.Query.Query,
+		SortBy:     req.Query.SortBy,
+		SortOrder:  req.Query.SortOrder,
+		MinPrice:   req.Query.MinPrice,
+		MaxPrice:   req.Query.MaxPrice,
+		CategoryID: req.Query.CategoryID,
+		Attributes: req.Query.Attributes,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, listing.ErrRelevanceRequiresQuery):
+			log.Info("Saved search rejected", utils.ErrLog(err))
+			httputil.BadRequestError(w, log, err.Error())
+		case errors.Is(err, listing.ErrTooManySavedSearches):
+			log.Info("Saved search rejected", utils.ErrLog(err))
+			httputil.ConflictError(w, log, err.Error())
+		default:
+			log.Error("Internal error during Create saved search", utils.ErrLog(err))
+			httputil.InternalError(w, log)
+		}
+		return
+	}
+
+	log.Info("Search saved successfully", slog.Int64("saved_search_id", savedSearch.ID))
+
+	httputil.WriteJSON(w, savedSearch, http.StatusCreated, log)
+}
+
+// @Summary Get my saved searches
+// @Security BearerAuth
+// @Success 200 {array} models.SavedSearch "Saved searches, oldest first"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/saved-searches [get]
+func (h *ListingHandler) GetSavedSearches(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.GetSavedSearches"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	searches, err := h.listingService.GetSavedSearches(r.Context(), userID)
+	if err != nil {
+		log.Error("Failed to get saved searches", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, searches, http.StatusOK, log)
+}
+
+// @Summary Delete a saved search
+// @Param id path int true "Saved search ID"
+// @Security BearerAuth
+// @Success 204 "Saved search deleted successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 404 {object} httputil.ErrorResponse "Saved search not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me/saved-searches/{id} [delete]
+func (h *ListingHandler) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("ListingHandler.DeleteSavedSearch"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id < 1 {
+		httputil.BadRequestError(w, log, "Invalid saved search 'id' parameter")
+		return
+	}
+
+	err = h.listingService.DeleteSavedSearch(r.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, listing.ErrSavedSearchNotFound) {
+			log.Info("Saved search not found", slog.Int64("saved_search_id", id))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Failed to delete saved search", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Saved search deleted successfully", slog.Int64("saved_search_id", id))
+
+	httputil.WriteJSON(w, nil, http.StatusNoContent, log)
+}
@@ -0,0 +1,197 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/user"
+	"github.com/ocenb/marketplace/internal/utils"
+	"github.com/ocenb/marketplace/internal/utils/httputil"
+)
+
+type UserHandlerInterface interface {
+	GetProfile(w http.ResponseWriter, r *http.Request)
+	GetProfileByLogin(w http.ResponseWriter, r *http.Request)
+	GetMe(w http.ResponseWriter, r *http.Request)
+	UpdateMe(w http.ResponseWriter, r *http.Request)
+	RegisterRoutes(noAuthRouter, sessionRouter chi.Router)
+}
+
+// UpdateMeRequest changes the fields that are set. An empty avatar_url
+// removes the avatar.
+type UpdateMeRequest struct {
+	DisplayName        *string                    `json:"display_name" validate:"omitnil,max=100"`
+	Bio                *string                    `json:"bio" validate:"omitnil,max=1000"`
+	AvatarURL          *string                    `json:"avatar_url" validate:"omitnil,max=255"`
+	ContactPreferences *models.ContactPreferences `json:"contact_preferences" validate:"omitnil"`
+}
+
+type UserHandler struct {
+	userService user.UserServiceInterface
+	log         *slog.Logger
+	validator   *validator.Validate
+}
+
+func New(userService user.UserServiceInterface, log *slog.Logger, validator *validator.Validate) UserHandlerInterface {
+	return &UserHandler{
+		userService,
+		log,
+		validator,
+	}
+}
+
+// @Summary Get a public user profile
+// @Description Returns the public profile of a seller by ID or login (/users/{login}): member-since date and number of active listings. Numeric values are looked up as IDs first, so a numeric login equal to the ID of another user is only reachable through /users/by-login/{login}.
+// @Param id path string true "User ID or login"
+// @Success 200 {object} models.UserProfile "Successfully retrieved profile"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 404 {object} httputil.ErrorResponse "User not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /users/{id} [get]
+func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("UserHandler.GetProfile"))
+
+	idOrLogin := chi.URLParam(r, "id")
+	if idOrLogin == "" || len(idOrLogin) > 50 {
+		httputil.BadRequestError(w, log, "Invalid user 'id' or 'login' parameter")
+		return
+	}
+
+	profile, err := h.userService.GetProfile(r.Context(), idOrLogin)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			log.Info("User not found", slog.String("user", idOrLogin))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during Get profile", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, profile, http.StatusOK, log)
+}
+
+// @Summary Get a public user profile by login
+// @Description Returns the same public profile as /users/{id}, looking the value up as a login only.
+// @Param login path string true "User login"
+// @Success 200 {object} models.UserProfile "Successfully retrieved profile"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 404 {object} httputil.ErrorResponse "User not found"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /users/by-login/{login} [get]
+func (h *UserHandler) GetProfileByLogin(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("UserHandler.GetProfileByLogin"))
+
+	login := chi.URLParam(r, "login")
+	if login == "" || len(login) > 50 {
+		httputil.BadRequestError(w, log, "Invalid user 'login' parameter")
+		return
+	}
+
+	profile, err := h.userService.GetProfileByLogin(r.Context(), login)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			log.Info("User not found", slog.String("login", login))
+			httputil.NotFoundError(w, log, err.Error())
+			return
+		}
+		log.Error("Internal error during Get profile by login", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, profile, http.StatusOK, log)
+}
+
+// @Summary Get the current user
+// @Description Returns the account of the caller, including the private profile fields.
+// @Security BearerAuth
+// @Success 200 {object} models.CurrentUser "Current user"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me [get]
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("UserHandler.GetMe"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	me, err := h.userService.GetCurrent(r.Context(), userID)
+	if err != nil {
+		log.Error("Failed to get current user", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	httputil.WriteJSON(w, me, http.StatusOK, log)
+}
+
+// @Summary Update the current user's profile
+// @Description Changes the display name, bio, avatar URL or contact preferences; omitted fields are kept. Contact preferences are replaced as a whole.
+// @Param request body UpdateMeRequest true "Profile fields to change"
+// @Security BearerAuth
+// @Success 200 {object} models.CurrentUser "Profile updated successfully"
+// @Failure 400 {object} httputil.ErrorResponse "Bad request"
+// @Failure 401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure 403 {object} httputil.ErrorResponse "Forbidden"
+// @Failure 500 {object} httputil.ErrorResponse "Internal server error"
+// @Router /me [patch]
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	log := h.log.With(utils.OpLog("UserHandler.UpdateMe"))
+
+	userID, ok := utils.GetInfoFromContext(r.Context(), log)
+	if !ok {
+		httputil.InternalError(w, log)
+		return
+	}
+
+	var req UpdateMeRequest
+	if !httputil.DecodeAndValidate(w, r, &req, h.validator, log) {
+		return
+	}
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		err := h.validator.Var(*req.AvatarURL, "url")
+		if err == nil {
+			err = httputil.ValidateImage(log, *req.AvatarURL)
+		}
+		if err != nil {
+			log.Info("Failed to validate avatar", utils.ErrLog(err))
+			httputil.BadRequestError(w, log, fmt.Sprintf("Validation failed: invalid avatar_url: %s", err.Error()))
+			return
+		}
+	}
+
+	me, err := h.userService.UpdateProfile(r.Context(), userID, models.ProfileUpdate{
+		DisplayName:        req.DisplayName,
+		Bio:                req.Bio,
+		AvatarURL:          req.AvatarURL,
+		ContactPreferences: req.ContactPreferences,
+	})
+	if err != nil {
+		log.Error("Failed to update profile", utils.ErrLog(err))
+		httputil.InternalError(w, log)
+		return
+	}
+
+	log.Info("Profile updated successfully", slog.Int64("user_id", userID))
+
+	httputil.WriteJSON(w, me, http.StatusOK, log)
+}
+
+func (h *UserHandler) RegisterRoutes(noAuthRouter, sessionRouter chi.Router) {
+	noAuthRouter.Get("/users/{id}", h.GetProfile)
+	noAuthRouter.Get("/users/by-login/{login}", h.GetProfileByLogin)
+
+	sessionRouter.Get("/me", h.GetMe)
+	sessionRouter.Patch("/me", h.UpdateMe)
+}
@@ -25,7 +25,7 @@ func NewHttpServer(log *slog.Logger, cfg *config.Config) *HttpServer {
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(middlewares.RealIPMiddleware(cfg.Server.TrustedProxyPrefixes))
 	router.Use(middleware.Recoverer)
 	router.Use(middlewares.LoggingMiddleware(log))
 
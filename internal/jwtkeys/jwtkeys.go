@@ -0,0 +1,366 @@
+// Package jwtkeys manages the keys used to sign and verify access tokens.
+//
+// A key ring has at most one signing key and any number of verification keys.
+// Asymmetric keys (RSA for RS256, Ed25519 for EdDSA) are identified by a kid
+// header equal to their RFC 7638 thumbprint and published as a JWK set, so
+// other services can verify tokens without sharing a secret. Rotating a key
+// means making the new key the signing key and keeping the old one as a
+// verification key until the tokens it signed have expired.
+//
+// When no signing key is configured, tokens are signed with HS256 using the
+// shared secret, which keeps the previous setup working. HS256 tokens are
+// accepted for as long as the secret is configured, also next to a signing
+// key; the secret has to be removed to retire it.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+var (
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrNoKeys          = errors.New("no signing key or secret configured")
+	ErrUnsupportedKey  = errors.New("unsupported key type")
+	ErrKeyNotPrivate   = errors.New("signing key must be a private key")
+	ErrRSAKeyTooShort  = errors.New("rsa keys must be at least 2048 bits")
+	ErrDuplicateKeyIDs = errors.New("key is configured more than once")
+)
+
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Public  crypto.PublicKey
+	private crypto.Signer
+	// members holds the key-type specific JWK members.
+	members JWK
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type KeyRing struct {
+	signing      *Key
+	verification map[string]*Key
+	secret       []byte
+}
+
+// Load builds a key ring from PEM files. signingKeyFile may be empty, in which
+// case tokens are signed with the HMAC secret. The secret, if set, is also
+// accepted for verification so that tokens issued before switching to
+// asymmetric keys stay valid until they expire.
+func Load(signingKeyFile string, verificationKeyFiles []string, secret string) (*KeyRing, error) {
+	ring := &KeyRing{
+		verification: make(map[string]*Key),
+		secret:       []byte(secret),
+	}
+
+	if signingKeyFile != "" {
+		key, err := loadKey(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("%s: %w", signingKeyFile, ErrKeyNotPrivate)
+		}
+		ring.signing = key
+		ring.verification[key.ID] = key
+	}
+
+	for _, file := range verificationKeyFiles {
+		if file == "" {
+			continue
+		}
+		key, err := loadKey(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ring.verification[key.ID]; ok {
+			return nil, fmt.Errorf("%s: %w", file, ErrDuplicateKeyIDs)
+		}
+		ring.verification[key.ID] = key
+	}
+
+	if ring.signing == nil && len(ring.secret) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return ring, nil
+}
+
+// NewSigningRing builds a key ring around an in-memory private key, for
+// services that generate their keys instead of loading them from files.
+func NewSigningRing(signer crypto.Signer) (*KeyRing, error) {
+	key, err := newKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyRing{
+		signing:      key,
+		verification: map[string]*Key{key.ID: key},
+	}, nil
+}
+
+// Sign signs the claims with the signing key, or with the HMAC secret if the
+// ring has no signing key.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	if r.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(r.secret)
+	}
+
+	token := jwt.NewWithClaims(r.signing.Method, claims)
+	token.Header["kid"] = r.signing.ID
+
+	return token.SignedString(r.signing.private)
+}
+
+// Keyfunc resolves the verification key of a token by its kid header. Tokens
+// without kid are verified with the HMAC secret.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(r.secret) == 0 {
+			return nil, ErrUnknownKey
+		}
+		return r.secret, nil
+	}
+
+	key, ok := r.verification[kid]
+	if !ok || key.Method.Alg() != token.Method.Alg() {
+		return nil, ErrUnknownKey
+	}
+
+	return key.Public, nil
+}
+
+// Algorithms returns the algorithms accepted by the ring, for
+// jwt.WithValidMethods.
+func (r *KeyRing) Algorithms() []string {
+	seen := map[string]bool{}
+	var algorithms []string
+
+	if len(r.secret) > 0 {
+		seen[jwt.SigningMethodHS256.Alg()] = true
+		algorithms = append(algorithms, jwt.SigningMethodHS256.Alg())
+	}
+	for _, key := range r.verification {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			algorithms = append(algorithms, key.Method.Alg())
+		}
+	}
+
+	return algorithms
+}
+
+// JWKS returns the public verification keys. The HMAC secret is never
+// published.
+func (r *KeyRing) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(r.verification))}
+
+	if r.signing != nil {
+		set.Keys = append(set.Keys, r.signing.jwk())
+	}
+	for id, key := range r.verification {
+		if r.signing != nil && id == r.signing.ID {
+			continue
+		}
+		set.Keys = append(set.Keys, key.jwk())
+	}
+
+	return set
+}
+
+// Key parses a public key published by another party. The key keeps the kid
+// it was published with.
+func (j JWK) Key() (*Key, error) {
+	var public crypto.PublicKey
+
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 key: %w", err)
+		}
+		if j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		public = ed25519.PublicKey(x)
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	key, err := newKey(public)
+	if err != nil {
+		return nil, err
+	}
+	if j.Alg != "" && j.Alg != key.Method.Alg() {
+		return nil, ErrUnsupportedKey
+	}
+	if j.Kid != "" {
+		key.ID = j.Kid
+	}
+
+	return key, nil
+}
+
+func (k *Key) jwk() JWK {
+	jwk := k.members
+	jwk.Kid = k.ID
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+	return jwk
+}
+
+func loadKey(file string) (*Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", file)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", file, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	key, err := newKey(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return key, nil
+}
+
+func newKey(parsed any) (*Key, error) {
+	key := &Key{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private = k
+		key.Public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.private = k
+		key.Public = k.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		key.Public = k
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, ErrRSAKeyTooShort
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.members = JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.members = JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	}
+	id, err := thumbprint(key.members)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+
+	return key, nil
+}
+
+// thumbprint computes the RFC 7638 thumbprint: the SHA-256 of the required
+// members of the JWK serialized with sorted keys and no whitespace.
+func thumbprint(jwk JWK) (string, error) {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", ErrUnsupportedKey
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,340 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey := newRSAKey(t, minRSAKeyBits)
+	edKey := newEd25519Key(t)
+
+	tests := []struct {
+		name    string
+		ring    *KeyRing
+		alg     string
+		withKid bool
+	}{
+		{"HS256", loadRing(t, nil, nil, testSecret), "HS256", false},
+		{"RS256", loadRing(t, rsaKey, nil, ""), "RS256", true},
+		{"EdDSA", loadRing(t, edKey, nil, ""), "EdDSA", true},
+		{"EdDSA with secret", loadRing(t, edKey, nil, testSecret), "EdDSA", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.ring.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			token, err := parse(tt.ring, signed)
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if token.Method.Alg() != tt.alg {
+				t.Errorf("alg = %s, want %s", token.Method.Alg(), tt.alg)
+			}
+			kid, _ := token.Header["kid"].(string)
+			if tt.withKid && kid != tt.ring.signing.ID {
+				t.Errorf("kid = %q, want %q", kid, tt.ring.signing.ID)
+			}
+			if !tt.withKid && kid != "" {
+				t.Errorf("kid = %q, want none", kid)
+			}
+		})
+	}
+}
+
+func TestUnknownKid(t *testing.T) {
+	ring := loadRing(t, newEd25519Key(t), nil, testSecret)
+	other := loadRing(t, newEd25519Key(t), nil, "")
+
+	signed, err := other.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parse(ring, signed)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("parse() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	rsaKey := newRSAKey(t, minRSAKeyBits)
+	ring := loadRing(t, rsaKey, nil, testSecret)
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		sign func() (string, error)
+	}{
+		{
+			// The public key must not be accepted as an HMAC secret.
+			name: "HS256 with the kid of the RSA key",
+			sign: func() (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+				token.Header["kid"] = ring.signing.ID
+				return token.SignedString(publicDER)
+			},
+		},
+		{
+			name: "RS256 without kid",
+			sign: func() (string, error) {
+				return jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims()).SignedString(rsaKey)
+			},
+		},
+		{
+			name: "HS256 with an unknown kid",
+			sign: func() (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+				token.Header["kid"] = testSecret
+				return token.SignedString([]byte(testSecret))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.sign()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = parse(ring, signed)
+			if !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("parse() error = %v, want %v", err, ErrUnknownKey)
+			}
+		})
+	}
+
+	// Without a secret, HS256 is not an accepted algorithm at all.
+	withoutSecret := loadRing(t, rsaKey, nil, "")
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parse(withoutSecret, signed)
+	if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("parse() without secret error = %v, want %v", err, jwt.ErrTokenSignatureInvalid)
+	}
+}
+
+func TestShortRSAKey(t *testing.T) {
+	short := newRSAKey(t, 1024)
+
+	_, err := Load(writeKey(t, short), nil, "")
+	if !errors.Is(err, ErrRSAKeyTooShort) {
+		t.Errorf("Load() signing key error = %v, want %v", err, ErrRSAKeyTooShort)
+	}
+
+	_, err = Load("", []string{writeKey(t, short.Public())}, testSecret)
+	if !errors.Is(err, ErrRSAKeyTooShort) {
+		t.Errorf("Load() verification key error = %v, want %v", err, ErrRSAKeyTooShort)
+	}
+
+	_, err = NewSigningRing(short)
+	if !errors.Is(err, ErrRSAKeyTooShort) {
+		t.Errorf("NewSigningRing() error = %v, want %v", err, ErrRSAKeyTooShort)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey := newEd25519Key(t)
+	newKey := newEd25519Key(t)
+
+	oldRing := loadRing(t, oldKey, nil, "")
+	oldToken, err := oldRing.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// During the rotation the old public key stays a verification key.
+	rotating := loadRing(t, newKey, []crypto.PublicKey{oldKey.Public()}, "")
+	if _, err := parse(rotating, oldToken); err != nil {
+		t.Errorf("parse() of a token of the previous key error = %v", err)
+	}
+	newToken, err := rotating.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(rotating, newToken); err != nil {
+		t.Errorf("parse() of a token of the new key error = %v", err)
+	}
+	if got := len(rotating.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS() has %d keys, want 2", got)
+	}
+
+	// The secret keeps verifying HS256 tokens next to the keys until it is
+	// removed from the configuration.
+	secretToken, err := loadRing(t, nil, nil, testSecret).Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(loadRing(t, newKey, nil, testSecret), secretToken); err != nil {
+		t.Errorf("parse() of an HS256 token with the secret set error = %v", err)
+	}
+	if _, err := parse(loadRing(t, newKey, nil, ""), secretToken); err == nil {
+		t.Errorf("parse() of an HS256 token after the secret was removed succeeded")
+	}
+
+	// Once the old key is rotated out, its tokens are rejected.
+	rotated := loadRing(t, newKey, nil, "")
+	_, err = parse(rotated, oldToken)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("parse() of a token of a rotated-out key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	key := newEd25519Key(t)
+	publicFile := writeKey(t, key.Public())
+
+	tests := []struct {
+		name              string
+		signingKeyFile    string
+		verificationFiles []string
+		secret            string
+		want              error
+	}{
+		{"no keys", "", nil, "", ErrNoKeys},
+		{"public signing key", publicFile, nil, "", ErrKeyNotPrivate},
+		{"duplicate key", writeKey(t, key), []string{publicFile}, "", ErrDuplicateKeyIDs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.signingKeyFile, tt.verificationFiles, tt.secret)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Load() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ring := loadRing(t, newRSAKey(t, minRSAKeyBits), []crypto.PublicKey{newEd25519Key(t).Public()}, testSecret)
+
+	set := ring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+	if set.Keys[0].Kid != ring.signing.ID {
+		t.Errorf("first key = %q, want the signing key %q", set.Keys[0].Kid, ring.signing.ID)
+	}
+	for _, jwk := range set.Keys {
+		if jwk.Kty == "oct" {
+			t.Errorf("JWKS() publishes the HMAC secret")
+		}
+
+		// A published key verifies the tokens of the ring on its own.
+		key, err := jwk.Key()
+		if err != nil {
+			t.Fatalf("Key() error = %v", err)
+		}
+		if key.ID != jwk.Kid || key.Method.Alg() != jwk.Alg {
+			t.Errorf("Key() = %s %s, want %s %s", key.ID, key.Method.Alg(), jwk.Kid, jwk.Alg)
+		}
+	}
+}
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func parse(ring *KeyRing, signed string) (*jwt.Token, error) {
+	return jwt.Parse(signed, ring.Keyfunc, jwt.WithValidMethods(ring.Algorithms()))
+}
+
+// loadRing writes the keys to PEM files and loads them like the
+// configuration would. signer may be nil to sign with the secret.
+func loadRing(t *testing.T, signer crypto.Signer, verification []crypto.PublicKey, secret string) *KeyRing {
+	t.Helper()
+
+	var signingFile string
+	if signer != nil {
+		signingFile = writeKey(t, signer)
+	}
+	var verificationFiles []string
+	for _, key := range verification {
+		verificationFiles = append(verificationFiles, writeKey(t, key))
+	}
+
+	ring, err := Load(signingFile, verificationFiles, secret)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return ring
+}
+
+// writeKey writes a private key as PKCS #8 or a public key as PKIX PEM.
+func writeKey(t *testing.T, key any) string {
+	t.Helper()
+
+	var block *pem.Block
+	switch key.(type) {
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+
+	file, err := os.CreateTemp(t.TempDir(), "key-*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if err := pem.Encode(file, block); err != nil {
+		t.Fatal(err)
+	}
+
+	return file.Name()
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
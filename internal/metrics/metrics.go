@@ -16,6 +16,9 @@ type Metrics struct {
 	RequestsCounter *prometheus.CounterVec
 	ResponseTime    *prometheus.HistogramVec
 	ListingsCounter prometheus.Counter
+
+	RevocationCacheLookups *prometheus.CounterVec
+	RevocationCacheSize    prometheus.Gauge
 }
 
 func NewMetrics(namespace string) *Metrics {
@@ -45,6 +48,21 @@ func NewMetrics(namespace string) *Metrics {
 					Help:      "Total number of listings created",
 				},
 			),
+			RevocationCacheLookups: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: namespace,
+					Name:      "token_revocation_cache_lookups_total",
+					Help:      "Access tokens validated against the in-memory revocation cache; hit means the session is revoked",
+				},
+				[]string{"result"},
+			),
+			RevocationCacheSize: promauto.NewGauge(
+				prometheus.GaugeOpts{
+					Namespace: namespace,
+					Name:      "token_revocation_cache_size",
+					Help:      "Number of revoked sessions held in the revocation cache",
+				},
+			),
 		}
 	})
 	return instance
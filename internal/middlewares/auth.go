@@ -5,8 +5,10 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/ocenb/marketplace/internal/models"
 	"github.com/ocenb/marketplace/internal/services/auth"
 	"github.com/ocenb/marketplace/internal/utils"
 	"github.com/ocenb/marketplace/internal/utils/httputil"
@@ -26,6 +28,26 @@ func AuthMiddleware(log *slog.Logger, authService auth.AuthServiceInterface) fun
 	}
 }
 
+// RequireRole allows the request only for users whose role includes the
+// permissions of the required role. It must be used after AuthMiddleware.
+func RequireRole(log *slog.Logger, role string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userRole, ok := utils.GetRoleFromContext(r.Context(), log)
+			if !ok || !models.HasRole(userRole, role) {
+				log.Info("Access denied: insufficient role",
+					slog.String("role", userRole),
+					slog.String("required", role),
+				)
+				httputil.ForbiddenError(w, log)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
 func OptionalAuthMiddleware(log *slog.Logger, authService auth.AuthServiceInterface) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -40,26 +62,77 @@ func OptionalAuthMiddleware(log *slog.Logger, authService auth.AuthServiceInterf
 	}
 }
 
-func validateToken(r *http.Request, log *slog.Logger, authService auth.AuthServiceInterface) (context.Context, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		log.Info("Authorization header is missing")
-		return nil, errors.New("authorization header is missing")
+// RequireScope rejects requests made with an API key that lacks the scope.
+// Requests authenticated with an access token are not limited by scopes.
+func RequireScope(log *slog.Logger, scope string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(utils.ScopesKey{}).([]string)
+			if ok && !slices.Contains(scopes, scope) {
+				log.Info("Access denied: API key lacks scope", slog.String("scope", scope))
+				httputil.ForbiddenError(w, log)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
 	}
+}
+
+// RequireSession rejects requests made with an API key, for endpoints that
+// manage the account and must be used by the user themselves.
+func RequireSession(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(utils.ScopesKey{}).([]string); ok {
+				log.Info("Access denied: endpoint is not available to API keys")
+				httputil.ForbiddenError(w, log)
+				return
+			}
 
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		log.Info("Invalid authorization header format")
-		return nil, errors.New("invalid authorization header format")
+			h.ServeHTTP(w, r)
+		})
 	}
+}
+
+// validateToken authenticates the request with an access token
+// ("Authorization: Bearer <token>") or an API key ("X-API-Key: <key>" or
+// "Authorization: ApiKey <key>").
+func validateToken(r *http.Request, log *slog.Logger, authService auth.AuthServiceInterface) (context.Context, error) {
+	scheme, credentials := "ApiKey", r.Header.Get("X-API-Key")
+	if credentials == "" {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			log.Info("Authorization header is missing")
+			return nil, errors.New("authorization header is missing")
+		}
 
-	token := tokenParts[1]
-	userID, err := authService.ValidateToken(r.Context(), token)
+		tokenParts := strings.Split(authHeader, " ")
+		if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "ApiKey") {
+			log.Info("Invalid authorization header format")
+			return nil, errors.New("invalid authorization header format")
+		}
+		scheme, credentials = tokenParts[0], tokenParts[1]
+	}
+
+	var claims *models.TokenClaims
+	var err error
+	if scheme == "ApiKey" {
+		claims, err = authService.ValidateAPIKey(r.Context(), credentials)
+	} else {
+		claims, err = authService.ValidateToken(r.Context(), credentials)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.WithValue(r.Context(), utils.UserIDKey{}, userID)
+	ctx := context.WithValue(r.Context(), utils.UserIDKey{}, claims.UserID)
+	ctx = context.WithValue(ctx, utils.RoleKey{}, claims.Role)
+	if claims.Scopes != nil {
+		ctx = context.WithValue(ctx, utils.ScopesKey{}, claims.Scopes)
+	} else {
+		ctx = context.WithValue(ctx, utils.SessionIDKey{}, claims.SessionID)
+	}
 
 	return ctx, nil
 }
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIPMiddleware replaces the remote address of requests coming from a
+// trusted proxy with the client address the proxy reports in X-Real-IP or
+// X-Forwarded-For. The headers of other requests are ignored, because any
+// client can set them.
+func RealIPMiddleware(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if remote, err := netip.ParseAddrPort(r.RemoteAddr); err == nil && trusted(remote.Addr()) {
+				if ip, ok := forwardedIP(r.Header, trusted); ok {
+					r.RemoteAddr = ip.String()
+				}
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client address reported by the proxy. Proxies
+// append to X-Forwarded-For, so it is read from the right, skipping the
+// trusted proxies; entries further left may have been set by the client.
+func forwardedIP(header http.Header, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	if forwardedFor := header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return netip.Addr{}, false
+			}
+			if i == 0 || !trusted(ip) {
+				return ip.Unmap(), true
+			}
+		}
+	}
+
+	if ip, err := netip.ParseAddr(strings.TrimSpace(header.Get("X-Real-IP"))); err == nil {
+		return ip.Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
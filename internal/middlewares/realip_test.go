@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   string
+		realIP         string
+		wantRemoteAddr string
+	}{
+		{"direct client", "203.0.113.1:1234", "", "", "203.0.113.1:1234"},
+		{"spoofed headers from an untrusted client", "203.0.113.1:1234", "198.51.100.7", "198.51.100.8", "203.0.113.1:1234"},
+		{"trusted proxy", "10.0.0.2:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"spoofed entry before the proxy hop", "10.0.0.2:1234", "192.0.2.99, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.2:1234", "198.51.100.7, 10.0.0.3", "", "198.51.100.7"},
+		{"X-Real-IP from a trusted proxy", "10.0.0.2:1234", "", "198.51.100.8", "198.51.100.8"},
+		{"malformed header", "10.0.0.2:1234", "not-an-ip", "", "10.0.0.2:1234"},
+		{"trusted proxy without headers", "10.0.0.2:1234", "", "", "10.0.0.2:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIPMiddleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.wantRemoteAddr {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.wantRemoteAddr)
+			}
+		})
+	}
+}
@@ -2,34 +2,396 @@ package models
 
 import "time"
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles; each role includes the permissions of the roles
+// ranked below it.
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// HasRole reports whether role grants at least the permissions of required.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	requiredRank, known := roleRanks[required]
+	return ok && known && rank >= requiredRank
+}
+
 type User struct {
 	ID           int64     `json:"id"`
 	Login        string    `json:"login"`
+	Email        string    `json:"email"`
 	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
 type UserPublic struct {
 	ID        int64     `json:"id"`
 	Login     string    `json:"login"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type Listing struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"user_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	Price       int64     `json:"price"`
+// CurrentUser is the account of the caller as returned by /me.
+type CurrentUser struct {
+	ID                 int64              `json:"id"`
+	Login              string             `json:"login"`
+	Email              string             `json:"email,omitempty"`
+	Role               string             `json:"role"`
+	CreatedAt          time.Time          `json:"created_at"`
+	DisplayName        string             `json:"display_name"`
+	Bio                string             `json:"bio"`
+	AvatarURL          string             `json:"avatar_url"`
+	ContactPreferences ContactPreferences `json:"contact_preferences"`
+}
+
+// ContactPreferences tell buyers how the user prefers to be contacted.
+type ContactPreferences struct {
+	ShowEmail        bool   `json:"show_email"`
+	Phone            string `json:"phone,omitempty" validate:"omitempty,e164"`
+	PreferredChannel string `json:"preferred_channel,omitempty" validate:"omitempty,oneof=email phone" enums:"email,phone"`
+}
+
+// ProfileUpdate holds the profile fields to change; nil fields are kept.
+type ProfileUpdate struct {
+	DisplayName        *string
+	Bio                *string
+	AvatarURL          *string
+	ContactPreferences *ContactPreferences
+}
+
+// UserProfile is the public page of a user as a seller.
+type UserProfile struct {
+	ID             int64     `json:"id"`
+	Login          string    `json:"login"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url"`
+	MemberSince    time.Time `json:"member_since"`
+	ActiveListings int       `json:"active_listings"`
+}
+
+// AccountExport is the personal data stored about a user, as downloaded from
+// /me/export.
+type AccountExport struct {
+	ExportedAt       time.Time      `json:"exported_at"`
+	Profile          CurrentUser    `json:"profile"`
+	TwoFactorEnabled bool           `json:"two_factor_enabled"`
+	Listings         []Listing      `json:"listings"`
+	Favorites        []Favorite     `json:"favorites"`
+	SavedSearches    []SavedSearch  `json:"saved_searches"`
+	Sessions         []Session      `json:"sessions"`
+	APIKeys          []APIKey       `json:"api_keys"`
+	Identities       []UserIdentity `json:"identities"`
+}
+
+// TokenPair is issued on login and on every refresh. The refresh token is
+// opaque and single-use.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// TokenClaims identify the user and the session of a validated access token.
+type TokenClaims struct {
+	UserID    int64
+	SessionID int64
+	Role      string
+	// Scopes is set only for requests authenticated with an API key.
+	Scopes []string
+}
+
+// ClientInfo describes the device a session is used from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	// RevokedAt is only loaded for the export of the account data.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RefreshToken is a stored refresh token together with the state of the
+// session (token family) it belongs to.
+type RefreshToken struct {
+	SessionID        int64
+	UserID           int64
+	ExpiresAt        time.Time
+	UsedAt           *time.Time
+	SessionRevokedAt *time.Time
+}
+
+// LoginResult is the outcome of a successful password check. Accounts with
+// two-factor authentication get a ChallengeToken instead of Tokens, which has
+// to be completed with a TOTP or recovery code.
+type LoginResult struct {
+	User               *UserPublic
+	Tokens             *TokenPair
+	ChallengeToken     string
+	ChallengeExpiresAt time.Time
+}
+
+type TOTP struct {
+	UserID       int64
+	Secret       string
+	LastUsedStep int64
+	ConfirmedAt  *time.Time
+}
+
+// TOTPSetup is returned when enrolling an authenticator app.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type LoginChallenge struct {
+	UserID    int64
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the issuer and the subject it assigned.
+type UserIdentity struct {
+	ID          int64     `json:"-"`
+	UserID      int64     `json:"-"`
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
 	CreatedAt   time.Time `json:"created_at"`
-	AuthorLogin string    `json:"author_login"`
-	IsOwner     bool      `json:"is_owner"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// OIDCLoginState is what is remembered about an OIDC login between the
+// redirect to the provider and the callback.
+type OIDCLoginState struct {
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+const (
+	ScopeListingsRead  = "listings:read"
+	ScopeListingsWrite = "listings:write"
+)
+
+// APIKeyScopes lists every scope an API key can be granted.
+var APIKeyScopes = []string{ScopeListingsRead, ScopeListingsWrite}
+
+// APIKey is a personal API key. The key itself is shown only once on
+// creation; Prefix identifies it afterwards.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// StoredAPIKey is an API key together with the data needed to authenticate
+// requests made with it.
+type StoredAPIKey struct {
+	APIKey
+	KeyHash   string
+	RevokedAt *time.Time
+	UserRole  string
+}
+
+// PasswordResetToken is a stored single-use password reset token.
+type PasswordResetToken struct {
+	UserID    int64
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Revocation is a revoked session whose access tokens have not expired yet.
+type Revocation struct {
+	SessionID int64
+	ExpiresAt time.Time
+}
+
+const (
+	AuditEventLoginLockout     = "login_lockout"
+	AuditEventRoleChanged      = "role_changed"
+	AuditEventListingModerated = "listing_moderated"
+	AuditEventAccountDeleted   = "account_deleted"
+)
+
+// AuditEntry is a security relevant event. UserID is nil when the event is
+// not tied to an existing account.
+type AuditEntry struct {
+	Event   string
+	UserID  *int64
+	IP      string
+	Details map[string]any
+}
+
+const (
+	ListingStatusDraft    = "draft"
+	ListingStatusActive   = "active"
+	ListingStatusReserved = "reserved"
+	ListingStatusSold     = "sold"
+	ListingStatusArchived = "archived"
+)
+
+// Listing is a listing as shown to users. Listings kept from a deleted account
+// have a zero UserID and an empty AuthorLogin.
+type Listing struct {
+	ID              int64             `json:"id"`
+	UserID          int64             `json:"user_id"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description"`
+	ImageURL        string            `json:"image_url"`
+	Price           int64             `json:"price"`
+	CategoryID      int64             `json:"category_id"`
+	Attributes      map[string]any    `json:"attributes"`
+	Status          string            `json:"status"`
+	StatusChangedAt time.Time         `json:"status_changed_at"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	AuthorLogin     string            `json:"author_login"`
+	IsOwner         bool              `json:"is_owner"`
+	IsFavorite      bool              `json:"is_favorite"`
+	FavoritesCount  int               `json:"favorites_count"`
+	Relevance       float64           `json:"relevance,omitempty"`
+	Highlight       *ListingHighlight `json:"highlight,omitempty"`
+}
+
+// ListingHighlight contains fragments of the listing text matching a search
+// query as HTML: the text is escaped and matches are wrapped in <mark></mark>.
+type ListingHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type FeedParams struct {
+	Page       int               `json:"page,omitempty" validate:"omitempty,min=1"`
+	Limit      int               `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+	SortBy     string            `json:"sort_by,omitempty" validate:"omitempty,oneof=createdAt price relevance"`
+	SortOrder  string            `json:"sort_order,omitempty" validate:"omitempty,oneof=asc desc"`
+	MinPrice   int64             `json:"min_price,omitempty" validate:"omitempty,min=0"`
+	MaxPrice   int64             `json:"max_price,omitempty" validate:"omitempty,min=0,gtefield=MinPrice"`
+	Status     string            `json:"status,omitempty" validate:"omitempty,oneof=draft active reserved sold archived"`
+	Query      string            `json:"q,omitempty" validate:"omitempty,max=200"`
+	CategoryID int64             `json:"category_id,omitempty" validate:"omitempty,min=1"`
+	Attributes []AttributeFilter `json:"attributes,omitempty" validate:"omitempty,dive"`
+	Cursor     string            `json:"cursor,omitempty"`
+	SellerID   int64             `json:"-"`
+	// AllStatuses drops the status filter; it is used for the owner's own
+	// listings only.
+	AllStatuses bool `json:"-"`
+	// Statuses, if set, replaces the status filter with a set of statuses.
+	Statuses []string `json:"-"`
+	// FavoritedBy restricts the feed to the favorites of the user.
+	FavoritedBy int64       `json:"-"`
+	After       *FeedCursor `json:"-"`
+}
+
+const (
+	AttributeFilterEq  = "eq"
+	AttributeFilterGte = "gte"
+	AttributeFilterLte = "lte"
+)
+
+// AttributeFilter restricts the feed to listings whose attribute Key
+// compares to Value with Op. Range operators apply to numeric attributes only.
+type AttributeFilter struct {
+	Key   string `json:"key" validate:"required,max=50"`
+	Op    string `json:"op" validate:"required,oneof=eq gte lte"`
+	Value string `json:"value" validate:"required,max=200"`
+}
+
+// FeedCursor is the decoded position of the last listing returned by a
+// keyset-paginated feed request.
+type FeedCursor struct {
+	SortBy    string    `json:"s"`
+	SortOrder string    `json:"o"`
+	CreatedAt time.Time `json:"c"`
+	Price     int64     `json:"p"`
+	ID        int64     `json:"i"`
+	// Filters is a digest of the feed filters the cursor was issued for.
+	Filters string `json:"f"`
+}
+
+// Favorite is a listing saved by a user.
+type Favorite struct {
+	ListingID int64     `json:"listing_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SavedSearch is a feed query saved by a user under a name. New listings
+// matching the query are announced to the user; LastCheckedAt is the time up
+// to which listings have been checked.
+type SavedSearch struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"-"`
+	Name          string     `json:"name"`
+	Query         FeedParams `json:"query"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastCheckedAt time.Time  `json:"last_checked_at"`
+}
+
+type Category struct {
+	ID       int64       `json:"id"`
+	ParentID *int64      `json:"parent_id"`
+	Name     string      `json:"name"`
+	Slug     string      `json:"slug"`
+	Children []*Category `json:"children,omitempty"`
+}
+
+const (
+	AttributeTypeEnum   = "enum"
+	AttributeTypeInt    = "int"
+	AttributeTypeBool   = "bool"
+	AttributeTypeString = "string"
+)
+
+// CategoryAttribute describes a structured field that listings in a category
+// may or must specify.
+type CategoryAttribute struct {
+	Key      string   `json:"key"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type" enums:"enum,int,bool,string"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+	Min      *int64   `json:"min,omitempty"`
+	Max      *int64   `json:"max,omitempty"`
+}
+
+// ListingsDashboard is the feed of the caller's own listings together with
+// the number of their listings in every status.
+type ListingsDashboard struct {
+	ListingsFeed
+	StatusCounts map[string]int `json:"status_counts"`
 }
 
 type ListingsFeed struct {
-	Listings []Listing `json:"listings"`
-	Total    int       `json:"total"`
-	Page     int       `json:"page"`
-	Limit    int       `json:"limit"`
+	Listings   []Listing `json:"listings"`
+	Total      int       `json:"total"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	TotalPages int       `json:"total_pages"`
+	HasNext    bool      `json:"has_next"`
+	HasPrev    bool      `json:"has_prev"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ocenb/marketplace/internal/mailer"
+)
+
+// Notification is a message to a user about an event in the marketplace.
+type Notification struct {
+	UserID  int64
+	Email   string
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the application log instead of
+// delivering them. It is meant for development and tests.
+type LogNotifier struct {
+	log *slog.Logger
+}
+
+func NewLogNotifier(log *slog.Logger) Notifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.log.Info("Notification",
+		slog.Int64("user_id", notification.UserID),
+		slog.String("subject", notification.Subject),
+		slog.String("body", notification.Body),
+	)
+	return nil
+}
+
+// MailNotifier emails notifications to the users. Users without an email
+// address are skipped.
+type MailNotifier struct {
+	mailer mailer.Mailer
+	log    *slog.Logger
+}
+
+func NewMailNotifier(mailer mailer.Mailer, log *slog.Logger) Notifier {
+	return &MailNotifier{mailer: mailer, log: log}
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.Email == "" {
+		n.log.Debug("Notification skipped, user has no email", slog.Int64("user_id", notification.UserID))
+		return nil
+	}
+
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      notification.Email,
+		Subject: notification.Subject,
+		Body:    notification.Body,
+	})
+}
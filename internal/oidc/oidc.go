@@ -0,0 +1,353 @@
+// Package oidc implements the relying party side of OpenID Connect login: the
+// authorization code flow with PKCE (RFC 7636) against a single issuer.
+//
+// The provider metadata is discovered lazily on first use, so the API starts
+// even while the provider is unreachable. ID tokens are verified against the
+// provider's JWK set, which is fetched again when a token is signed with an
+// unknown key.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ocenb/marketplace/internal/jwtkeys"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// keyRefreshInterval limits how often the JWK set is fetched again for
+	// tokens signed with an unknown key.
+	keyRefreshInterval = time.Minute
+	clockSkew          = time.Minute
+	maxResponseBytes   = 1 << 20
+	verifierBytes      = 32
+)
+
+var (
+	ErrDiscovery      = errors.New("oidc discovery failed")
+	ErrTokenExchange  = errors.New("authorization code exchange failed")
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the verified claims of an ID token that identify the user.
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]*jwtkeys.Key
+	keysFetchedAt time.Time
+}
+
+func NewClient(cfg Config, httpClient *http.Client) *Client {
+	cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+
+	return &Client{
+		cfg:  cfg,
+		http: httpClient,
+	}
+}
+
+// Issuer returns the issuer identifier external identities are scoped to.
+func (c *Client) Issuer() string {
+	return c.cfg.IssuerURL
+}
+
+// GenerateVerifier returns a random PKCE code verifier.
+func GenerateVerifier() (string, error) {
+	b := make([]byte, verifierBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256Challenge derives the PKCE code challenge sent with the authorization
+// request from the verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL of the provider's authorization endpoint the
+// user is sent to.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	md, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.cfg.ClientID)
+	query.Set("redirect_uri", c.cfg.RedirectURL)
+	query.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return md.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the claims of the
+// verified ID token. The nonce must be the one sent with the authorization
+// request.
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	md, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", c.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+
+	var token tokenResponse
+	status, err := c.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s %s", ErrTokenExchange, status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrTokenExchange)
+	}
+
+	return c.verify(ctx, md, token.IDToken, nonce)
+}
+
+func (c *Client) verify(ctx context.Context, md *metadata, rawToken, nonce string) (*Claims, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawToken, &claims,
+		func(token *jwt.Token) (any, error) {
+			return c.key(ctx, md, token)
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.AuthorizedParty != "" && claims.AuthorizedParty != c.cfg.ClientID {
+		return nil, fmt.Errorf("%w: token issued to another client", ErrInvalidIDToken)
+	}
+
+	return &Claims{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// key resolves the verification key of an ID token by its kid header,
+// fetching the JWK set again if the key is unknown.
+func (c *Client) key(ctx context.Context, md *metadata, token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.findKey(kid)
+	if key == nil && time.Since(c.keysFetchedAt) >= keyRefreshInterval {
+		err := c.fetchKeys(ctx, md)
+		if err != nil {
+			return nil, err
+		}
+		key = c.findKey(kid)
+	}
+	if key == nil || key.Method.Alg() != token.Method.Alg() {
+		return nil, jwtkeys.ErrUnknownKey
+	}
+
+	return key.Public, nil
+}
+
+// findKey returns the key with the kid. Tokens without kid are accepted only
+// while the provider publishes a single key.
+func (c *Client) findKey(kid string) *jwtkeys.Key {
+	if kid != "" {
+		return c.keys[kid]
+	}
+	if len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func (c *Client) fetchKeys(ctx context.Context, md *metadata) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+
+	var set jwtkeys.JWKS
+	status, err := c.doJSON(req, &set)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks: status %d", status)
+	}
+
+	// Keys of unsupported types or meant for encryption are skipped.
+	keys := make(map[string]*jwtkeys.Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	c.keys = keys
+	c.keysFetchedAt = time.Now()
+	return nil
+}
+
+func (c *Client) discover(ctx context.Context) (*metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.IssuerURL+discoveryPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+
+	var md metadata
+	status, err := c.doJSON(req, &md)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrDiscovery, status)
+	}
+	if md.Issuer != c.cfg.IssuerURL {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, md.Issuer, c.cfg.IssuerURL)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete provider metadata", ErrDiscovery)
+	}
+
+	c.metadata = &md
+	return c.metadata, nil
+}
+
+// doJSON sends the request and decodes a JSON response body of any status.
+func (c *Client) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+			return 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+type AuditRepoInterface interface {
+	Create(ctx context.Context, entry models.AuditEntry) error
+}
+
+type AuditRepo struct {
+	postgres *sql.DB
+}
+
+func New(postgres *sql.DB) AuditRepoInterface {
+	return &AuditRepo{postgres: postgres}
+}
+
+func (r *AuditRepo) Create(ctx context.Context, entry models.AuditEntry) error {
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	// Sent as a string because lib/pq sends []byte parameters as bytea.
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+
+	query := `INSERT INTO audit_log (event, user_id, ip, details) VALUES ($1, $2, $3, $4)`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, entry.Event, entry.UserID, entry.IP, string(data))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, created_at, last_used_at, expires_at`
+
+func (r *AuthRepo) CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	row := storage.QueryRowWithTx(ctx, r.postgres, query,
+		key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.ExpiresAt,
+	)
+	return row.Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetAPIKeyByPrefix returns the key with the prefix together with the current
+// role of its owner.
+func (r *AuthRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.StoredAPIKey, error) {
+	query := `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.created_at, k.last_used_at, k.expires_at,
+			k.key_hash, k.revoked_at, u.role
+		FROM api_keys AS k
+		JOIN users AS u ON u.id = k.user_id
+		WHERE k.prefix = $1
+	`
+
+	var key models.StoredAPIKey
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, prefix).Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		(*pq.StringArray)(&key.Scopes),
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.ExpiresAt,
+		&key.KeyHash,
+		&key.RevokedAt,
+		&key.UserRole,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetActiveAPIKeys returns the keys of the user that are neither revoked nor
+// expired.
+func (r *AuthRepo) GetActiveAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			(*pq.StringArray)(&key.Scopes),
+			&key.CreatedAt,
+			&key.LastUsedAt,
+			&key.ExpiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an active key of the user and reports whether it
+// existed.
+func (r *AuthRepo) RevokeAPIKey(ctx context.Context, userID, keyID int64) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, keyID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *AuthRepo) RevokeUserAPIKeys(ctx context.Context, userID int64) (int64, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// TouchAPIKey updates last_used_at, at most once per interval.
+func (r *AuthRepo) TouchAPIKey(ctx context.Context, keyID int64, interval time.Duration) error {
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, keyID, time.Now().Add(-interval))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -3,24 +3,74 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/ocenb/marketplace/internal/models"
 	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
 )
 
 type AuthRepoInterface interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (storage.SqlTx, error)
 	CheckTokenExists(ctx context.Context, token string) (bool, error)
-	CreateToken(ctx context.Context, token string, userID int64, expiresAt time.Time) error
-	DeleteExpiredTokens(ctx context.Context) error
+	CreateToken(ctx context.Context, token string, userID, sessionID int64, expiresAt time.Time) error
+	CreateSession(ctx context.Context, userID int64, client models.ClientInfo, expiresAt time.Time) (int64, error)
+	GetActiveSession(ctx context.Context, sessionID int64) (*models.Session, error)
+	GetActiveSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	GetUserSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	ExtendSession(ctx context.Context, sessionID int64, expiresAt time.Time) error
+	UpdateSessionClient(ctx context.Context, sessionID int64, client models.ClientInfo) error
+	TouchSession(ctx context.Context, sessionID int64, interval time.Duration) error
+	RevokeSession(ctx context.Context, sessionID int64) error
+	RevokeUserSessions(ctx context.Context, userID int64) (int64, error)
+	RevokeOtherSessions(ctx context.Context, userID, currentSessionID int64) (int64, error)
+	CreateRefreshToken(ctx context.Context, tokenHash string, sessionID int64, expiresAt time.Time) error
+	GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, tokenHash string) error
+	CreatePasswordResetToken(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error
+	GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	InvalidatePasswordResetTokens(ctx context.Context, userID int64) error
+	SaveTOTPSecret(ctx context.Context, userID int64, secret string) error
+	GetTOTPForUpdate(ctx context.Context, userID int64) (*models.TOTP, error)
+	IsTOTPEnabled(ctx context.Context, userID int64) (bool, error)
+	UseTOTPStep(ctx context.Context, userID, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	CreateLoginChallenge(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error
+	GetLoginChallengeForUpdate(ctx context.Context, tokenHash string) (*models.LoginChallenge, error)
+	IncrementLoginChallengeAttempts(ctx context.Context, tokenHash string) error
+	DeleteLoginChallenge(ctx context.Context, tokenHash string) error
+	GetActiveRevocations(ctx context.Context) ([]models.Revocation, error)
+	LockThrottle(ctx context.Context, scope, key string) (*time.Time, error)
+	GetBlockedUntil(ctx context.Context, scope, key string) (*time.Time, error)
+	RecordLoginFailure(ctx context.Context, scope, key string, expiresAt time.Time) (int, error)
+	BlockLogin(ctx context.Context, scope, key string, until time.Time, resetFailures bool) error
+	ClearLoginFailures(ctx context.Context, scope, key string) error
+	CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.StoredAPIKey, error)
+	GetActiveAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int64) (bool, error)
+	RevokeUserAPIKeys(ctx context.Context, userID int64) (int64, error)
+	TouchAPIKey(ctx context.Context, keyID int64, interval time.Duration) error
+	CreateOIDCLoginState(ctx context.Context, stateHash string, state models.OIDCLoginState) error
+	TakeOIDCLoginState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error)
+	GetUserIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error)
+	GetUserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error)
+	CreateUserIdentity(ctx context.Context, userID int64, issuer, subject, email string) error
+	TouchUserIdentity(ctx context.Context, identityID int64, email string) error
+	DeleteExpiredTokens(ctx context.Context) (int64, error)
 }
 
 type AuthRepo struct {
 	postgres *sql.DB
+	log      *slog.Logger
 }
 
-func New(postgres *sql.DB) AuthRepoInterface {
-	return &AuthRepo{postgres: postgres}
+func New(postgres *sql.DB, log *slog.Logger) AuthRepoInterface {
+	return &AuthRepo{postgres: postgres, log: log}
 }
 
 func (r *AuthRepo) BeginTx(ctx context.Context, opts *sql.TxOptions) (storage.SqlTx, error) {
@@ -38,9 +88,9 @@ func (r *AuthRepo) CheckTokenExists(ctx context.Context, token string) (bool, er
 	return exists, nil
 }
 
-func (r *AuthRepo) CreateToken(ctx context.Context, token string, userID int64, expiresAt time.Time) error {
-	query := `INSERT INTO tokens (token, user_id, expires_at) VALUES ($1, $2, $3)`
-	_, err := storage.ExecWithTx(ctx, r.postgres, query, token, userID, expiresAt)
+func (r *AuthRepo) CreateToken(ctx context.Context, token string, userID, sessionID int64, expiresAt time.Time) error {
+	query := `INSERT INTO tokens (token, user_id, session_id, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, token, userID, sessionID, expiresAt)
 	if err != nil {
 		return err
 	}
@@ -48,12 +98,356 @@ func (r *AuthRepo) CreateToken(ctx context.Context, token string, userID int64,
 	return nil
 }
 
-func (r *AuthRepo) DeleteExpiredTokens(ctx context.Context) error {
-	query := `DELETE FROM tokens WHERE expires_at < $1`
-	_, err := storage.ExecWithTx(ctx, r.postgres, query, time.Now())
+func (r *AuthRepo) CreateSession(ctx context.Context, userID int64, client models.ClientInfo, expiresAt time.Time) (int64, error) {
+	query := `INSERT INTO sessions (user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	var id int64
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, userID, client.UserAgent, client.IP, expiresAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_used_at, expires_at`
+
+func (r *AuthRepo) GetActiveSession(ctx context.Context, sessionID int64) (*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	var session models.Session
+	err := scanSession(storage.QueryRowWithTx(ctx, r.postgres, query, sessionID), &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *AuthRepo) GetActiveSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC, id DESC
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// GetUserSessions returns every session of the user, including revoked and
+// expired ones, newest first.
+func (r *AuthRepo) GetUserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `, revoked_at
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := scanSession(rows, &session, &session.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (r *AuthRepo) ExtendSession(ctx context.Context, sessionID int64, expiresAt time.Time) error {
+	query := `UPDATE sessions SET expires_at = $2 WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, sessionID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) UpdateSessionClient(ctx context.Context, sessionID int64, client models.ClientInfo) error {
+	query := `UPDATE sessions SET user_agent = $2, ip = $3, last_used_at = NOW() WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, sessionID, client.UserAgent, client.IP)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TouchSession updates last_used_at unless it was updated less than interval
+// ago, so that active clients do not write on every request.
+func (r *AuthRepo) TouchSession(ctx context.Context, sessionID int64, interval time.Duration) error {
+	query := `UPDATE sessions SET last_used_at = NOW() WHERE id = $1 AND last_used_at < $2`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, sessionID, time.Now().Add(-interval))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSession marks the session as revoked, which invalidates all of its
+// refresh tokens, and deletes its access tokens.
+func (r *AuthRepo) RevokeSession(ctx context.Context, sessionID int64) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = r.recordRevocations(ctx, `session_id = $1`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM tokens WHERE session_id = $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes every session of the user and returns the number
+// of deleted access tokens.
+func (r *AuthRepo) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.recordRevocations(ctx, `user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM tokens WHERE user_id = $1`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// RevokeOtherSessions revokes every session of the user except the current
+// one and returns the number of revoked sessions.
+func (r *AuthRepo) RevokeOtherSessions(ctx context.Context, userID, currentSessionID int64) (int64, error) {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID, currentSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.recordRevocations(ctx, `user_id = $1 AND session_id <> $2`, userID, currentSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM tokens WHERE user_id = $1 AND session_id IS DISTINCT FROM $2`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, userID, currentSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *AuthRepo) CreateRefreshToken(ctx context.Context, tokenHash string, sessionID int64, expiresAt time.Time) error {
+	query := `INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash, sessionID, expiresAt)
 	if err != nil {
 		return err
 	}
 
-	return err
+	return nil
+}
+
+func (r *AuthRepo) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT rt.session_id, s.user_id, rt.expires_at, rt.used_at, s.revoked_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s
+	`
+
+	var token models.RefreshToken
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, tokenHash).Scan(
+		&token.SessionID,
+		&token.UserID,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.SessionRevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *AuthRepo) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) error {
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) CreatePasswordResetToken(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) GetPasswordResetTokenForUpdate(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	query := `
+		SELECT user_id, expires_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var token models.PasswordResetToken
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, tokenHash).Scan(&token.UserID, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidatePasswordResetTokens marks every unused reset token of the user as
+// used.
+func (r *AuthRepo) InvalidatePasswordResetTokens(ctx context.Context, userID int64) error {
+	query := `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredTokens deletes expired access tokens, password reset tokens,
+// login challenges, OIDC login states, revocations, login throttling state and
+// expired sessions together with their refresh tokens. It returns the number
+// of deleted access tokens.
+func (r *AuthRepo) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	query := `DELETE FROM tokens WHERE expires_at < $1`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM sessions WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM password_reset_tokens WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM login_challenges WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM oidc_login_states WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM revocations WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `DELETE FROM login_throttle WHERE expires_at < $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner, session *models.Session, extra ...any) error {
+	dest := []any{
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
 }
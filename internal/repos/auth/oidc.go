@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+func (r *AuthRepo) CreateOIDCLoginState(ctx context.Context, stateHash string, state models.OIDCLoginState) error {
+	query := `INSERT INTO oidc_login_states (state_hash, code_verifier, nonce, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, stateHash, state.CodeVerifier, state.Nonce, state.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TakeOIDCLoginState deletes the state and returns it, so that every state is
+// used at most once.
+func (r *AuthRepo) TakeOIDCLoginState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING code_verifier, nonce, expires_at
+	`
+
+	var state models.OIDCLoginState
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, stateHash).Scan(
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (r *AuthRepo) GetUserIdentity(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2
+	`
+
+	var identity models.UserIdentity
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, issuer, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *AuthRepo) GetUserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var identity models.UserIdentity
+		err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Issuer,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+			&identity.LastLoginAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return identities, nil
+}
+
+func (r *AuthRepo) CreateUserIdentity(ctx context.Context, userID int64, issuer, subject, email string) error {
+	query := `INSERT INTO user_identities (user_id, issuer, subject, email) VALUES ($1, $2, $3, $4)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, issuer, subject, email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TouchUserIdentity records a login through the identity together with the
+// email the provider reported this time.
+func (r *AuthRepo) TouchUserIdentity(ctx context.Context, identityID int64, email string) error {
+	query := `UPDATE user_identities SET last_login_at = $2, email = $3 WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, identityID, time.Now(), email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
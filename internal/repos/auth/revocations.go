@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// RevocationsChannel is the LISTEN/NOTIFY channel that announces revoked
+// sessions. The payload is "<session id>:<unix time the revocation expires>".
+const RevocationsChannel = "token_revocations"
+
+// recordRevocations stores revocations for the sessions of the tokens matching
+// the condition and notifies other replicas once the transaction commits. Only
+// sessions with unexpired access tokens are recorded, and each revocation
+// expires together with the last of those tokens. It must be called before
+// the tokens are deleted.
+func (r *AuthRepo) recordRevocations(ctx context.Context, condition string, args ...any) error {
+	query := `
+		WITH revoked AS (
+			INSERT INTO revocations (session_id, expires_at)
+			SELECT session_id, MAX(expires_at)
+			FROM tokens
+			WHERE ` + condition + ` AND session_id IS NOT NULL AND expires_at > NOW()
+			GROUP BY session_id
+			ON CONFLICT (session_id) DO UPDATE
+			SET expires_at = GREATEST(revocations.expires_at, EXCLUDED.expires_at)
+			RETURNING session_id, expires_at
+		)
+		SELECT pg_notify('` + RevocationsChannel + `', session_id || ':' || FLOOR(EXTRACT(EPOCH FROM expires_at))::BIGINT)
+		FROM revoked
+	`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record revocations: %w", err)
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) GetActiveRevocations(ctx context.Context) ([]models.Revocation, error) {
+	query := `SELECT session_id, expires_at FROM revocations WHERE expires_at > NOW()`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	revocations := []models.Revocation{}
+	for rows.Next() {
+		var revocation models.Revocation
+		if err := rows.Scan(&revocation.SessionID, &revocation.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revocation: %w", err)
+		}
+		revocations = append(revocations, revocation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return revocations, nil
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+// LockThrottle locks the throttle row of the key for the rest of the
+// transaction, creating an empty row if there is none, and returns the time
+// until which the key is blocked, or nil if it is not blocked. Attempts of the
+// key that check the block and record their failure in one transaction are
+// serialized this way, so that parallel attempts cannot all pass the check
+// before any failure is recorded.
+func (r *AuthRepo) LockThrottle(ctx context.Context, scope, key string) (*time.Time, error) {
+	query := `
+		INSERT INTO login_throttle (scope, key, expires_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (scope, key) DO UPDATE SET scope = EXCLUDED.scope
+		RETURNING CASE WHEN blocked_until > NOW() THEN blocked_until END
+	`
+
+	var blockedUntil sql.NullTime
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, scope, key).Scan(&blockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if !blockedUntil.Valid {
+		return nil, nil
+	}
+
+	return &blockedUntil.Time, nil
+}
+
+// GetBlockedUntil returns the time until which the key is blocked, or nil if it
+// is not blocked.
+func (r *AuthRepo) GetBlockedUntil(ctx context.Context, scope, key string) (*time.Time, error) {
+	query := `
+		SELECT blocked_until
+		FROM login_throttle
+		WHERE scope = $1 AND key = $2 AND blocked_until > NOW()
+	`
+
+	var blockedUntil time.Time
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, scope, key).Scan(&blockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &blockedUntil, nil
+}
+
+// RecordLoginFailure counts a failed login, or another throttled attempt, for
+// the key and returns the number
+// of failures within the window. The count starts over once the row has
+// expired.
+func (r *AuthRepo) RecordLoginFailure(ctx context.Context, scope, key string, expiresAt time.Time) (int, error) {
+	query := `
+		INSERT INTO login_throttle (scope, key, failures, expires_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET failures = CASE
+				WHEN login_throttle.expires_at <= NOW() THEN 1
+				ELSE login_throttle.failures + 1
+			END,
+			expires_at = GREATEST(login_throttle.expires_at, EXCLUDED.expires_at)
+		RETURNING failures
+	`
+
+	var failures int
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, scope, key, expiresAt).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// BlockLogin blocks logins for the key until the given time. A lockout resets
+// the failure count, so that the key gets a fresh set of attempts afterwards.
+func (r *AuthRepo) BlockLogin(ctx context.Context, scope, key string, until time.Time, resetFailures bool) error {
+	query := `
+		UPDATE login_throttle
+		SET blocked_until = $3,
+			expires_at = GREATEST(expires_at, $3),
+			failures = CASE WHEN $4 THEN 0 ELSE failures END
+		WHERE scope = $1 AND key = $2
+	`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, scope, key, until, resetFailures)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) ClearLoginFailures(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM login_throttle WHERE scope = $1 AND key = $2`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, scope, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+// SaveTOTPSecret stores a new unconfirmed secret, replacing a previous
+// unconfirmed one. A confirmed secret is never replaced.
+func (r *AuthRepo) SaveTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL
+	`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) GetTOTPForUpdate(ctx context.Context, userID int64) (*models.TOTP, error) {
+	query := `
+		SELECT user_id, secret, last_used_step, confirmed_at
+		FROM user_totp
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+
+	var totp models.TOTP
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.LastUsedStep,
+		&totp.ConfirmedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+func (r *AuthRepo) IsTOTPEnabled(ctx context.Context, userID int64) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL)`
+	var enabled bool
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, userID).Scan(&enabled)
+	if err != nil {
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+// UseTOTPStep records the step of an accepted code and confirms the secret if
+// it was not confirmed yet.
+func (r *AuthRepo) UseTOTPStep(ctx context.Context, userID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2, confirmed_at = COALESCE(confirmed_at, NOW())
+		WHERE user_id = $1
+	`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTOTP disables two-factor authentication together with the recovery
+// codes of the user.
+func (r *AuthRepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	query := `DELETE FROM user_totp WHERE user_id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM recovery_codes WHERE user_id = $1`
+	_, err = storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	for _, hash := range codeHashes {
+		_, err = storage.ExecWithTx(ctx, r.postgres, query, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used and reports whether
+// such a code existed.
+func (r *AuthRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *AuthRepo) CreateLoginChallenge(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	query := `INSERT INTO login_challenges (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) GetLoginChallengeForUpdate(ctx context.Context, tokenHash string) (*models.LoginChallenge, error) {
+	query := `
+		SELECT user_id, attempts, expires_at
+		FROM login_challenges
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var challenge models.LoginChallenge
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, tokenHash).Scan(
+		&challenge.UserID,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (r *AuthRepo) IncrementLoginChallengeAttempts(ctx context.Context, tokenHash string) error {
+	query := `UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthRepo) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	query := `DELETE FROM login_challenges WHERE token_hash = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
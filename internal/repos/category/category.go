@@ -0,0 +1,159 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/lib/pq"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+type CategoryRepoInterface interface {
+	GetAll(ctx context.Context) ([]*models.Category, error)
+	IsLeaf(ctx context.Context, id int64) (bool, error)
+	CheckExists(ctx context.Context, id int64) (bool, error)
+	GetAttributes(ctx context.Context, categoryID int64) ([]models.CategoryAttribute, error)
+}
+
+type CategoryRepo struct {
+	postgres *sql.DB
+	log      *slog.Logger
+}
+
+func New(postgres *sql.DB, log *slog.Logger) CategoryRepoInterface {
+	return &CategoryRepo{postgres, log}
+}
+
+func (r *CategoryRepo) GetAll(ctx context.Context) ([]*models.Category, error) {
+	query := `SELECT id, parent_id, name, slug FROM categories ORDER BY id`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	var categories []*models.Category
+	for rows.Next() {
+		var category models.Category
+		var parentID sql.NullInt64
+		err := rows.Scan(&category.ID, &parentID, &category.Name, &category.Slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category row: %w", err)
+		}
+		if parentID.Valid {
+			category.ParentID = &parentID.Int64
+		}
+		categories = append(categories, &category)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return categories, nil
+}
+
+// IsLeaf reports whether the category has no subcategories. It returns
+// sql.ErrNoRows if the category does not exist.
+func (r *CategoryRepo) IsLeaf(ctx context.Context, id int64) (bool, error) {
+	query := `
+		SELECT NOT EXISTS(SELECT 1 FROM categories AS child WHERE child.parent_id = c.id)
+		FROM categories AS c
+		WHERE c.id = $1
+	`
+
+	var isLeaf bool
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, id).Scan(&isLeaf)
+	if err != nil {
+		return false, err
+	}
+
+	return isLeaf, nil
+}
+
+func (r *CategoryRepo) CheckExists(ctx context.Context, id int64) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)`
+	var exists bool
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, id).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetAttributes returns attribute definitions of the category and all of its
+// ancestors, ordered from the root category down to the given one.
+func (r *CategoryRepo) GetAttributes(ctx context.Context, categoryID int64) ([]models.CategoryAttribute, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.depth + 1 FROM categories AS c JOIN ancestors AS a ON c.id = a.parent_id
+		)
+		SELECT
+			ca.key,
+			ca.name,
+			ca.type,
+			ca.required,
+			ca.options,
+			ca.min_value,
+			ca.max_value
+		FROM
+			category_attributes AS ca
+		JOIN
+			ancestors AS a ON ca.category_id = a.id
+		ORDER BY
+			a.depth DESC, ca.id
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category attributes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	var attributes []models.CategoryAttribute
+	for rows.Next() {
+		var attribute models.CategoryAttribute
+		var options pq.StringArray
+		var minValue, maxValue sql.NullInt64
+		err := rows.Scan(
+			&attribute.Key,
+			&attribute.Name,
+			&attribute.Type,
+			&attribute.Required,
+			&options,
+			&minValue,
+			&maxValue,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category attribute row: %w", err)
+		}
+		attribute.Options = options
+		if minValue.Valid {
+			attribute.Min = &minValue.Int64
+		}
+		if maxValue.Valid {
+			attribute.Max = &maxValue.Int64
+		}
+		attributes = append(attributes, attribute)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return attributes, nil
+}
@@ -3,11 +3,15 @@ package listing
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/ocenb/marketplace/internal/models"
 	"github.com/ocenb/marketplace/internal/storage"
 	"github.com/ocenb/marketplace/internal/utils"
@@ -15,9 +19,30 @@ import (
 
 type ListingRepoInterface interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (storage.SqlTx, error)
-	Create(ctx context.Context, userID int64, title string, description string, imageUrl string, price int64) (*models.Listing, error)
-	GetFeed(ctx context.Context, userID int64, page, limit int, sortBy, sortOrder string, minPrice, maxPrice int64) (*models.ListingsFeed, error)
-	CheckExists(ctx context.Context, id int64) (bool, error)
+	Create(ctx context.Context, listing *models.Listing) (*models.Listing, error)
+	GetFeed(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error)
+	CountFeed(ctx context.Context, params models.FeedParams) (int, error)
+	CountByStatus(ctx context.Context, userID int64) (map[string]int, error)
+	GetByID(ctx context.Context, id, userID int64) (*models.Listing, error)
+	GetForUpdate(ctx context.Context, id int64) (*models.Listing, error)
+	Update(ctx context.Context, listing *models.Listing) (*models.Listing, error)
+	UpdateStatus(ctx context.Context, id int64, status string) (*models.Listing, error)
+	Delete(ctx context.Context, id int64) error
+	GetByUser(ctx context.Context, userID int64) ([]models.Listing, error)
+	DeleteByUser(ctx context.Context, userID int64) (int64, error)
+	AnonymizeByUser(ctx context.Context, userID int64) (int64, error)
+	AddFavorite(ctx context.Context, userID, listingID int64) error
+	RemoveFavorite(ctx context.Context, userID, listingID int64) error
+	GetFavoritesByUser(ctx context.Context, userID int64) ([]models.Favorite, error)
+	CreateSavedSearch(ctx context.Context, search *models.SavedSearch) (*models.SavedSearch, error)
+	GetSavedSearchesByUser(ctx context.Context, userID int64) ([]models.SavedSearch, error)
+	CountSavedSearches(ctx context.Context, userID int64) (int, error)
+	DeleteSavedSearch(ctx context.Context, userID, id int64) (bool, error)
+	GetSavedSearches(ctx context.Context) ([]models.SavedSearch, error)
+	ClaimSavedSearch(ctx context.Context, id int64, lastCheckedAt time.Time) (time.Time, error)
+	RecordSavedSearchMatches(ctx context.Context, search models.SavedSearch, since, until time.Time, limit int) ([]models.Listing, int, error)
+	DeleteSavedSearchMatches(ctx context.Context, id int64, before time.Time) error
+	ReindexSearch(ctx context.Context) error
 }
 
 type ListingRepo struct {
@@ -33,48 +58,44 @@ func (r *ListingRepo) BeginTx(ctx context.Context, opts *sql.TxOptions) (storage
 	return r.postgres.BeginTx(ctx, opts)
 }
 
-func (r *ListingRepo) Create(
-	ctx context.Context,
-	userID int64,
-	title string,
-	description string,
-	imageUrl string,
-	price int64,
-) (*models.Listing, error) {
+func (r *ListingRepo) Create(ctx context.Context, listing *models.Listing) (*models.Listing, error) {
 	query := `
 		WITH inserted_listing AS (
-			INSERT INTO listings (user_id, title, description, image_url, price)
-			VALUES ($1, $2, $3, $4, $5)
-			RETURNING id, user_id, title, description, image_url, price, created_at
+			INSERT INTO listings (user_id, title, description, image_url, price, category_id, attributes, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, user_id, title, description, image_url, price, category_id, attributes, status, status_changed_at, created_at, updated_at
 		)
 		SELECT
 			il.id,
-			il.user_id,
-			u.login AS author_login,
+			COALESCE(il.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
 			il.title,
 			il.description,
 			il.image_url,
 			il.price,
-			il.created_at
+			il.category_id,
+			il.attributes,
+			il.status,
+			il.status_changed_at,
+			il.created_at,
+			il.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = il.id) AS favorites_count
 		FROM
 			inserted_listing AS il
-		JOIN
+		LEFT JOIN
 			users AS u ON il.user_id = u.id;
 	`
 
-	listing := models.Listing{IsOwner: true}
-	row := storage.QueryRowWithTx(ctx, r.postgres, query, userID, title, description, imageUrl, price)
+	attributes, err := marshalAttributes(listing.Attributes)
+	if err != nil {
+		return nil, err
+	}
 
-	err := row.Scan(
-		&listing.ID,
-		&listing.UserID,
-		&listing.AuthorLogin,
-		&listing.Title,
-		&listing.Description,
-		&listing.ImageURL,
-		&listing.Price,
-		&listing.CreatedAt,
-	)
+	created := models.Listing{IsOwner: true}
+	row := storage.QueryRowWithTx(ctx, r.postgres, query,
+		listing.UserID, listing.Title, listing.Description, listing.ImageURL, listing.Price, listing.CategoryID, attributes, listing.Status)
+
+	err = scanListing(row, &created)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("listing creation failed or author not found: %w", err)
@@ -82,64 +103,93 @@ func (r *ListingRepo) Create(
 		return nil, fmt.Errorf("failed to scan created listing with author login: %w", err)
 	}
 
-	return &listing, nil
+	return &created, nil
 }
 
-func (r *ListingRepo) GetFeed(ctx context.Context, userID int64, page, limit int, sortBy, sortOrder string, minPrice, maxPrice int64) (*models.ListingsFeed, error) {
-	var whereClauses []string
-	var args []any
-	argCounter := 1
+func (r *ListingRepo) GetFeed(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error) {
+	filter := buildFeedFilter(params)
+	whereClause, args := filter.where, filter.args
+	argCounter := len(args) + 1
 
-	if minPrice > 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("l.price >= $%d", argCounter))
-		args = append(args, minPrice)
-		argCounter++
-	}
-	if maxPrice > 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("l.price <= $%d", argCounter))
-		args = append(args, maxPrice)
-		argCounter++
+	searchColumns := ""
+	if filter.searchArg > 0 {
+		searchColumns = fmt.Sprintf(`,
+			ts_rank(l.search_vector, websearch_to_tsquery('%[1]s', $%[2]d)) AS relevance,
+			ts_headline('%[1]s', l.title, websearch_to_tsquery('%[1]s', $%[2]d),
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', HighlightAll=true'),
+			ts_headline('%[1]s', coalesce(l.description, ''), websearch_to_tsquery('%[1]s', $%[2]d),
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=20, MinWords=5')`,
+			searchConfig, filter.searchArg)
 	}
 
-	whereClause := ""
-	if len(whereClauses) > 0 {
-		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+	sortColumn := "l.created_at"
+	switch params.SortBy {
+	case "price":
+		sortColumn = "l.price"
+	case "relevance":
+		sortColumn = "relevance"
+	}
+	sortOrder := "DESC"
+	if params.SortOrder == "asc" {
+		sortOrder = "ASC"
 	}
 
-	orderByClause := "ORDER BY l.created_at DESC"
-	switch sortBy {
-	case "createdAt":
-		orderByClause = fmt.Sprintf("ORDER BY l.created_at %s", strings.ToUpper(sortOrder))
-	case "price":
-		orderByClause = fmt.Sprintf("ORDER BY l.price %s", strings.ToUpper(sortOrder))
+	favoriteArg := argCounter
+	args = append(args, userID)
+	argCounter++
+
+	limitClause := fmt.Sprintf("LIMIT $%d", argCounter)
+	args = append(args, params.Limit+1)
+	argCounter++
+
+	if params.After != nil {
+		comparison := "<"
+		if sortOrder == "ASC" {
+			comparison = ">"
+		}
+
+		var afterValue any = params.After.CreatedAt
+		if params.SortBy == "price" {
+			afterValue = params.After.Price
+		}
+
+		keysetClause := fmt.Sprintf("(%s, l.id) %s ($%d, $%d)", sortColumn, comparison, argCounter, argCounter+1)
+		args = append(args, afterValue, params.After.ID)
+		whereClause += " AND " + keysetClause
+	} else {
+		limitClause += fmt.Sprintf(" OFFSET $%d", argCounter)
+		args = append(args, (params.Page-1)*params.Limit)
 	}
 
-	offset := (page - 1) * limit
+	orderByClause := fmt.Sprintf("ORDER BY %s %s, l.id %s", sortColumn, sortOrder, sortOrder)
 
 	mainQuery := fmt.Sprintf(`
 		SELECT
 			l.id,
-			l.user_id,
-			u.login AS author_login,
+			COALESCE(l.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
 			l.title,
 			l.description,
 			l.image_url,
 			l.price,
-			l.created_at
+			l.category_id,
+			l.attributes,
+			l.status,
+			l.status_changed_at,
+			l.created_at,
+			l.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = l.id) AS favorites_count,
+			EXISTS (SELECT 1 FROM favorites AS f WHERE f.listing_id = l.id AND f.user_id = $%d) AS is_favorite%s
 		FROM
 			listings AS l
-		JOIN
+		LEFT JOIN
 			users AS u ON l.user_id = u.id
 		%s
 		%s
-		LIMIT $%d OFFSET $%d;
-	`, whereClause, orderByClause, argCounter, argCounter+1)
-
-	queryArgs := make([]any, len(args))
-	copy(queryArgs, args)
-	queryArgs = append(queryArgs, limit, offset)
+		%s;
+	`, favoriteArg, searchColumns, whereClause, orderByClause, limitClause)
 
-	rows, err := storage.QueryWithTx(ctx, r.postgres, mainQuery, queryArgs...)
+	rows, err := storage.QueryWithTx(ctx, r.postgres, mainQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query listing feed: %w", err)
 	}
@@ -152,19 +202,20 @@ func (r *ListingRepo) GetFeed(ctx context.Context, userID int64, page, limit int
 	var listingsFeed models.ListingsFeed
 	for rows.Next() {
 		var listing models.Listing
-		err := rows.Scan(
-			&listing.ID,
-			&listing.UserID,
-			&listing.AuthorLogin,
-			&listing.Title,
-			&listing.Description,
-			&listing.ImageURL,
-			&listing.Price,
-			&listing.CreatedAt,
-		)
+		var err error
+		if filter.searchArg > 0 {
+			listing.Highlight = &models.ListingHighlight{}
+			err = scanListing(rows, &listing, &listing.IsFavorite, &listing.Relevance, &listing.Highlight.Title, &listing.Highlight.Description)
+		} else {
+			err = scanListing(rows, &listing, &listing.IsFavorite)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan listing row: %w", err)
 		}
+		if listing.Highlight != nil {
+			listing.Highlight.Title = highlightFragment(listing.Highlight.Title)
+			listing.Highlight.Description = highlightFragment(listing.Highlight.Description)
+		}
 		if userID > 0 {
 			listing.IsOwner = listing.UserID == userID
 		}
@@ -173,20 +224,544 @@ func (r *ListingRepo) GetFeed(ctx context.Context, userID int64, page, limit int
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
-	listingsFeed.Total = len(listingsFeed.Listings)
-	listingsFeed.Page = page
-	listingsFeed.Limit = limit
+	listingsFeed.Page = params.Page
+	listingsFeed.Limit = params.Limit
 
 	return &listingsFeed, nil
 }
 
-func (r *ListingRepo) CheckExists(ctx context.Context, id int64) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM listing WHERE id = $1)`
-	var exists bool
-	err := storage.QueryRowWithTx(ctx, r.postgres, query, id).Scan(&exists)
+// highlightReplacer turns the match delimiters of ts_headline, chr(2) and
+// chr(3), into <mark> tags.
+var highlightReplacer = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// highlightFragment prepares a ts_headline fragment for HTML. ts_headline
+// returns the listing text as is, so the text is escaped before the matches
+// are marked up.
+func highlightFragment(fragment string) string {
+	return highlightReplacer.Replace(html.EscapeString(fragment))
+}
+
+func (r *ListingRepo) CountFeed(ctx context.Context, params models.FeedParams) (int, error) {
+	filter := buildFeedFilter(params)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM
+			listings AS l
+		%s;
+	`, filter.where)
+
+	var total int
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, filter.args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count listing feed: %w", err)
+	}
+
+	return total, nil
+}
+
+// CountByStatus returns the number of listings of the user in every status,
+// including statuses without listings.
+func (r *ListingRepo) CountByStatus(ctx context.Context, userID int64) (map[string]int, error) {
+	query := `SELECT status, COUNT(*) FROM listings WHERE user_id = $1 GROUP BY status`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	counts := map[string]int{
+		models.ListingStatusDraft:    0,
+		models.ListingStatusActive:   0,
+		models.ListingStatusReserved: 0,
+		models.ListingStatusSold:     0,
+		models.ListingStatusArchived: 0,
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		counts[status] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return counts, nil
+}
+
+func (r *ListingRepo) GetByID(ctx context.Context, id, userID int64) (*models.Listing, error) {
+	query := `
+		SELECT
+			l.id,
+			COALESCE(l.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			l.title,
+			l.description,
+			l.image_url,
+			l.price,
+			l.category_id,
+			l.attributes,
+			l.status,
+			l.status_changed_at,
+			l.created_at,
+			l.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = l.id) AS favorites_count,
+			EXISTS (SELECT 1 FROM favorites AS f WHERE f.listing_id = l.id AND f.user_id = $2) AS is_favorite
+		FROM
+			listings AS l
+		LEFT JOIN
+			users AS u ON l.user_id = u.id
+		WHERE
+			l.id = $1;
+	`
+
+	var listing models.Listing
+	err := scanListing(storage.QueryRowWithTx(ctx, r.postgres, query, id, userID), &listing, &listing.IsFavorite)
+	if err != nil {
+		return nil, err
+	}
+	if userID > 0 {
+		listing.IsOwner = listing.UserID == userID
+	}
+
+	return &listing, nil
+}
+
+func (r *ListingRepo) GetForUpdate(ctx context.Context, id int64) (*models.Listing, error) {
+	query := `
+		SELECT
+			l.id,
+			COALESCE(l.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			l.title,
+			l.description,
+			l.image_url,
+			l.price,
+			l.category_id,
+			l.attributes,
+			l.status,
+			l.status_changed_at,
+			l.created_at,
+			l.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = l.id) AS favorites_count
+		FROM
+			listings AS l
+		LEFT JOIN
+			users AS u ON l.user_id = u.id
+		WHERE
+			l.id = $1
+		FOR UPDATE OF l;
+	`
+
+	var listing models.Listing
+	err := scanListing(storage.QueryRowWithTx(ctx, r.postgres, query, id), &listing)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listing, nil
+}
+
+func (r *ListingRepo) Update(ctx context.Context, listing *models.Listing) (*models.Listing, error) {
+	query := `
+		WITH updated_listing AS (
+			UPDATE listings
+			SET title = $2, description = $3, image_url = $4, price = $5, category_id = $6, attributes = $7, updated_at = NOW()
+			WHERE id = $1
+			RETURNING id, user_id, title, description, image_url, price, category_id, attributes, status, status_changed_at, created_at, updated_at
+		)
+		SELECT
+			ul.id,
+			COALESCE(ul.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			ul.title,
+			ul.description,
+			ul.image_url,
+			ul.price,
+			ul.category_id,
+			ul.attributes,
+			ul.status,
+			ul.status_changed_at,
+			ul.created_at,
+			ul.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = ul.id) AS favorites_count
+		FROM
+			updated_listing AS ul
+		LEFT JOIN
+			users AS u ON ul.user_id = u.id;
+	`
+
+	attributes, err := marshalAttributes(listing.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := models.Listing{IsOwner: listing.IsOwner}
+	row := storage.QueryRowWithTx(ctx, r.postgres, query,
+		listing.ID, listing.Title, listing.Description, listing.ImageURL, listing.Price, listing.CategoryID, attributes)
+
+	err = scanListing(row, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan updated listing: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func (r *ListingRepo) UpdateStatus(ctx context.Context, id int64, status string) (*models.Listing, error) {
+	query := `
+		WITH updated_listing AS (
+			UPDATE listings
+			SET status = $2, status_changed_at = NOW()
+			WHERE id = $1
+			RETURNING id, user_id, title, description, image_url, price, category_id, attributes, status, status_changed_at, created_at, updated_at
+		)
+		SELECT
+			ul.id,
+			COALESCE(ul.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			ul.title,
+			ul.description,
+			ul.image_url,
+			ul.price,
+			ul.category_id,
+			ul.attributes,
+			ul.status,
+			ul.status_changed_at,
+			ul.created_at,
+			ul.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = ul.id) AS favorites_count
+		FROM
+			updated_listing AS ul
+		LEFT JOIN
+			users AS u ON ul.user_id = u.id;
+	`
+
+	var updated models.Listing
+	err := scanListing(storage.QueryRowWithTx(ctx, r.postgres, query, id, status), &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan listing after status change: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func (r *ListingRepo) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM listings WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByUser returns every listing of the user regardless of its status,
+// oldest first.
+func (r *ListingRepo) GetByUser(ctx context.Context, userID int64) ([]models.Listing, error) {
+	query := `
+		SELECT
+			l.id,
+			COALESCE(l.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			l.title,
+			l.description,
+			l.image_url,
+			l.price,
+			l.category_id,
+			l.attributes,
+			l.status,
+			l.status_changed_at,
+			l.created_at,
+			l.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = l.id) AS favorites_count
+		FROM
+			listings AS l
+		LEFT JOIN
+			users AS u ON l.user_id = u.id
+		WHERE
+			l.user_id = $1
+		ORDER BY
+			l.created_at, l.id;
+	`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user listings: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	listings := []models.Listing{}
+	for rows.Next() {
+		listing := models.Listing{IsOwner: true}
+		if err := scanListing(rows, &listing); err != nil {
+			return nil, fmt.Errorf("failed to scan listing row: %w", err)
+		}
+		listings = append(listings, listing)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return listings, nil
+}
+
+// DeleteByUser deletes every listing of the user and returns their number.
+func (r *ListingRepo) DeleteByUser(ctx context.Context, userID int64) (int64, error) {
+	query := `DELETE FROM listings WHERE user_id = $1`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// AnonymizeByUser detaches the listings of the user from the account. Drafts
+// were never public and are deleted; listings that are still on sale are
+// archived. It returns the number of listings kept.
+func (r *ListingRepo) AnonymizeByUser(ctx context.Context, userID int64) (int64, error) {
+	query := `DELETE FROM listings WHERE user_id = $1 AND status = $2`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, models.ListingStatusDraft)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		UPDATE listings
+		SET
+			user_id = NULL,
+			status = CASE WHEN status IN ($2, $3) THEN $4 ELSE status END,
+			status_changed_at = CASE WHEN status IN ($2, $3) THEN NOW() ELSE status_changed_at END
+		WHERE user_id = $1
+	`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, userID,
+		models.ListingStatusActive, models.ListingStatusReserved, models.ListingStatusArchived)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// AddFavorite saves the listing to the favorites of the user. Adding a
+// favorite twice has no effect.
+func (r *ListingRepo) AddFavorite(ctx context.Context, userID, listingID int64) error {
+	query := `
+		INSERT INTO favorites (user_id, listing_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, listing_id) DO NOTHING
+	`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, listingID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *ListingRepo) RemoveFavorite(ctx context.Context, userID, listingID int64) error {
+	query := `DELETE FROM favorites WHERE user_id = $1 AND listing_id = $2`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, userID, listingID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetFavoritesByUser returns every favorite of the user regardless of the
+// status of the listing, newest first.
+func (r *ListingRepo) GetFavoritesByUser(ctx context.Context, userID int64) ([]models.Favorite, error) {
+	query := `SELECT listing_id, created_at FROM favorites WHERE user_id = $1 ORDER BY created_at DESC, listing_id DESC`
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query favorites: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	favorites := []models.Favorite{}
+	for rows.Next() {
+		var favorite models.Favorite
+		if err := rows.Scan(&favorite.ListingID, &favorite.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan favorite: %w", err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return favorites, nil
+}
+
+// ReindexSearch rebuilds the full-text search index and refreshes the planner
+// statistics of the listings table.
+func (r *ListingRepo) ReindexSearch(ctx context.Context) error {
+	_, err := r.postgres.ExecContext(ctx, `REINDEX INDEX idx_listings_search_vector`)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.postgres.ExecContext(ctx, `ANALYZE listings`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const searchConfig = "russian"
+
+type feedFilter struct {
+	where string
+	args  []any
+	// searchArg is the placeholder number of the search query, 0 if the feed
+	// is not filtered by a search query.
+	searchArg int
+}
+
+func buildFeedFilter(params models.FeedParams) feedFilter {
+	var whereClauses []string
+	var filter feedFilter
+	argCounter := 1
+
+	switch {
+	case params.AllStatuses:
+	case len(params.Statuses) > 0:
+		whereClauses = append(whereClauses, fmt.Sprintf("l.status = ANY($%d)", argCounter))
+		filter.args = append(filter.args, pq.Array(params.Statuses))
+		argCounter++
+	default:
+		status := params.Status
+		if status == "" {
+			status = models.ListingStatusActive
+		}
+		whereClauses = append(whereClauses, fmt.Sprintf("l.status = $%d", argCounter))
+		filter.args = append(filter.args, status)
+		argCounter++
+	}
+
+	if params.SellerID > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("l.user_id = $%d", argCounter))
+		filter.args = append(filter.args, params.SellerID)
+		argCounter++
+	}
+	if params.FavoritedBy > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("l.id IN (SELECT listing_id FROM favorites WHERE user_id = $%d)", argCounter))
+		filter.args = append(filter.args, params.FavoritedBy)
+		argCounter++
+	}
+	if params.MinPrice > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("l.price >= $%d", argCounter))
+		filter.args = append(filter.args, params.MinPrice)
+		argCounter++
+	}
+	if params.MaxPrice > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("l.price <= $%d", argCounter))
+		filter.args = append(filter.args, params.MaxPrice)
+		argCounter++
+	}
+	if params.CategoryID > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf(`l.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $%d
+				UNION ALL
+				SELECT c.id FROM categories AS c JOIN subtree AS st ON c.parent_id = st.id
+			)
+			SELECT id FROM subtree
+		)`, argCounter))
+		filter.args = append(filter.args, params.CategoryID)
+		argCounter++
+	}
+	for _, attribute := range params.Attributes {
+		switch attribute.Op {
+		case models.AttributeFilterGte, models.AttributeFilterLte:
+			comparison := ">="
+			if attribute.Op == models.AttributeFilterLte {
+				comparison = "<="
+			}
+			whereClauses = append(whereClauses, fmt.Sprintf(
+				"(CASE WHEN jsonb_typeof(l.attributes -> $%[1]d::text) = 'number' THEN (l.attributes ->> $%[1]d::text)::numeric END) %[2]s $%[3]d::numeric",
+				argCounter, comparison, argCounter+1))
+		default:
+			whereClauses = append(whereClauses, fmt.Sprintf("l.attributes ->> $%d::text = $%d", argCounter, argCounter+1))
+		}
+		filter.args = append(filter.args, attribute.Key, attribute.Value)
+		argCounter += 2
+	}
+	if params.Query != "" {
+		whereClauses = append(whereClauses,
+			fmt.Sprintf("l.search_vector @@ websearch_to_tsquery('%s', $%d)", searchConfig, argCounter))
+		filter.args = append(filter.args, params.Query)
+		filter.searchArg = argCounter
+	}
+
+	filter.where = "WHERE " + strings.Join(whereClauses, " AND ")
+
+	return filter
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanListing scans the common listing columns followed by any extra columns
+// selected by the query.
+func scanListing(row rowScanner, listing *models.Listing, extra ...any) error {
+	var attributes []byte
+	dest := []any{
+		&listing.ID,
+		&listing.UserID,
+		&listing.AuthorLogin,
+		&listing.Title,
+		&listing.Description,
+		&listing.ImageURL,
+		&listing.Price,
+		&listing.CategoryID,
+		&attributes,
+		&listing.Status,
+		&listing.StatusChangedAt,
+		&listing.CreatedAt,
+		&listing.UpdatedAt,
+		&listing.FavoritesCount,
+	}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(attributes, &listing.Attributes)
+}
+
+// marshalAttributes encodes attributes for a JSONB parameter. The result is a
+// string because lib/pq sends []byte parameters as bytea.
+func marshalAttributes(attributes map[string]any) (string, error) {
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+
+	data, err := json.Marshal(attributes)
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("failed to marshal listing attributes: %w", err)
 	}
 
-	return exists, nil
+	return string(data), nil
 }
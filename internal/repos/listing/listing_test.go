@@ -0,0 +1,22 @@
+package listing
+
+import "testing"
+
+func TestHighlightFragment(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     string
+	}{
+		{"plain \x02bike\x03 for sale", "plain <mark>bike</mark> for sale"},
+		{"<script>alert(1)</script> \x02bike\x03", "&lt;script&gt;alert(1)&lt;/script&gt; <mark>bike</mark>"},
+		{"\x02<b>\x03 & \"quotes\"", "<mark>&lt;b&gt;</mark> &amp; &#34;quotes&#34;"},
+		{"<mark>fake</mark>", "&lt;mark&gt;fake&lt;/mark&gt;"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := highlightFragment(tt.fragment); got != tt.want {
+			t.Errorf("highlightFragment(%q) = %q, want %q", tt.fragment, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package listing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+const savedSearchColumns = `id, user_id, name, query, created_at, last_checked_at`
+
+func (r *ListingRepo) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) (*models.SavedSearch, error) {
+	query := `
+		INSERT INTO saved_searches (user_id, name, query)
+		VALUES ($1, $2, $3)
+		RETURNING ` + savedSearchColumns
+
+	feedQuery, err := json.Marshal(search.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal saved search query: %w", err)
+	}
+
+	var created models.SavedSearch
+	row := storage.QueryRowWithTx(ctx, r.postgres, query, search.UserID, search.Name, string(feedQuery))
+	if err := scanSavedSearch(row, &created); err != nil {
+		return nil, fmt.Errorf("failed to scan created saved search: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetSavedSearchesByUser returns the saved searches of the user, oldest
+// first.
+func (r *ListingRepo) GetSavedSearchesByUser(ctx context.Context, userID int64) ([]models.SavedSearch, error) {
+	query := `SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE user_id = $1 ORDER BY created_at, id`
+	return r.querySavedSearches(ctx, query, userID)
+}
+
+func (r *ListingRepo) CountSavedSearches(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM saved_searches WHERE user_id = $1`
+
+	var count int
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count saved searches: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteSavedSearch deletes a saved search of the user and reports whether it
+// existed.
+func (r *ListingRepo) DeleteSavedSearch(ctx context.Context, userID, id int64) (bool, error) {
+	query := `DELETE FROM saved_searches WHERE id = $1 AND user_id = $2`
+	result, err := storage.ExecWithTx(ctx, r.postgres, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// GetSavedSearches returns the saved searches of all users.
+func (r *ListingRepo) GetSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	query := `SELECT ` + savedSearchColumns + ` FROM saved_searches ORDER BY id`
+	return r.querySavedSearches(ctx, query)
+}
+
+// ClaimSavedSearch moves the last check of a saved search to the current time
+// and returns it. It returns sql.ErrNoRows if the search has been checked
+// since lastCheckedAt was read, so that concurrent checks of the same search
+// do not notify twice.
+func (r *ListingRepo) ClaimSavedSearch(ctx context.Context, id int64, lastCheckedAt time.Time) (time.Time, error) {
+	query := `
+		UPDATE saved_searches
+		SET last_checked_at = NOW()
+		WHERE id = $1 AND last_checked_at = $2
+		RETURNING last_checked_at
+	`
+
+	var checkedAt time.Time
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, id, lastCheckedAt).Scan(&checkedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return checkedAt, nil
+}
+
+// RecordSavedSearchMatches records the active listings of other users that
+// became active in (since, until] and match the query of the saved search,
+// skipping the listings it has already matched. It returns up to limit of the
+// newly matched listings, in the order of the query, and their total number.
+func (r *ListingRepo) RecordSavedSearchMatches(ctx context.Context, search models.SavedSearch, since, until time.Time, limit int) ([]models.Listing, int, error) {
+	params := search.Query
+	params.Status = models.ListingStatusActive
+
+	filter := buildFeedFilter(params)
+	args := filter.args
+	argCounter := len(args) + 1
+
+	whereClause := filter.where + fmt.Sprintf(
+		" AND l.status_changed_at > $%d AND l.status_changed_at <= $%d AND l.user_id IS DISTINCT FROM $%d",
+		argCounter, argCounter+1, argCounter+2)
+	args = append(args, since, until, search.UserID)
+	argCounter += 3
+	searchArg := argCounter
+	args = append(args, search.ID)
+	argCounter++
+
+	sortColumn := "l.created_at"
+	switch params.SortBy {
+	case "price":
+		sortColumn = "l.price"
+	case "relevance":
+		if filter.searchArg > 0 {
+			sortColumn = fmt.Sprintf("ts_rank(l.search_vector, websearch_to_tsquery('%s', $%d))", searchConfig, filter.searchArg)
+		}
+	}
+	sortOrder := "DESC"
+	if params.SortOrder == "asc" {
+		sortOrder = "ASC"
+	}
+
+	query := fmt.Sprintf(`
+		WITH new_matches AS (
+			INSERT INTO saved_search_matches (saved_search_id, listing_id)
+			SELECT $%d, l.id FROM listings AS l %s
+			ON CONFLICT DO NOTHING
+			RETURNING listing_id
+		)
+		SELECT
+			l.id,
+			COALESCE(l.user_id, 0),
+			COALESCE(u.login, '') AS author_login,
+			l.title,
+			l.description,
+			l.image_url,
+			l.price,
+			l.category_id,
+			l.attributes,
+			l.status,
+			l.status_changed_at,
+			l.created_at,
+			l.updated_at,
+			(SELECT COUNT(*) FROM favorites AS f WHERE f.listing_id = l.id) AS favorites_count,
+			COUNT(*) OVER () AS total
+		FROM
+			new_matches AS nm
+		JOIN
+			listings AS l ON l.id = nm.listing_id
+		LEFT JOIN
+			users AS u ON l.user_id = u.id
+		ORDER BY %s %s, l.id %s
+		LIMIT $%d;
+	`, searchArg, whereClause, sortColumn, sortOrder, sortOrder, argCounter)
+	args = append(args, limit)
+
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to record saved search matches: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	listings := []models.Listing{}
+	total := 0
+	for rows.Next() {
+		var listing models.Listing
+		if err := scanListing(rows, &listing, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan listing row: %w", err)
+		}
+		listings = append(listings, listing)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return listings, total, nil
+}
+
+// DeleteSavedSearchMatches forgets the listings the saved search matched
+// before the time. Listings matched then became active before it as well, so
+// checks starting after it do not match them again.
+func (r *ListingRepo) DeleteSavedSearchMatches(ctx context.Context, id int64, before time.Time) error {
+	query := `DELETE FROM saved_search_matches WHERE saved_search_id = $1 AND matched_at < $2`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, id, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search matches: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ListingRepo) querySavedSearches(ctx context.Context, query string, args ...any) ([]models.SavedSearch, error) {
+	rows, err := storage.QueryWithTx(ctx, r.postgres, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.log.Error("Failed to close rows", utils.ErrLog(err))
+		}
+	}()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var search models.SavedSearch
+		if err := scanSavedSearch(rows, &search); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		searches = append(searches, search)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return searches, nil
+}
+
+func scanSavedSearch(row rowScanner, search *models.SavedSearch) error {
+	var query []byte
+	err := row.Scan(&search.ID, &search.UserID, &search.Name, &query, &search.CreatedAt, &search.LastCheckedAt)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(query, &search.Query)
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+const profileQuery = `
+	SELECT u.id, u.login, u.display_name, u.bio, u.avatar_url, u.created_at,
+		(SELECT COUNT(*) FROM listings AS l WHERE l.user_id = u.id AND l.status = 'active')
+	FROM users AS u
+`
+
+func (r *UserRepo) GetProfileByID(ctx context.Context, id int64) (*models.UserProfile, error) {
+	return r.getProfile(ctx, profileQuery+`WHERE u.id = $1`, id)
+}
+
+func (r *UserRepo) GetProfileByLogin(ctx context.Context, login string) (*models.UserProfile, error) {
+	return r.getProfile(ctx, profileQuery+`WHERE u.login = $1`, login)
+}
+
+func (r *UserRepo) getProfile(ctx context.Context, query string, arg any) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, arg).Scan(
+		&profile.ID,
+		&profile.Login,
+		&profile.DisplayName,
+		&profile.Bio,
+		&profile.AvatarURL,
+		&profile.MemberSince,
+		&profile.ActiveListings,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+const currentUserColumns = `id, login, COALESCE(email, ''), role, created_at, display_name, bio, avatar_url, contact_preferences`
+
+func (r *UserRepo) GetCurrent(ctx context.Context, id int64) (*models.CurrentUser, error) {
+	query := `SELECT ` + currentUserColumns + ` FROM users WHERE id = $1`
+	return scanCurrentUser(storage.QueryRowWithTx(ctx, r.postgres, query, id))
+}
+
+// UpdateProfile changes the non-nil fields of the update in a single
+// statement and returns the updated account.
+func (r *UserRepo) UpdateProfile(ctx context.Context, id int64, update models.ProfileUpdate) (*models.CurrentUser, error) {
+	query := `
+		UPDATE users
+		SET display_name = COALESCE($2, display_name),
+			bio = COALESCE($3, bio),
+			avatar_url = COALESCE($4, avatar_url),
+			contact_preferences = COALESCE($5::jsonb, contact_preferences)
+		WHERE id = $1
+		RETURNING ` + currentUserColumns
+
+	// A nil interface is sent as NULL, which keeps the stored preferences.
+	var contactPreferences any
+	if update.ContactPreferences != nil {
+		encoded, err := json.Marshal(update.ContactPreferences)
+		if err != nil {
+			return nil, err
+		}
+		contactPreferences = string(encoded)
+	}
+
+	return scanCurrentUser(storage.QueryRowWithTx(ctx, r.postgres, query,
+		id, update.DisplayName, update.Bio, update.AvatarURL, contactPreferences,
+	))
+}
+
+func scanCurrentUser(row *sql.Row) (*models.CurrentUser, error) {
+	var user models.CurrentUser
+	var contactPreferences []byte
+	err := row.Scan(
+		&user.ID,
+		&user.Login,
+		&user.Email,
+		&user.Role,
+		&user.CreatedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&contactPreferences,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(contactPreferences, &user.ContactPreferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode contact preferences: %w", err)
+	}
+
+	return &user, nil
+}
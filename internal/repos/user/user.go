@@ -9,10 +9,19 @@ import (
 )
 
 type UserRepoInterface interface {
-	Create(ctx context.Context, login, passwordHash string) (*models.UserPublic, error)
+	Create(ctx context.Context, login, email, passwordHash, role string) (*models.UserPublic, error)
 	GetByLogin(ctx context.Context, login string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByID(ctx context.Context, id int64) (*models.User, error)
 	CheckExists(ctx context.Context, login string) (bool, error)
+	CheckEmailExists(ctx context.Context, email string) (bool, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	Delete(ctx context.Context, id int64) error
+	GetProfileByID(ctx context.Context, id int64) (*models.UserProfile, error)
+	GetProfileByLogin(ctx context.Context, login string) (*models.UserProfile, error)
+	GetCurrent(ctx context.Context, id int64) (*models.CurrentUser, error)
+	UpdateProfile(ctx context.Context, id int64, update models.ProfileUpdate) (*models.CurrentUser, error)
 }
 
 type UserRepo struct {
@@ -23,16 +32,16 @@ func New(postgres *sql.DB) UserRepoInterface {
 	return &UserRepo{postgres: postgres}
 }
 
-func (r *UserRepo) Create(ctx context.Context, login, passwordHash string) (*models.UserPublic, error) {
+func (r *UserRepo) Create(ctx context.Context, login, email, passwordHash, role string) (*models.UserPublic, error) {
 	query := `
-		INSERT INTO users (login, password_hash) 
-		VALUES ($1, $2)
-		RETURNING id, login, created_at
+		INSERT INTO users (login, email, password_hash, role) 
+		VALUES ($1, NULLIF($2, ''), $3, $4)
+		RETURNING id, login, role, created_at
 	`
 
 	var user models.UserPublic
-	row := storage.QueryRowWithTx(ctx, r.postgres, query, login, passwordHash)
-	if err := row.Scan(&user.ID, &user.Login, &user.CreatedAt); err != nil {
+	row := storage.QueryRowWithTx(ctx, r.postgres, query, login, email, passwordHash, role)
+	if err := row.Scan(&user.ID, &user.Login, &user.Role, &user.CreatedAt); err != nil {
 		return nil, err
 	}
 
@@ -41,13 +50,29 @@ func (r *UserRepo) Create(ctx context.Context, login, passwordHash string) (*mod
 
 func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at
+		SELECT id, login, COALESCE(email, ''), password_hash, role, created_at
 		FROM users
 		WHERE login = $1
 	`
 
 	var user models.User
-	err := storage.QueryRowWithTx(ctx, r.postgres, query, login).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, login).Scan(&user.ID, &user.Login, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `
+		SELECT id, login, COALESCE(email, ''), password_hash, role, created_at
+		FROM users
+		WHERE LOWER(email) = LOWER($1)
+	`
+
+	var user models.User
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, email).Scan(&user.ID, &user.Login, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -57,13 +82,13 @@ func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User,
 
 func (r *UserRepo) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at
+		SELECT id, login, COALESCE(email, ''), password_hash, role, created_at
 		FROM users
 		WHERE id = $1
 	`
 
 	var user models.User
-	err := storage.QueryRowWithTx(ctx, r.postgres, query, id).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, id).Scan(&user.ID, &user.Login, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -81,3 +106,46 @@ func (r *UserRepo) CheckExists(ctx context.Context, login string) (bool, error)
 
 	return exists, nil
 }
+
+func (r *UserRepo) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))`
+	var exists bool
+	err := storage.QueryRowWithTx(ctx, r.postgres, query, email).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2 WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	query := `UPDATE users SET role = $2 WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, id, role)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete deletes the user; the data of the account is deleted with it by the
+// foreign keys.
+func (r *UserRepo) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM users WHERE id = $1`
+	_, err := storage.ExecWithTx(ctx, r.postgres, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/repos/audit"
+)
+
+type AuditServiceInterface interface {
+	Record(ctx context.Context, entry models.AuditEntry) error
+}
+
+type AuditService struct {
+	auditRepo audit.AuditRepoInterface
+}
+
+func New(auditRepo audit.AuditRepoInterface) AuditServiceInterface {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record stores the entry in the audit log. Inside a transaction the entry is
+// only kept if the transaction commits.
+func (s *AuditService) Record(ctx context.Context, entry models.AuditEntry) error {
+	return s.auditRepo.Create(ctx, entry)
+}
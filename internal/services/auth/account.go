@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/services/user"
+	"github.com/ocenb/marketplace/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DeleteAccount deletes the account of the user after re-authentication. The
+// password is required, together with a TOTP or recovery code if two-factor
+// authentication is enabled; wrong passwords and codes count as failed logins.
+// Accounts created through OIDC have no password to re-enter, they confirm the
+// deletion with a session started by a login within the re-authentication
+// window instead.
+//
+// The sessions are revoked before the account is deleted, so that access
+// tokens validated without the database are rejected as well. The listings
+// are deleted or anonymized according to the configured policy; the rest of
+// the account data is deleted together with the user.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID, sessionID int64, password, code string, client models.ClientInfo) error {
+	var listings int64
+
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		user, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		passwordless, err := s.isPasswordless(txCtx, user)
+		if err != nil {
+			return err
+		}
+		if passwordless {
+			recent, err := s.isRecentLogin(txCtx, sessionID)
+			if err != nil {
+				return err
+			}
+			if !recent {
+				return ErrReauthenticationRequired
+			}
+		} else {
+			err = s.checkPasswordAndCode(txCtx, user, password, code, client)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = s.authRepo.RevokeUserSessions(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		listings, err = s.listingService.RemoveUserListings(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = s.userService.Delete(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		// The entry is not linked to the deleted account, the ID is kept in
+		// the details only.
+		return s.auditService.Record(txCtx, models.AuditEntry{
+			Event: models.AuditEventAccountDeleted,
+			Details: map[string]any{
+				"user_id":        userID,
+				"listing_policy": s.cfg.Account.DeletionListingPolicy,
+				"listings":       listings,
+			},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Account deleted",
+		slog.Int64("user_id", userID),
+		slog.String("listing_policy", s.cfg.Account.DeletionListingPolicy),
+		slog.Int64("listings", listings),
+	)
+	return nil
+}
+
+// checkPasswordAndCode re-authenticates the user with the password and, if
+// two-factor authentication is enabled, a TOTP or recovery code. It must run in
+// a transaction of withTransaction, so that the failures are committed.
+func (s *AuthService) checkPasswordAndCode(ctx context.Context, user *models.User, password, code string, client models.ClientInfo) error {
+	if password == "" {
+		return ErrReauthenticationRequired
+	}
+
+	err := s.checkLoginThrottle(ctx, user.Login, client.IP)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return s.rejectLogin(ctx, ErrWrongPassword, user.Login, &user.ID, client.IP)
+	}
+
+	twoFactor, err := s.authRepo.IsTOTPEnabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor {
+		return nil
+	}
+
+	ok, err := s.verifySecondFactor(ctx, user.ID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.rejectLogin(ctx, ErrInvalidTwoFactorCode, user.Login, &user.ID, client.IP)
+	}
+
+	return nil
+}
+
+// isPasswordless reports whether the account was created through OIDC and has
+// not got a password since.
+func (s *AuthService) isPasswordless(ctx context.Context, user *models.User) (bool, error) {
+	if user.PasswordHash != "" {
+		return false, nil
+	}
+
+	identities, err := s.authRepo.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(identities) > 0, nil
+}
+
+// isRecentLogin reports whether the session was started by a login within the
+// re-authentication window.
+func (s *AuthService) isRecentLogin(ctx context.Context, sessionID int64) (bool, error) {
+	session, err := s.authRepo.GetActiveSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return time.Since(session.CreatedAt) <= s.cfg.Account.ReauthWindow, nil
+}
+
+// ExportAccount collects the personal data stored about the user. The data is
+// read in a single snapshot, so that its parts are consistent with each other.
+func (s *AuthService) ExportAccount(ctx context.Context, userID int64) (*models.AccountExport, error) {
+	export := models.AccountExport{ExportedAt: time.Now().UTC()}
+
+	txOpts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := storage.WithTransactionOptions(ctx, s.authRepo, txOpts, func(txCtx context.Context) error {
+		profile, err := s.userService.GetCurrent(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, user.ErrUserNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		export.Profile = *profile
+
+		export.TwoFactorEnabled, err = s.authRepo.IsTOTPEnabled(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.Listings, err = s.listingService.GetUserListings(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.Favorites, err = s.listingService.GetUserFavorites(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.SavedSearches, err = s.listingService.GetSavedSearches(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.Sessions, err = s.authRepo.GetUserSessions(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.APIKeys, err = s.authRepo.GetActiveAPIKeys(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		export.Identities, err = s.authRepo.GetUserIdentities(txCtx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("Account data exported", slog.Int64("user_id", userID))
+	return &export, nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/repos/auth"
+)
+
+// identityRepo serves the external identities of a user; the other repository
+// methods are not used by isPasswordless.
+type identityRepo struct {
+	auth.AuthRepoInterface
+	identities []models.UserIdentity
+}
+
+func (r *identityRepo) GetUserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	return r.identities, nil
+}
+
+func TestIsPasswordless(t *testing.T) {
+	identity := []models.UserIdentity{{UserID: 1}}
+
+	tests := []struct {
+		name         string
+		passwordHash string
+		identities   []models.UserIdentity
+		want         bool
+	}{
+		{"OIDC account", "", identity, true},
+		{"OIDC account with a password set by a reset", "$2a$12$hash", identity, false},
+		{"password account", "$2a$12$hash", nil, false},
+		// Such an account cannot be re-authenticated at all, it must not be
+		// deletable by any fresh session either.
+		{"no password and no identity", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &AuthService{authRepo: &identityRepo{identities: tt.identities}}
+
+			got, err := s.isPasswordless(context.Background(), &models.User{ID: 1, PasswordHash: tt.passwordHash})
+			if err != nil {
+				t.Fatalf("isPasswordless() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isPasswordless() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
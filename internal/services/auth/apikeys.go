@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// API keys look like "mk_<prefix>_<secret>". The prefix is stored in plain
+// text to find the key, only a hash of the whole key is stored.
+const (
+	apiKeyTag         = "mk_"
+	apiKeyPrefixBytes = 4
+	apiKeyPrefixLen   = len(apiKeyTag) + 2*apiKeyPrefixBytes
+)
+
+// CreateAPIKey creates a key for the user and returns it together with the
+// key itself, which is not stored and cannot be shown again. Without scopes
+// the key is granted every scope.
+func (s *AuthService) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	if len(scopes) == 0 {
+		scopes = models.APIKeyScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiry
+	}
+
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", err
+	}
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix := apiKeyTag + hex.EncodeToString(prefixBytes)
+	rawKey := prefix + "_" + secret
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt: expiresAt,
+	}
+
+	err = s.authRepo.CreateAPIKey(ctx, key, hashOpaqueToken(rawKey))
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.log.Info("API key created", slog.Int64("user_id", userID), slog.String("prefix", prefix))
+	return key, rawKey, nil
+}
+
+func (s *AuthService) GetAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	return s.authRepo.GetActiveAPIKeys(ctx, userID)
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	revoked, err := s.authRepo.RevokeAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	s.log.Info("API key revoked", slog.Int64("user_id", userID), slog.Int64("key_id", keyID))
+	return nil
+}
+
+// ValidateAPIKey authenticates a request made with an API key. The claims carry
+// the current role of the owner and the scopes of the key.
+func (s *AuthService) ValidateAPIKey(ctx context.Context, rawKey string) (*models.TokenClaims, error) {
+	if len(rawKey) <= apiKeyPrefixLen || !strings.HasPrefix(rawKey, apiKeyTag) || rawKey[apiKeyPrefixLen] != '_' {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.authRepo.GetAPIKeyByPrefix(ctx, rawKey[:apiKeyPrefixLen])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOpaqueToken(rawKey)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		s.log.Info("API key validation failed: key revoked or expired", slog.Int64("key_id", key.ID))
+		return nil, ErrInvalidAPIKey
+	}
+
+	err = s.authRepo.TouchAPIKey(ctx, key.ID, sessionTouchInterval)
+	if err != nil {
+		s.log.Error("Failed to update API key activity", slog.Int64("key_id", key.ID), utils.ErrLog(err))
+	}
+
+	return &models.TokenClaims{
+		UserID: key.UserID,
+		Role:   key.UserRole,
+		Scopes: key.Scopes,
+	}, nil
+}
@@ -5,12 +5,18 @@ import (
 	"database/sql"
 	"errors"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/ocenb/marketplace/internal/config"
+	"github.com/ocenb/marketplace/internal/jwtkeys"
+	"github.com/ocenb/marketplace/internal/mailer"
 	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/oidc"
 	"github.com/ocenb/marketplace/internal/repos/auth"
+	"github.com/ocenb/marketplace/internal/services/audit"
+	"github.com/ocenb/marketplace/internal/services/listing"
 	"github.com/ocenb/marketplace/internal/services/user"
 	"github.com/ocenb/marketplace/internal/storage"
 	"github.com/ocenb/marketplace/internal/utils"
@@ -18,10 +24,33 @@ import (
 )
 
 type AuthServiceInterface interface {
-	Register(ctx context.Context, login, password string) (*models.UserPublic, error)
-	Login(ctx context.Context, login, password string) (*models.UserPublic, string, error)
-	ValidateToken(ctx context.Context, token string) (int64, error)
-	CleanupExpiredTokens()
+	Register(ctx context.Context, login, email, password, role string) (*models.UserPublic, error)
+	Login(ctx context.Context, login, password string, client models.ClientInfo) (*models.LoginResult, error)
+	CompleteLogin(ctx context.Context, challengeToken, code string, client models.ClientInfo) (*models.LoginResult, error)
+	StartOIDCLogin(ctx context.Context) (string, error)
+	CompleteOIDCLogin(ctx context.Context, code, state string, client models.ClientInfo) (*models.LoginResult, error)
+	Refresh(ctx context.Context, refreshToken string, client models.ClientInfo) (*models.TokenPair, error)
+	ValidateToken(ctx context.Context, token string) (*models.TokenClaims, error)
+	JWKS() jwtkeys.JWKS
+	Logout(ctx context.Context, sessionID int64) error
+	GetSessions(ctx context.Context, userID, currentSessionID int64) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID int64) error
+	RevokeOtherSessions(ctx context.Context, userID, currentSessionID int64) (int64, error)
+	SetupTOTP(ctx context.Context, userID int64) (*models.TOTPSetup, error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, password, code string, client models.ClientInfo) error
+	ChangePassword(ctx context.Context, userID, currentSessionID int64, currentPassword, newPassword string, client models.ClientInfo) error
+	RequestPasswordReset(ctx context.Context, email string, client models.ClientInfo) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	RevokeUserTokens(ctx context.Context, userID int64) (int64, error)
+	ChangeRole(ctx context.Context, actorID, userID int64, role string) (*models.UserPublic, error)
+	CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, userID int64) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int64) error
+	ValidateAPIKey(ctx context.Context, key string) (*models.TokenClaims, error)
+	DeleteAccount(ctx context.Context, userID, sessionID int64, password, code string, client models.ClientInfo) error
+	ExportAccount(ctx context.Context, userID int64) (*models.AccountExport, error)
+	CleanupExpiredTokens(ctx context.Context) (int64, error)
 }
 
 var (
@@ -29,25 +58,82 @@ var (
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrUserAlreadyExists  = errors.New("user with this login already exists")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrEmailAlreadyExists = errors.New("user with this email already exists")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used, session revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+	ErrReauthenticationRequired = errors.New("confirm with the password or, for an account without one, a fresh login")
+
+	ErrTOTPAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotSetUp         = errors.New("two-factor authentication is not set up")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+	ErrInvalidChallenge     = errors.New("invalid or expired login challenge")
+
+	ErrLoginThrottled         = errors.New("too many failed login attempts, try again later")
+	ErrPasswordResetThrottled = errors.New("too many password reset requests, try again later")
+
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrCannotChangeOwnRole = errors.New("administrators cannot change their own role")
+
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("invalid api key scope")
+	ErrInvalidExpiry  = errors.New("expiration time must be in the future")
+
+	ErrOIDCNotConfigured = errors.New("oidc login is not configured")
+	ErrInvalidOIDCState  = errors.New("invalid or expired oidc login state")
+	ErrOIDCLoginFailed   = errors.New("oidc login failed")
 )
 
+// sessionTouchInterval limits how often last_used_at of a session is updated
+// while validating its access tokens.
+const sessionTouchInterval = time.Minute
+
 type AuthService struct {
-	cfg         *config.Config
-	log         *slog.Logger
-	authRepo    auth.AuthRepoInterface
-	userService user.UserServiceInterface
+	cfg            *config.Config
+	log            *slog.Logger
+	authRepo       auth.AuthRepoInterface
+	userService    user.UserServiceInterface
+	listingService listing.ListingServiceInterface
+	auditService   audit.AuditServiceInterface
+	mailer         mailer.Mailer
+	keyRing        *jwtkeys.KeyRing
+	revocations    *RevocationCache
+	oidc           *oidc.Client
 }
 
-func New(cfg *config.Config, log *slog.Logger, authRepo auth.AuthRepoInterface, userService user.UserServiceInterface) AuthServiceInterface {
+func New(
+	cfg *config.Config,
+	log *slog.Logger,
+	authRepo auth.AuthRepoInterface,
+	userService user.UserServiceInterface,
+	listingService listing.ListingServiceInterface,
+	auditService audit.AuditServiceInterface,
+	mailer mailer.Mailer,
+	keyRing *jwtkeys.KeyRing,
+	revocations *RevocationCache,
+	oidcClient *oidc.Client,
+) AuthServiceInterface {
 	return &AuthService{
-		cfg:         cfg,
-		log:         log,
-		authRepo:    authRepo,
-		userService: userService,
+		cfg:            cfg,
+		log:            log,
+		authRepo:       authRepo,
+		userService:    userService,
+		listingService: listingService,
+		auditService:   auditService,
+		mailer:         mailer,
+		keyRing:        keyRing,
+		revocations:    revocations,
+		oidc:           oidcClient,
 	}
 }
 
-func (s *AuthService) Register(ctx context.Context, login, password string) (*models.UserPublic, error) {
+func (s *AuthService) Register(ctx context.Context, login, email, password, role string) (*models.UserPublic, error) {
 	var newUser *models.UserPublic
 
 	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
@@ -59,12 +145,22 @@ func (s *AuthService) Register(ctx context.Context, login, password string) (*mo
 			return ErrUserAlreadyExists
 		}
 
+		if email != "" {
+			exists, err = s.userService.CheckEmailExists(txCtx, email)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrEmailAlreadyExists
+			}
+		}
+
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.cfg.JWT.BCryptCost)
 		if err != nil {
 			return err
 		}
 
-		newUser, err = s.userService.Create(txCtx, login, string(hashedPassword))
+		newUser, err = s.userService.Create(txCtx, login, email, string(hashedPassword), role)
 		if err != nil {
 			return err
 		}
@@ -78,120 +174,472 @@ func (s *AuthService) Register(ctx context.Context, login, password string) (*mo
 	return newUser, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, login, password string) (*models.UserPublic, string, error) {
-	var token string
-	var existingUser *models.User
+// Login checks the credentials and starts a session. For accounts with
+// two-factor authentication only a login challenge is created; the session is
+// started by CompleteLogin. Failed attempts are throttled per login and per
+// client IP.
+func (s *AuthService) Login(ctx context.Context, login, password string, client models.ClientInfo) (*models.LoginResult, error) {
+	var result *models.LoginResult
+
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		err := s.checkLoginThrottle(txCtx, login, client.IP)
+		if err != nil {
+			return err
+		}
 
-	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
 		user, err := s.userService.GetByLogin(txCtx, login)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return ErrUserNotFound
+				return s.rejectLogin(txCtx, ErrUserNotFound, login, nil, client.IP)
 			}
 			return err
 		}
 
 		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 		if err != nil {
-			return ErrInvalidCredentials
+			return s.rejectLogin(txCtx, ErrInvalidCredentials, login, &user.ID, client.IP)
 		}
 
-		token, err = s.createToken(txCtx, user.ID)
+		result, err = s.startLogin(txCtx, &models.UserPublic{
+			ID:        user.ID,
+			Login:     user.Login,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+		}, client)
 		if err != nil {
 			return err
 		}
 
-		existingUser = user
-
-		return nil
+		// With two-factor authentication the login is not complete yet; the
+		// failures are kept until the code is accepted, so that codes cannot
+		// be guessed with a fresh challenge after each correct password.
+		if result.ChallengeToken != "" {
+			return nil
+		}
+		return s.authRepo.ClearLoginFailures(txCtx, throttleScopeLogin, throttleKey(login))
 	})
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	return &models.UserPublic{
-		ID:        existingUser.ID,
-		Login:     existingUser.Login,
-		CreatedAt: existingUser.CreatedAt,
-	}, token, nil
+	return result, nil
 }
 
-func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (int64, error) {
-	s.log.Debug("Validating token")
+// startLogin starts a session for an authenticated user, or creates a login
+// challenge if the user has two-factor authentication enabled.
+func (s *AuthService) startLogin(ctx context.Context, user *models.UserPublic, client models.ClientInfo) (*models.LoginResult, error) {
+	result := &models.LoginResult{User: user}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			s.log.Error("Invalid token signing method", slog.String("method", token.Method.Alg()))
-			return nil, ErrInvalidToken
+	twoFactor, err := s.authRepo.IsTOTPEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor {
+		result.ChallengeToken, result.ChallengeExpiresAt, err = s.createLoginChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	sessionID, err := s.authRepo.CreateSession(ctx, user.ID, client, time.Now().Add(s.cfg.JWT.RefreshTokenLiveTime))
+	if err != nil {
+		return nil, err
+	}
+
+	result.Tokens, err = s.issueTokens(ctx, user.ID, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once; presenting an already used one means it has leaked, so the
+// whole session it belongs to is revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, client models.ClientInfo) (*models.TokenPair, error) {
+	var tokens *models.TokenPair
+	var stored *models.RefreshToken
+
+	tokenHash := hashOpaqueToken(refreshToken)
+
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		var err error
+		stored, err = s.authRepo.GetRefreshTokenForUpdate(txCtx, tokenHash)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if stored.SessionRevokedAt != nil {
+			return ErrInvalidRefreshToken
 		}
-		return []byte(s.cfg.JWT.JWTSecret), nil
+
+		if stored.UsedAt != nil {
+			err = s.authRepo.RevokeSession(txCtx, stored.SessionID)
+			if err != nil {
+				return err
+			}
+			return &committedError{err: ErrRefreshTokenReused}
+		}
+
+		if time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		err = s.authRepo.MarkRefreshTokenUsed(txCtx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		err = s.authRepo.UpdateSessionClient(txCtx, stored.SessionID, client)
+		if err != nil {
+			return err
+		}
+
+		// The role is read again so that role changes apply from the next
+		// refresh.
+		user, err := s.userService.GetByID(txCtx, stored.UserID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.issueTokens(txCtx, user.ID, user.Role, stored.SessionID)
+		return err
 	})
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			s.log.Warn("Refresh token reuse detected, session revoked",
+				slog.Int64("user_id", stored.UserID),
+				slog.Int64("session_id", stored.SessionID),
+			)
+		}
+		return nil, err
+	}
+
+	s.log.Info("Tokens refreshed", slog.Int64("user_id", stored.UserID), slog.Int64("session_id", stored.SessionID))
+	return tokens, nil
+}
+
+// ValidateToken checks the signature and claims of an access token. In the
+// stateful mode the token must also be stored in the database; in the stateless
+// mode only the revocation cache is consulted and session activity is not
+// tracked.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*models.TokenClaims, error) {
+	s.log.Debug("Validating token")
+
+	token, err := jwt.Parse(tokenString, s.keyRing.Keyfunc,
+		jwt.WithValidMethods(s.keyRing.Algorithms()),
+		jwt.WithIssuer(s.cfg.JWT.Issuer),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
 		s.log.Error("Token parsing failed", utils.ErrLog(err))
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
 		s.log.Error("Invalid token", slog.String("token", tokenString))
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	userID, ok := claims["userID"].(float64)
 	if !ok {
 		s.log.Error("Token validation failed: userID not found in token")
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before sessions were introduced carry no session and
+	// cannot be logged out, so they are no longer accepted.
+	sessionID, ok := claims["sid"].(float64)
+	if !ok {
+		s.log.Info("Token validation failed: sid not found in token", slog.Int64("user_id", int64(userID)))
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before roles were introduced belong to regular users.
+	role, ok := claims["role"].(string)
+	if !ok {
+		role = models.RoleUser
+	}
+
+	if s.cfg.JWT.ValidationMode == config.ValidationModeStateless {
+		if s.revocations.IsRevoked(int64(sessionID)) {
+			s.log.Info("Token validation failed: session revoked", slog.Int64("user_id", int64(userID)))
+			return nil, ErrInvalidToken
+		}
+
+		s.log.Debug("Token validated successfully", slog.Int64("user_id", int64(userID)))
+		return &models.TokenClaims{
+			UserID:    int64(userID),
+			SessionID: int64(sessionID),
+			Role:      role,
+		}, nil
 	}
 
 	exists, err := s.authRepo.CheckTokenExists(ctx, tokenString)
 	if err != nil {
 		s.log.Error("Failed to get token", utils.ErrLog(err))
-		return 0, err
+		return nil, err
 	}
 	if !exists {
 		s.log.Info("Token validation failed: token not found or expired", slog.Int64("user_id", int64(userID)))
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+
+	err = s.authRepo.TouchSession(ctx, int64(sessionID), sessionTouchInterval)
+	if err != nil {
+		s.log.Error("Failed to update session activity", slog.Int64("session_id", int64(sessionID)), utils.ErrLog(err))
 	}
 
 	s.log.Debug("Token validated successfully", slog.Int64("user_id", int64(userID)))
-	return int64(userID), nil
+	return &models.TokenClaims{
+		UserID:    int64(userID),
+		SessionID: int64(sessionID),
+		Role:      role,
+	}, nil
 }
 
-func (s *AuthService) CleanupExpiredTokens() {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// Logout revokes the session of the current access token together with its
+// refresh tokens.
+func (s *AuthService) Logout(ctx context.Context, sessionID int64) error {
+	err := s.authRepo.RevokeSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Session revoked", slog.Int64("session_id", sessionID))
+	return nil
+}
 
-	if err := s.authRepo.DeleteExpiredTokens(ctx); err != nil {
+// GetSessions returns the active sessions of the user, marking the one the
+// request was made from.
+func (s *AuthService) GetSessions(ctx context.Context, userID, currentSessionID int64) ([]models.Session, error) {
+	sessions, err := s.authRepo.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	return storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		session, err := s.authRepo.GetActiveSession(txCtx, sessionID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+
+		// Sessions of other users are reported as missing so that their IDs
+		// cannot be probed.
+		if session.UserID != userID {
+			return ErrSessionNotFound
+		}
+
+		return s.authRepo.RevokeSession(txCtx, sessionID)
+	})
+}
+
+// RevokeOtherSessions signs the user out on every device except the current
+// one.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID int64) (int64, error) {
+	var revoked int64
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		var err error
+		revoked, err = s.authRepo.RevokeOtherSessions(txCtx, userID, currentSessionID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Info("Other sessions revoked", slog.Int64("user_id", userID), slog.Int64("count", revoked))
+	return revoked, nil
+}
+
+// JWKS returns the public keys that verify access tokens.
+func (s *AuthService) JWKS() jwtkeys.JWKS {
+	return s.keyRing.JWKS()
+}
+
+// RevokeUserTokens revokes every session and API key of the user, signing them
+// out on all devices.
+func (s *AuthService) RevokeUserTokens(ctx context.Context, userID int64) (int64, error) {
+	var revoked int64
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		_, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		revoked, err = s.authRepo.RevokeUserSessions(txCtx, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.authRepo.RevokeUserAPIKeys(txCtx, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Info("User tokens revoked", slog.Int64("user_id", userID), slog.Int64("count", revoked))
+	return revoked, nil
+}
+
+// ChangeRole assigns a role to the user. Tokens carry the role of the time they
+// were issued, so promotions apply from the next refresh, while demotions
+// revoke every session of the user at once.
+func (s *AuthService) ChangeRole(ctx context.Context, actorID, userID int64, role string) (*models.UserPublic, error) {
+	if !models.HasRole(role, models.RoleUser) {
+		return nil, ErrInvalidRole
+	}
+	if actorID == userID {
+		return nil, ErrCannotChangeOwnRole
+	}
+
+	var result *models.UserPublic
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		user, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		result = &models.UserPublic{
+			ID:        user.ID,
+			Login:     user.Login,
+			Role:      role,
+			CreatedAt: user.CreatedAt,
+		}
+		if user.Role == role {
+			return nil
+		}
+
+		err = s.userService.UpdateRole(txCtx, userID, role)
+		if err != nil {
+			return err
+		}
+
+		if !models.HasRole(role, user.Role) {
+			_, err = s.authRepo.RevokeUserSessions(txCtx, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.auditService.Record(txCtx, models.AuditEntry{
+			Event:  models.AuditEventRoleChanged,
+			UserID: &userID,
+			Details: map[string]any{
+				"actor_id": actorID,
+				"from":     user.Role,
+				"to":       role,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("User role changed",
+		slog.Int64("actor_id", actorID),
+		slog.Int64("user_id", userID),
+		slog.String("role", role),
+	)
+	return result, nil
+}
+
+func (s *AuthService) CleanupExpiredTokens(ctx context.Context) (int64, error) {
+	deleted, err := s.authRepo.DeleteExpiredTokens(ctx)
+	if err != nil {
 		s.log.Error("Failed to cleanup expired tokens", utils.ErrLog(err))
-	} else {
-		s.log.Info("Successfully cleaned up expired tokens")
+		return 0, err
+	}
+
+	s.log.Info("Successfully cleaned up expired tokens", slog.Int64("count", deleted))
+	return deleted, nil
+}
+
+// issueTokens creates an access token and a refresh token for the session and
+// extends the session lifetime to the lifetime of the new refresh token.
+func (s *AuthService) issueTokens(ctx context.Context, userID int64, role string, sessionID int64) (*models.TokenPair, error) {
+	accessToken, expiresAt, err := s.createToken(ctx, userID, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiresAt := time.Now().Add(s.cfg.JWT.RefreshTokenLiveTime)
+	err = s.authRepo.CreateRefreshToken(ctx, hashOpaqueToken(refreshToken), sessionID, refreshExpiresAt)
+	if err != nil {
+		s.log.Error("Failed to create refresh token in db", slog.Int64("user_id", userID), utils.ErrLog(err))
+		return nil, err
+	}
+
+	err = s.authRepo.ExtendSession(ctx, sessionID, refreshExpiresAt)
+	if err != nil {
+		return nil, err
 	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
 }
 
-func (s *AuthService) createToken(ctx context.Context, userID int64) (string, error) {
+func (s *AuthService) createToken(ctx context.Context, userID int64, role string, sessionID int64) (string, time.Time, error) {
 	s.log.Debug("Creating token for user", slog.Int64("user_id", userID))
 
 	expiresAt := time.Now().Add(s.cfg.JWT.TokenLiveTime)
 
 	payload := jwt.MapClaims{
+		"iss":    s.cfg.JWT.Issuer,
+		"sub":    strconv.FormatInt(userID, 10),
 		"userID": userID,
+		"sid":    sessionID,
+		"role":   role,
 		"exp":    expiresAt.Unix(),
 		"iat":    time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	tokenString, err := token.SignedString([]byte(s.cfg.JWT.JWTSecret))
+	tokenString, err := s.keyRing.Sign(payload)
 	if err != nil {
 		s.log.Error("Failed to generate tokens", slog.Int64("user_id", userID), utils.ErrLog(err))
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	err = s.authRepo.CreateToken(ctx, tokenString, userID, expiresAt)
+	err = s.authRepo.CreateToken(ctx, tokenString, userID, sessionID, expiresAt)
 	if err != nil {
 		s.log.Error("Failed to create token in db", slog.Int64("user_id", userID), utils.ErrLog(err))
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	s.log.Info("Token created successfully", slog.Int64("user_id", userID))
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/oidc"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+const (
+	oidcLoginMinLength = 3
+	oidcLoginMaxLength = 40
+	oidcLoginAttempts  = 5
+	oidcLoginFallback  = "user"
+)
+
+// StartOIDCLogin begins a login through the OIDC provider and returns the URL
+// of its authorization endpoint. The PKCE verifier and nonce are kept until
+// the callback under a hash of the state.
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (string, error) {
+	if s.oidc == nil {
+		return "", ErrOIDCNotConfigured
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.oidc.AuthCodeURL(ctx, state, nonce, oidc.S256Challenge(verifier))
+	if err != nil {
+		return "", err
+	}
+
+	err = s.authRepo.CreateOIDCLoginState(ctx, hashOpaqueToken(state), models.OIDCLoginState{
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.cfg.OIDC.StateLiveTime),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code of the callback and logs in
+// the user linked to the external identity. On the first login an account is
+// created for the identity; an account already registered with the same email
+// is not linked automatically.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, code, state string, client models.ClientInfo) (*models.LoginResult, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	loginState, err := s.authRepo.TakeOIDCLoginState(ctx, hashOpaqueToken(state))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if time.Now().After(loginState.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := s.oidc.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrTokenExchange) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			s.log.Warn("OIDC login rejected", slog.String("reason", err.Error()))
+			return nil, ErrOIDCLoginFailed
+		}
+		return nil, err
+	}
+
+	email := ""
+	if claims.EmailVerified {
+		email = strings.ToLower(claims.Email)
+	}
+
+	var result *models.LoginResult
+
+	err = storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		var user *models.UserPublic
+
+		identity, err := s.authRepo.GetUserIdentity(txCtx, s.oidc.Issuer(), claims.Subject)
+		switch {
+		case err == nil:
+			existing, err := s.userService.GetByID(txCtx, identity.UserID)
+			if err != nil {
+				return err
+			}
+			user = &models.UserPublic{
+				ID:        existing.ID,
+				Login:     existing.Login,
+				Role:      existing.Role,
+				CreatedAt: existing.CreatedAt,
+			}
+
+			err = s.authRepo.TouchUserIdentity(txCtx, identity.ID, email)
+			if err != nil {
+				return err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			user, err = s.provisionOIDCUser(txCtx, claims, email)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		result, err = s.startLogin(txCtx, user, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("User logged in with OIDC", slog.Int64("user_id", result.User.ID))
+	return result, nil
+}
+
+// provisionOIDCUser creates an account for an external identity. The account
+// gets no password, so it cannot log in with one; a password can be set later
+// through the password reset flow if the provider reported a verified email.
+func (s *AuthService) provisionOIDCUser(ctx context.Context, claims *oidc.Claims, email string) (*models.UserPublic, error) {
+	if email != "" {
+		exists, err := s.userService.CheckEmailExists(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrEmailAlreadyExists
+		}
+	}
+
+	login, err := s.uniqueOIDCLogin(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty hash matches no password.
+	user, err := s.userService.Create(ctx, login, email, "", models.RoleUser)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authRepo.CreateUserIdentity(ctx, user.ID, s.oidc.Issuer(), claims.Subject, email)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("User provisioned from OIDC identity", slog.Int64("user_id", user.ID), slog.String("login", login))
+	return user, nil
+}
+
+// uniqueOIDCLogin derives a free login from the preferred username or email
+// of the identity, appending a random number if it is already taken.
+func (s *AuthService) uniqueOIDCLogin(ctx context.Context, claims *oidc.Claims) (string, error) {
+	base := oidcLoginBase(claims.PreferredUsername)
+	if base == "" {
+		base = oidcLoginBase(strings.SplitN(claims.Email, "@", 2)[0])
+	}
+	if len(base) < oidcLoginMinLength {
+		base = oidcLoginFallback + base
+	}
+
+	login := base
+	for range oidcLoginAttempts {
+		exists, err := s.userService.CheckExists(ctx, login)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return login, nil
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+		if err != nil {
+			return "", err
+		}
+		login = fmt.Sprintf("%s%06d", base, n.Int64())
+	}
+
+	return "", ErrUserAlreadyExists
+}
+
+// oidcLoginBase keeps the ASCII letters and digits of the name, as required
+// for logins.
+func oidcLoginBase(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+		if b.Len() == oidcLoginMaxLength {
+			break
+		}
+	}
+
+	return b.String()
+}
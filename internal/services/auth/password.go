@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/mailer"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetSendTimeout bounds the background work of a reset request.
+const passwordResetSendTimeout = 30 * time.Second
+
+// ChangePassword replaces the password of the user after checking the current
+// one. Wrong current passwords count as failed logins, so that a session cannot
+// be used to guess the password. Every other session of the user is revoked,
+// so a stolen session does not survive the change.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, currentSessionID int64, currentPassword, newPassword string, client models.ClientInfo) error {
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		user, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		err = s.checkLoginThrottle(txCtx, user.Login, client.IP)
+		if err != nil {
+			return err
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword))
+		if err != nil {
+			return s.rejectLogin(txCtx, ErrWrongPassword, user.Login, &user.ID, client.IP)
+		}
+
+		err = s.setPassword(txCtx, userID, newPassword)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.authRepo.RevokeOtherSessions(txCtx, userID, currentSessionID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Password changed", slog.Int64("user_id", userID))
+	return nil
+}
+
+// RequestPasswordReset mails a single-use reset link to the user with the given
+// email. The link is prepared and sent in the background, so that neither the
+// response time nor a mailer failure tell whether the email is registered. The
+// requests are limited per client IP, which gets ErrPasswordResetThrottled
+// once the limit is reached, and per email, whose extra requests are dropped
+// silently.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string, client models.ClientInfo) error {
+	err := s.checkPasswordResetThrottle(ctx, client.IP)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetSendTimeout)
+		defer cancel()
+
+		err := s.sendPasswordReset(ctx, email)
+		if err != nil {
+			s.log.Error("Failed to send password reset email", utils.ErrLog(err))
+		}
+	}()
+
+	return nil
+}
+
+func (s *AuthService) sendPasswordReset(ctx context.Context, email string) error {
+	security := s.cfg.Security
+
+	requests, err := s.authRepo.RecordLoginFailure(ctx, throttleScopeResetEmail, throttleKey(email), time.Now().Add(security.PasswordResetWindow))
+	if err != nil {
+		return err
+	}
+	if requests > security.PasswordResetMaxRequests {
+		s.log.Warn("Password reset request dropped, too many requests for the email", slog.Int("requests", requests))
+		return nil
+	}
+
+	user, err := s.userService.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.log.Info("Password reset requested for unknown email")
+			return nil
+		}
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.cfg.JWT.PasswordResetLiveTime)
+	err = s.authRepo.CreatePasswordResetToken(ctx, hashOpaqueToken(token), user.ID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(s.cfg.Mailer.PasswordResetURL)
+	if err != nil {
+		return fmt.Errorf("invalid password reset url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Hello, %s!\r\n\r\n"+
+				"Someone requested a password reset for your marketplace account.\r\n"+
+				"To choose a new password, open the link below. It is valid until %s.\r\n\r\n"+
+				"%s\r\n\r\n"+
+				"If you did not request a reset, ignore this message.\r\n",
+			user.Login, expiresAt.UTC().Format(time.RFC1123), link.String(),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("user %d: %w", user.ID, err)
+	}
+
+	s.log.Info("Password reset requested", slog.Int64("user_id", user.ID))
+	return nil
+}
+
+// checkPasswordResetThrottle counts a reset request of the IP and fails while
+// the IP is blocked after too many of them.
+func (s *AuthService) checkPasswordResetThrottle(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	security := s.cfg.Security
+
+	blockedUntil, err := s.authRepo.GetBlockedUntil(ctx, throttleScopeResetIP, ip)
+	if err != nil {
+		return err
+	}
+	if blockedUntil != nil {
+		return &PasswordResetThrottledError{RetryAfter: time.Until(*blockedUntil)}
+	}
+
+	now := time.Now()
+	requests, err := s.authRepo.RecordLoginFailure(ctx, throttleScopeResetIP, ip, now.Add(security.PasswordResetWindow))
+	if err != nil {
+		return err
+	}
+	if requests >= security.PasswordResetIPMaxRequests {
+		s.log.Warn("Password reset requests blocked for the IP", slog.String("ip", ip), slog.Int("requests", requests))
+		return s.authRepo.BlockLogin(ctx, throttleScopeResetIP, ip, now.Add(security.PasswordResetWindow), true)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out
+// everywhere.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	var userID int64
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		stored, err := s.authRepo.GetPasswordResetTokenForUpdate(txCtx, hashOpaqueToken(token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+		userID = stored.UserID
+
+		err = s.setPassword(txCtx, userID, newPassword)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.authRepo.RevokeUserSessions(txCtx, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Password reset", slog.Int64("user_id", userID))
+	return nil
+}
+
+// setPassword stores the new password hash and invalidates outstanding reset
+// tokens, which were issued for the old password.
+func (s *AuthService) setPassword(ctx context.Context, userID int64, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.cfg.JWT.BCryptCost)
+	if err != nil {
+		return err
+	}
+
+	err = s.userService.UpdatePassword(ctx, userID, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+
+	return s.authRepo.InvalidatePasswordResetTokens(ctx, userID)
+}
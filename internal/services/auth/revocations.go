@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ocenb/marketplace/internal/config"
+	"github.com/ocenb/marketplace/internal/metrics"
+	"github.com/ocenb/marketplace/internal/repos/auth"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// RevocationCache keeps the revoked sessions that still have unexpired access
+// tokens in memory, so that stateless validation needs no database round-trip.
+// It is reloaded periodically and updated on notifications from the
+// revocations channel.
+type RevocationCache struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	authRepo auth.AuthRepoInterface
+	metrics  *metrics.Metrics
+
+	mu       sync.RWMutex
+	sessions map[int64]time.Time
+}
+
+func NewRevocationCache(
+	cfg *config.Config,
+	log *slog.Logger,
+	authRepo auth.AuthRepoInterface,
+	metrics *metrics.Metrics,
+) *RevocationCache {
+	return &RevocationCache{
+		cfg:      cfg,
+		log:      log,
+		authRepo: authRepo,
+		metrics:  metrics,
+		sessions: make(map[int64]time.Time),
+	}
+}
+
+// Start subscribes to revocation notifications and loads the current
+// revocations before returning, so that no revoked token is accepted once the
+// server starts. The cache is kept up to date until the context is cancelled.
+func (c *RevocationCache) Start(ctx context.Context) error {
+	listener := pq.NewListener(c.cfg.Postgres.Url, 10*time.Second, time.Minute, c.logListenerEvent)
+
+	err := listener.Listen(auth.RevocationsChannel)
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	err = c.reload(ctx)
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	go c.run(ctx, listener)
+	return nil
+}
+
+// IsRevoked reports whether the session has been revoked.
+func (c *RevocationCache) IsRevoked(sessionID int64) bool {
+	c.mu.RLock()
+	expiresAt, ok := c.sessions[sessionID]
+	c.mu.RUnlock()
+
+	if ok && time.Now().Before(expiresAt) {
+		c.metrics.RevocationCacheLookups.WithLabelValues("hit").Inc()
+		return true
+	}
+
+	c.metrics.RevocationCacheLookups.WithLabelValues("miss").Inc()
+	return false
+}
+
+func (c *RevocationCache) run(ctx context.Context, listener *pq.Listener) {
+	defer func() {
+		if err := listener.Close(); err != nil {
+			c.log.Error("Failed to close revocation listener", utils.ErrLog(err))
+		}
+	}()
+
+	ticker := time.NewTicker(c.cfg.JWT.RevocationRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.reloadLogged(ctx)
+		case notification := <-listener.Notify:
+			// A nil notification is sent after the connection has been
+			// re-established, notifications may have been lost meanwhile.
+			if notification == nil {
+				c.reloadLogged(ctx)
+				continue
+			}
+			c.apply(notification.Extra)
+		}
+	}
+}
+
+func (c *RevocationCache) reloadLogged(ctx context.Context) {
+	if err := c.reload(ctx); err != nil {
+		c.log.Error("Failed to reload revocation cache", utils.ErrLog(err))
+	}
+}
+
+// reload merges the stored revocations into the cache and drops the expired
+// ones. Revocations are never undone, so entries received from notifications
+// while the query was running are kept.
+func (c *RevocationCache) reload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	revocations, err := c.authRepo.GetActiveRevocations(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, revocation := range revocations {
+		c.add(revocation.SessionID, revocation.ExpiresAt)
+	}
+	for sessionID, expiresAt := range c.sessions {
+		if !now.Before(expiresAt) {
+			delete(c.sessions, sessionID)
+		}
+	}
+	c.metrics.RevocationCacheSize.Set(float64(len(c.sessions)))
+
+	c.log.Debug("Revocation cache reloaded", slog.Int("size", len(c.sessions)))
+	return nil
+}
+
+func (c *RevocationCache) apply(payload string) {
+	rawSessionID, rawExpiresAt, found := strings.Cut(payload, ":")
+	sessionID, sessionErr := strconv.ParseInt(rawSessionID, 10, 64)
+	expiresAt, expiresErr := strconv.ParseInt(rawExpiresAt, 10, 64)
+	if !found || sessionErr != nil || expiresErr != nil {
+		c.log.Error("Invalid revocation notification", slog.String("payload", payload))
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The payload is truncated to seconds, round up so that the last token of
+	// the session does not outlive its revocation.
+	c.add(sessionID, time.Unix(expiresAt+1, 0))
+	c.metrics.RevocationCacheSize.Set(float64(len(c.sessions)))
+
+	c.log.Debug("Session revocation received", slog.Int64("session_id", sessionID))
+}
+
+// add must be called with the lock held.
+func (c *RevocationCache) add(sessionID int64, expiresAt time.Time) {
+	if current, ok := c.sessions[sessionID]; !ok || expiresAt.After(current) {
+		c.sessions[sessionID] = expiresAt
+	}
+}
+
+func (c *RevocationCache) logListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnectionAttemptFailed, pq.ListenerEventDisconnected:
+		c.log.Error("Revocation listener connection problem", utils.ErrLog(err))
+	case pq.ListenerEventReconnected:
+		c.log.Info("Revocation listener reconnected")
+	}
+}
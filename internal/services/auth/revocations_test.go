@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/config"
+	"github.com/ocenb/marketplace/internal/metrics"
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/repos/auth"
+)
+
+// revocationRepo serves the stored revocations; the other repository methods
+// are not used by the cache.
+type revocationRepo struct {
+	auth.AuthRepoInterface
+	revocations []models.Revocation
+	err         error
+}
+
+func (r *revocationRepo) GetActiveRevocations(ctx context.Context) ([]models.Revocation, error) {
+	return r.revocations, r.err
+}
+
+func newTestRevocationCache(repo *revocationRepo) *RevocationCache {
+	return NewRevocationCache(&config.Config{}, slog.New(slog.DiscardHandler), repo, metrics.NewMetrics("test"))
+}
+
+func TestRevocationCacheApply(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		payloads  []string
+		sessionID int64
+		revoked   bool
+	}{
+		{"unexpired", []string{revocationPayload(1, now.Add(time.Minute))}, 1, true},
+		{"expired", []string{revocationPayload(1, now.Add(-2*time.Second))}, 1, false},
+		// The payload is truncated to seconds, the revocation must last until
+		// the end of that second.
+		{"expiring this second", []string{revocationPayload(1, now)}, 1, true},
+		{"other session", []string{revocationPayload(2, now.Add(time.Minute))}, 1, false},
+		{"later expiry wins", []string{
+			revocationPayload(1, now.Add(time.Minute)),
+			revocationPayload(1, now.Add(-time.Minute)),
+		}, 1, true},
+		{"invalid payloads", []string{"", "1", "1:", ":1", "x:1", "1:x"}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestRevocationCache(&revocationRepo{})
+			for _, payload := range tt.payloads {
+				cache.apply(payload)
+			}
+
+			if got := cache.IsRevoked(tt.sessionID); got != tt.revoked {
+				t.Errorf("IsRevoked(%d) = %v, want %v", tt.sessionID, got, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestRevocationCacheReload(t *testing.T) {
+	now := time.Now()
+	repo := &revocationRepo{
+		revocations: []models.Revocation{
+			{SessionID: 1, ExpiresAt: now.Add(time.Minute)},
+			{SessionID: 2, ExpiresAt: now.Add(-time.Second)},
+			{SessionID: 3, ExpiresAt: now.Add(time.Hour)},
+		},
+	}
+	cache := newTestRevocationCache(repo)
+
+	// Entries received from notifications are kept by a reload unless they
+	// have expired, the stored revocations may lag behind them.
+	cache.apply(revocationPayload(3, now.Add(time.Minute)))
+	cache.apply(revocationPayload(4, now.Add(time.Minute)))
+	cache.sessions[5] = now.Add(-time.Second)
+
+	if err := cache.reload(context.Background()); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+
+	want := map[int64]bool{1: true, 2: false, 3: true, 4: true, 5: false}
+	for sessionID, revoked := range want {
+		if got := cache.IsRevoked(sessionID); got != revoked {
+			t.Errorf("IsRevoked(%d) = %v, want %v", sessionID, got, revoked)
+		}
+	}
+	if len(cache.sessions) != 3 {
+		t.Errorf("cache holds %d sessions, want the 3 unexpired ones", len(cache.sessions))
+	}
+	if !cache.sessions[3].Equal(repo.revocations[2].ExpiresAt) {
+		t.Errorf("session 3 expires at %v, want the later %v", cache.sessions[3], repo.revocations[2].ExpiresAt)
+	}
+
+	// A failed reload leaves the cache as it was.
+	repo.err = errors.New("connection lost")
+	if err := cache.reload(context.Background()); !errors.Is(err, repo.err) {
+		t.Fatalf("reload() error = %v, want %v", err, repo.err)
+	}
+	if !cache.IsRevoked(1) {
+		t.Errorf("IsRevoked(1) = false after a failed reload")
+	}
+}
+
+// revocationPayload builds a notification like the revocations channel sends
+// them.
+func revocationPayload(sessionID int64, expiresAt time.Time) string {
+	return fmt.Sprintf("%d:%d", sessionID, expiresAt.Unix())
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+const (
+	throttleScopeLogin = "login"
+	throttleScopeIP    = "ip"
+
+	throttleScopeResetIP    = "reset_ip"
+	throttleScopeResetEmail = "reset_email"
+)
+
+// LoginThrottledError is returned by Login while the login or the client IP
+// is blocked after failed attempts. It matches ErrLoginThrottled.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// PasswordResetThrottledError is returned by RequestPasswordReset while the
+// client IP is blocked after too many requests. It matches
+// ErrPasswordResetThrottled.
+type PasswordResetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *PasswordResetThrottledError) Error() string {
+	return ErrPasswordResetThrottled.Error()
+}
+
+func (e *PasswordResetThrottledError) Is(target error) bool {
+	return target == ErrPasswordResetThrottled
+}
+
+// committedError fails an operation after its transaction has been committed.
+// A rejected attempt is often recorded in the transaction that rejects it, a
+// failed login or a revoked session, and returning the rejection from the
+// transaction as a plain error would roll the record back.
+type committedError struct {
+	err error
+}
+
+func (e *committedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *committedError) Unwrap() error {
+	return e.err
+}
+
+// withTransaction runs fn in a transaction like storage.WithTransaction, but
+// commits the transaction when fn returns a *committedError, whose error is
+// returned after the commit.
+func (s *AuthService) withTransaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	var rejected *committedError
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		err := fn(txCtx)
+		if errors.As(err, &rejected) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if rejected != nil {
+		return rejected.err
+	}
+
+	return nil
+}
+
+// rejectLogin records a failed login like recordLoginFailure and returns the
+// rejection as a *committedError, so that the failure is committed by
+// withTransaction.
+func (s *AuthService) rejectLogin(ctx context.Context, rejection error, login string, userID *int64, ip string) error {
+	err := s.recordLoginFailure(ctx, login, userID, ip)
+	if err != nil {
+		return err
+	}
+
+	return &committedError{err: rejection}
+}
+
+// checkLoginThrottle fails if the login or the IP is blocked. It runs before
+// the password is hashed, so blocked attempts cost no bcrypt work. It must run
+// in the transaction that records the failure of the attempt: the throttle rows
+// stay locked until it ends, so that concurrent attempts wait for the failure
+// to be recorded instead of all passing the check.
+func (s *AuthService) checkLoginThrottle(ctx context.Context, login, ip string) error {
+	// The rows are always locked in the same order, the login first.
+	blockedUntil, err := s.authRepo.LockThrottle(ctx, throttleScopeLogin, throttleKey(login))
+	if err != nil {
+		return err
+	}
+
+	if ip != "" {
+		ipBlockedUntil, err := s.authRepo.LockThrottle(ctx, throttleScopeIP, ip)
+		if err != nil {
+			return err
+		}
+		if blockedUntil == nil || (ipBlockedUntil != nil && ipBlockedUntil.After(*blockedUntil)) {
+			blockedUntil = ipBlockedUntil
+		}
+	}
+
+	if blockedUntil == nil {
+		return nil
+	}
+
+	return &LoginThrottledError{RetryAfter: time.Until(*blockedUntil)}
+}
+
+// recordLoginFailure counts the failure for the login and the IP and blocks
+// them for a backoff delay, or locks them out once the limit is reached.
+// userID is nil when the login does not exist.
+func (s *AuthService) recordLoginFailure(ctx context.Context, login string, userID *int64, ip string) error {
+	security := s.cfg.Security
+
+	err := s.throttle(ctx, throttleScopeLogin, throttleKey(login), security.LoginFreeFailures, security.LoginMaxFailures, userID, ip)
+	if err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+	return s.throttle(ctx, throttleScopeIP, ip, security.LoginIPFreeFailures, security.LoginIPMaxFailures, nil, ip)
+}
+
+func (s *AuthService) throttle(ctx context.Context, scope, key string, freeFailures, maxFailures int, userID *int64, ip string) error {
+	security := s.cfg.Security
+	now := time.Now()
+
+	failures, err := s.authRepo.RecordLoginFailure(ctx, scope, key, now.Add(security.LoginFailureWindow))
+	if err != nil {
+		return err
+	}
+
+	if failures >= maxFailures {
+		lockedUntil := now.Add(security.LoginLockoutDuration)
+		err = s.authRepo.BlockLogin(ctx, scope, key, lockedUntil, true)
+		if err != nil {
+			return err
+		}
+
+		s.log.Warn("Login locked out after failed attempts",
+			slog.String("scope", scope),
+			slog.String("key", key),
+			slog.Int("failures", failures),
+		)
+		return s.auditService.Record(ctx, models.AuditEntry{
+			Event:  models.AuditEventLoginLockout,
+			UserID: userID,
+			IP:     ip,
+			Details: map[string]any{
+				"scope":        scope,
+				"key":          key,
+				"failures":     failures,
+				"locked_until": lockedUntil,
+			},
+		})
+	}
+
+	delay := loginBackoff(security.LoginBackoffBase, failures-freeFailures, security.LoginLockoutDuration)
+	if delay <= 0 {
+		return nil
+	}
+
+	return s.authRepo.BlockLogin(ctx, scope, key, now.Add(delay), false)
+}
+
+// loginBackoff doubles the delay with every counted failure, starting at base
+// and never exceeding limit.
+func loginBackoff(base time.Duration, failures int, limit time.Duration) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+
+	return min(delay, limit)
+}
+
+// throttleKey makes logins differing only in case share their failure count.
+func throttleKey(login string) string {
+	return strings.ToLower(login)
+}
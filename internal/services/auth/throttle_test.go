@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/ocenb/marketplace/internal/repos/auth"
+	"github.com/ocenb/marketplace/internal/storage"
+)
+
+// txRepo hands out a transaction that only records how it ended; the other
+// repository methods are not used by withTransaction.
+type txRepo struct {
+	auth.AuthRepoInterface
+	tx *recordingTx
+}
+
+func (r *txRepo) BeginTx(ctx context.Context, opts *sql.TxOptions) (storage.SqlTx, error) {
+	r.tx = &recordingTx{}
+	return r.tx, nil
+}
+
+type recordingTx struct {
+	storage.SqlTx
+	committed bool
+}
+
+func (tx *recordingTx) Commit() error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	return nil
+}
+
+func TestWithTransaction(t *testing.T) {
+	failure := errors.New("connection lost")
+
+	tests := []struct {
+		name      string
+		result    error
+		committed bool
+		want      error
+	}{
+		{"success", nil, true, nil},
+		{"error", failure, false, failure},
+		{"committed error", &committedError{err: ErrInvalidCredentials}, true, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &txRepo{}
+			s := &AuthService{authRepo: repo}
+
+			err := s.withTransaction(context.Background(), func(txCtx context.Context) error {
+				return tt.result
+			})
+			if err != tt.want {
+				t.Errorf("withTransaction() error = %v, want %v", err, tt.want)
+			}
+			if repo.tx.committed != tt.committed {
+				t.Errorf("committed = %v, want %v", repo.tx.committed, tt.committed)
+			}
+		})
+	}
+}
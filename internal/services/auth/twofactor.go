@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxChallengeAttempts limits guessing of the 6-digit code within a
+	// single login challenge.
+	maxChallengeAttempts = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// SetupTOTP generates a new authenticator secret for the user. Two-factor
+// authentication is enabled only after the first code is confirmed.
+func (s *AuthService) SetupTOTP(ctx context.Context, userID int64) (*models.TOTPSetup, error) {
+	var setup *models.TOTPSetup
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		user, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		enabled, err := s.authRepo.IsTOTPEnabled(txCtx, userID)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			return ErrTOTPAlreadyEnabled
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return err
+		}
+
+		err = s.authRepo.SaveTOTPSecret(txCtx, userID, secret)
+		if err != nil {
+			return err
+		}
+
+		setup = &models.TOTPSetup{
+			Secret: secret,
+			URI:    totp.URI(s.cfg.JWT.TOTPIssuer, user.Login, secret),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return setup, nil
+}
+
+// ConfirmTOTP enables two-factor authentication with the first code from the
+// authenticator app and returns recovery codes. The codes are shown only once;
+// only their hashes are stored.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	var recoveryCodes []string
+
+	err := storage.WithTransaction(ctx, s.authRepo, func(txCtx context.Context) error {
+		stored, err := s.authRepo.GetTOTPForUpdate(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTOTPNotSetUp
+			}
+			return err
+		}
+		if stored.ConfirmedAt != nil {
+			return ErrTOTPAlreadyEnabled
+		}
+
+		step, ok := totp.Validate(stored.Secret, code, time.Now(), stored.LastUsedStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		err = s.authRepo.UseTOTPStep(txCtx, userID, step)
+		if err != nil {
+			return err
+		}
+
+		var hashes []string
+		recoveryCodes, hashes, err = generateRecoveryCodes()
+		if err != nil {
+			return err
+		}
+
+		return s.authRepo.ReplaceRecoveryCodes(txCtx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("Two-factor authentication enabled", slog.Int64("user_id", userID))
+	return recoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. Both the password and a
+// current code are required, so neither a stolen session nor a stolen password
+// is enough. Wrong passwords and codes count as failed logins.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID int64, password, code string, client models.ClientInfo) error {
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		user, err := s.userService.GetByID(txCtx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		err = s.checkLoginThrottle(txCtx, user.Login, client.IP)
+		if err != nil {
+			return err
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+		if err != nil {
+			return s.rejectLogin(txCtx, ErrWrongPassword, user.Login, &user.ID, client.IP)
+		}
+
+		enabled, err := s.authRepo.IsTOTPEnabled(txCtx, userID)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return ErrTOTPNotSetUp
+		}
+
+		ok, err := s.verifySecondFactor(txCtx, userID, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.rejectLogin(txCtx, ErrInvalidTwoFactorCode, user.Login, &user.ID, client.IP)
+		}
+
+		return s.authRepo.DeleteTOTP(txCtx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Two-factor authentication disabled", slog.Int64("user_id", userID))
+	return nil
+}
+
+// CompleteLogin exchanges a login challenge and a TOTP or recovery code for a
+// new session. Rejected codes count as failed logins of the user and the
+// client IP, so that they are throttled across challenges like wrong
+// passwords; the failures of the login are cleared once a code is accepted.
+func (s *AuthService) CompleteLogin(ctx context.Context, challengeToken, code string, client models.ClientInfo) (*models.LoginResult, error) {
+	var result *models.LoginResult
+
+	tokenHash := hashOpaqueToken(challengeToken)
+
+	err := s.withTransaction(ctx, func(txCtx context.Context) error {
+		challenge, err := s.authRepo.GetLoginChallengeForUpdate(txCtx, tokenHash)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidChallenge
+			}
+			return err
+		}
+		if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+			return ErrInvalidChallenge
+		}
+
+		user, err := s.userService.GetByID(txCtx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = s.checkLoginThrottle(txCtx, user.Login, client.IP)
+		if err != nil {
+			return err
+		}
+
+		ok, err := s.verifySecondFactor(txCtx, challenge.UserID, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			err = s.authRepo.IncrementLoginChallengeAttempts(txCtx, tokenHash)
+			if err != nil {
+				return err
+			}
+			return s.rejectLogin(txCtx, ErrInvalidTwoFactorCode, user.Login, &user.ID, client.IP)
+		}
+
+		err = s.authRepo.DeleteLoginChallenge(txCtx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		err = s.authRepo.ClearLoginFailures(txCtx, throttleScopeLogin, throttleKey(user.Login))
+		if err != nil {
+			return err
+		}
+
+		sessionID, err := s.authRepo.CreateSession(txCtx, user.ID, client, time.Now().Add(s.cfg.JWT.RefreshTokenLiveTime))
+		if err != nil {
+			return err
+		}
+
+		tokens, err := s.issueTokens(txCtx, user.ID, user.Role, sessionID)
+		if err != nil {
+			return err
+		}
+
+		result = &models.LoginResult{
+			User: &models.UserPublic{
+				ID:        user.ID,
+				Login:     user.Login,
+				Role:      user.Role,
+				CreatedAt: user.CreatedAt,
+			},
+			Tokens: tokens,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("Two-factor login completed", slog.Int64("user_id", result.User.ID))
+	return result, nil
+}
+
+// createLoginChallenge starts the second step of the login of a user with
+// two-factor authentication.
+func (s *AuthService) createLoginChallenge(ctx context.Context, userID int64) (string, time.Time, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.cfg.JWT.ChallengeLiveTime)
+	err = s.authRepo.CreateLoginChallenge(ctx, hashOpaqueToken(token), userID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code, which cannot be
+// replayed, or an unused recovery code, which is consumed.
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		stored, err := s.authRepo.GetTOTPForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+		if stored.ConfirmedAt == nil {
+			return false, nil
+		}
+
+		step, ok := totp.Validate(stored.Secret, code, time.Now(), stored.LastUsedStep)
+		if !ok {
+			return false, nil
+		}
+
+		return true, s.authRepo.UseTOTPStep(ctx, userID, step)
+	}
+
+	used, err := s.authRepo.UseRecoveryCode(ctx, userID, hashOpaqueToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	if used {
+		s.log.Info("Recovery code used", slog.Int64("user_id", userID))
+	}
+
+	return used, nil
+}
+
+// generateRecoveryCodes returns codes formatted for display, like
+// "abcde-fghij", and the hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashOpaqueToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
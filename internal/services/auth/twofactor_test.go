@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/repos/auth"
+	"github.com/ocenb/marketplace/internal/totp"
+)
+
+// secondFactorRepo keeps the TOTP secret and the recovery codes of a single
+// user the way the repository does; the other repository methods are not used
+// by the second factor check.
+type secondFactorRepo struct {
+	auth.AuthRepoInterface
+	totp          *models.TOTP
+	recoveryCodes map[string]bool
+}
+
+func (r *secondFactorRepo) GetTOTPForUpdate(ctx context.Context, userID int64) (*models.TOTP, error) {
+	if r.totp == nil {
+		return nil, sql.ErrNoRows
+	}
+	stored := *r.totp
+	return &stored, nil
+}
+
+func (r *secondFactorRepo) UseTOTPStep(ctx context.Context, userID, step int64) error {
+	r.totp.LastUsedStep = step
+	return nil
+}
+
+func (r *secondFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	used, ok := r.recoveryCodes[codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.recoveryCodes[codeHash] = true
+	return true, nil
+}
+
+func newSecondFactorService(t *testing.T) (*AuthService, *secondFactorRepo, []string) {
+	t.Helper()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confirmedAt := time.Now()
+	repo := &secondFactorRepo{
+		totp:          &models.TOTP{UserID: 1, Secret: secret, ConfirmedAt: &confirmedAt},
+		recoveryCodes: make(map[string]bool, len(hashes)),
+	}
+	for _, hash := range hashes {
+		repo.recoveryCodes[hash] = false
+	}
+
+	return &AuthService{log: slog.New(slog.DiscardHandler), authRepo: repo}, repo, codes
+}
+
+func TestVerifySecondFactorTOTP(t *testing.T) {
+	s, repo, _ := newSecondFactorService(t)
+
+	code, err := totp.Code(repo.totp.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.verifySecondFactor(context.Background(), 1, code); err != nil || !ok {
+		t.Fatalf("verifySecondFactor() = %v, %v, want the code accepted", ok, err)
+	}
+	// The step of the accepted code is stored, so the code cannot be
+	// replayed.
+	if ok, err := s.verifySecondFactor(context.Background(), 1, code); err != nil || ok {
+		t.Errorf("verifySecondFactor() of a replayed code = %v, %v, want it rejected", ok, err)
+	}
+
+	repo.totp.ConfirmedAt = nil
+	repo.totp.LastUsedStep = 0
+	if ok, err := s.verifySecondFactor(context.Background(), 1, code); err != nil || ok {
+		t.Errorf("verifySecondFactor() with an unconfirmed secret = %v, %v, want it rejected", ok, err)
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	s, _, codes := newSecondFactorService(t)
+
+	// Codes are accepted without the dash and in any case.
+	variants := []string{codes[0], strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))}
+	for _, code := range variants {
+		if ok, err := s.verifySecondFactor(context.Background(), 1, code); err != nil || !ok {
+			t.Fatalf("verifySecondFactor(%q) = %v, %v, want the code accepted", code, ok, err)
+		}
+		if ok, err := s.verifySecondFactor(context.Background(), 1, code); err != nil || ok {
+			t.Errorf("verifySecondFactor(%q) on a second use = %v, %v, want it rejected", code, ok, err)
+		}
+	}
+
+	if ok, err := s.verifySecondFactor(context.Background(), 1, "aaaaa-bbbbb"); err != nil || ok {
+		t.Errorf("verifySecondFactor() of an unknown code = %v, %v, want it rejected", ok, err)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("generateRecoveryCodes() = %d codes, %d hashes, want %d", len(codes), len(hashes), recoveryCodeCount)
+	}
+
+	seen := make(map[string]bool, len(codes))
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted like abcde-fghij", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q is repeated", code)
+		}
+		seen[code] = true
+		if hashes[i] != hashOpaqueToken(normalizeRecoveryCode(code)) {
+			t.Errorf("hash of code %q does not match the normalized code", code)
+		}
+	}
+}
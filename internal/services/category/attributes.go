@@ -0,0 +1,90 @@
+package category
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ocenb/marketplace/internal/models"
+)
+
+const maxStringAttributeLength = 200
+
+func validateAttributes(schema []models.CategoryAttribute, attributes map[string]any) (map[string]any, error) {
+	definitions := make(map[string]models.CategoryAttribute, len(schema))
+	for _, attribute := range schema {
+		definitions[attribute.Key] = attribute
+	}
+
+	for key := range attributes {
+		if _, ok := definitions[key]; !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttributes, key)
+		}
+	}
+
+	normalized := make(map[string]any, len(attributes))
+	for _, attribute := range schema {
+		value, ok := attributes[attribute.Key]
+		if !ok || value == nil {
+			if attribute.Required {
+				return nil, fmt.Errorf("%w: attribute %q is required", ErrInvalidAttributes, attribute.Key)
+			}
+			continue
+		}
+
+		normalizedValue, err := validateAttributeValue(attribute, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: attribute %q %s", ErrInvalidAttributes, attribute.Key, err.Error())
+		}
+		normalized[attribute.Key] = normalizedValue
+	}
+
+	return normalized, nil
+}
+
+func validateAttributeValue(attribute models.CategoryAttribute, value any) (any, error) {
+	switch attribute.Type {
+	case models.AttributeTypeEnum:
+		str, ok := value.(string)
+		if !ok || !slices.Contains(attribute.Options, str) {
+			return nil, fmt.Errorf("must be one of: %s", strings.Join(attribute.Options, ", "))
+		}
+		return str, nil
+
+	case models.AttributeTypeInt:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		integer := int64(number)
+		if attribute.Min != nil && integer < *attribute.Min {
+			return nil, fmt.Errorf("must be at least %d", *attribute.Min)
+		}
+		if attribute.Max != nil && integer > *attribute.Max {
+			return nil, fmt.Errorf("must be at most %d", *attribute.Max)
+		}
+		return integer, nil
+
+	case models.AttributeTypeBool:
+		boolean, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return boolean, nil
+
+	case models.AttributeTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		str = strings.TrimSpace(str)
+		if str == "" || utf8.RuneCountInString(str) > maxStringAttributeLength {
+			return nil, fmt.Errorf("must be a non-empty string of at most %d characters", maxStringAttributeLength)
+		}
+		return str, nil
+	}
+
+	return nil, fmt.Errorf("has unsupported type %q", attribute.Type)
+}
@@ -0,0 +1,75 @@
+package category
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ocenb/marketplace/internal/models"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	minMemory, maxMemory := int64(1), int64(4096)
+	schema := []models.CategoryAttribute{
+		{Key: "condition", Type: models.AttributeTypeEnum, Required: true, Options: []string{"new", "used"}},
+		{Key: "memory_gb", Type: models.AttributeTypeInt, Min: &minMemory, Max: &maxMemory},
+		{Key: "brand", Type: models.AttributeTypeString},
+		{Key: "boxed", Type: models.AttributeTypeBool},
+	}
+
+	tests := []struct {
+		name       string
+		attributes map[string]any
+		want       map[string]any
+	}{
+		{
+			name:       "required only",
+			attributes: map[string]any{"condition": "new"},
+			want:       map[string]any{"condition": "new"},
+		},
+		{
+			name:       "all types",
+			attributes: map[string]any{"condition": "used", "memory_gb": float64(128), "brand": "  Acme ", "boxed": true},
+			want:       map[string]any{"condition": "used", "memory_gb": int64(128), "brand": "Acme", "boxed": true},
+		},
+		{
+			name:       "null optional",
+			attributes: map[string]any{"condition": "new", "brand": nil},
+			want:       map[string]any{"condition": "new"},
+		},
+		{
+			name:       "int bounds are inclusive",
+			attributes: map[string]any{"condition": "new", "memory_gb": float64(4096)},
+			want:       map[string]any{"condition": "new", "memory_gb": int64(4096)},
+		},
+		{name: "unknown key", attributes: map[string]any{"condition": "new", "color": "red"}},
+		{name: "missing required", attributes: map[string]any{"memory_gb": float64(64)}},
+		{name: "null required", attributes: map[string]any{"condition": nil}},
+		{name: "enum outside options", attributes: map[string]any{"condition": "broken"}},
+		{name: "enum of wrong type", attributes: map[string]any{"condition": float64(1)}},
+		{name: "int of wrong type", attributes: map[string]any{"condition": "new", "memory_gb": "128"}},
+		{name: "fractional int", attributes: map[string]any{"condition": "new", "memory_gb": 1.5}},
+		{name: "int below min", attributes: map[string]any{"condition": "new", "memory_gb": float64(0)}},
+		{name: "int above max", attributes: map[string]any{"condition": "new", "memory_gb": float64(4097)}},
+		{name: "empty string", attributes: map[string]any{"condition": "new", "brand": "   "}},
+		{name: "bool of wrong type", attributes: map[string]any{"condition": "new", "boxed": "yes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateAttributes(schema, tt.attributes)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidAttributes) {
+					t.Fatalf("validateAttributes() error = %v, want ErrInvalidAttributes", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateAttributes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateAttributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/repos/category"
+)
+
+type CategoryServiceInterface interface {
+	GetTree(ctx context.Context) ([]*models.Category, error)
+	CheckLeaf(ctx context.Context, id int64) error
+	GetAttributes(ctx context.Context, categoryID int64) ([]models.CategoryAttribute, error)
+	ValidateAttributes(ctx context.Context, categoryID int64, attributes map[string]any) (map[string]any, error)
+}
+
+var (
+	ErrCategoryNotFound  = errors.New("category not found")
+	ErrCategoryNotLeaf   = errors.New("listings can only be placed in a category without subcategories")
+	ErrInvalidAttributes = errors.New("invalid listing attributes")
+)
+
+type CategoryService struct {
+	categoryRepo category.CategoryRepoInterface
+}
+
+func New(categoryRepo category.CategoryRepoInterface) CategoryServiceInterface {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (s *CategoryService) GetTree(ctx context.Context) ([]*models.Category, error) {
+	categories, err := s.categoryRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*models.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	roots := []*models.Category{}
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		if parent, ok := byID[*c.ParentID]; ok {
+			parent.Children = append(parent.Children, c)
+		}
+	}
+
+	return roots, nil
+}
+
+func (s *CategoryService) CheckLeaf(ctx context.Context, id int64) error {
+	isLeaf, err := s.categoryRepo.IsLeaf(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	if !isLeaf {
+		return ErrCategoryNotLeaf
+	}
+
+	return nil
+}
+
+// GetAttributes returns the attribute schema of the category, including
+// attributes inherited from parent categories. A subcategory may redefine an
+// attribute of its parent under the same key.
+func (s *CategoryService) GetAttributes(ctx context.Context, categoryID int64) ([]models.CategoryAttribute, error) {
+	exists, err := s.categoryRepo.CheckExists(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCategoryNotFound
+	}
+
+	inherited, err := s.categoryRepo.GetAttributes(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []models.CategoryAttribute{}
+	positions := make(map[string]int, len(inherited))
+	for _, attribute := range inherited {
+		if i, ok := positions[attribute.Key]; ok {
+			attributes[i] = attribute
+			continue
+		}
+		positions[attribute.Key] = len(attributes)
+		attributes = append(attributes, attribute)
+	}
+
+	return attributes, nil
+}
+
+// ValidateAttributes checks listing attributes against the category schema
+// and returns them normalized to their schema types.
+func (s *CategoryService) ValidateAttributes(ctx context.Context, categoryID int64, attributes map[string]any) (map[string]any, error) {
+	schema, err := s.GetAttributes(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateAttributes(schema, attributes)
+}
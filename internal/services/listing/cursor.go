@@ -0,0 +1,102 @@
+package listing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/ocenb/marketplace/internal/models"
+)
+
+// encodeCursor serializes the cursor and appends an HMAC signature so that
+// clients cannot forge positions in the feed.
+func encodeCursor(secret []byte, cursor models.FeedCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	signature := base64.RawURLEncoding.EncodeToString(signCursor(secret, encodedPayload))
+
+	return encodedPayload + "." + signature, nil
+}
+
+func decodeCursor(secret []byte, raw string) (*models.FeedCursor, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(raw, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !hmac.Equal(signature, signCursor(secret, encodedPayload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor models.FeedCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	switch {
+	case cursor.SortBy != "createdAt" && cursor.SortBy != "price",
+		cursor.SortOrder != "asc" && cursor.SortOrder != "desc",
+		cursor.ID < 1:
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// feedFiltersDigest returns a digest of the filters of a feed request. Cursors
+// carry it so that a position found with one set of filters is not used to
+// continue a feed with another one.
+func feedFiltersDigest(params models.FeedParams) string {
+	status := params.Status
+	if status == "" && !params.AllStatuses && len(params.Statuses) == 0 {
+		status = models.ListingStatusActive
+	}
+
+	filters, _ := json.Marshal(struct {
+		Status      string                   `json:"s"`
+		Statuses    []string                 `json:"ss"`
+		AllStatuses bool                     `json:"as"`
+		Query       string                   `json:"q"`
+		MinPrice    int64                    `json:"min"`
+		MaxPrice    int64                    `json:"max"`
+		CategoryID  int64                    `json:"c"`
+		Attributes  []models.AttributeFilter `json:"a"`
+		SellerID    int64                    `json:"u"`
+		FavoritedBy int64                    `json:"fav"`
+	}{
+		Status:      status,
+		Statuses:    params.Statuses,
+		AllStatuses: params.AllStatuses,
+		Query:       params.Query,
+		MinPrice:    params.MinPrice,
+		MaxPrice:    params.MaxPrice,
+		CategoryID:  params.CategoryID,
+		Attributes:  params.Attributes,
+		SellerID:    params.SellerID,
+		FavoritedBy: params.FavoritedBy,
+	})
+	sum := sha256.Sum256(filters)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+func signCursor(secret []byte, encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package listing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocenb/marketplace/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	secret := []byte("cursor-secret")
+	cursor := models.FeedCursor{
+		SortBy:    "price",
+		SortOrder: "asc",
+		CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Price:     150000,
+		ID:        42,
+		Filters:   feedFiltersDigest(models.FeedParams{MinPrice: 100000}),
+	}
+
+	raw, err := encodeCursor(secret, cursor)
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+
+	decoded, err := decodeCursor(secret, raw)
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if *decoded != cursor {
+		t.Fatalf("decoded cursor %+v, want %+v", *decoded, cursor)
+	}
+
+	for name, tampered := range map[string]string{
+		"other secret":    "",
+		"no signature":    raw[:len(raw)-44],
+		"changed payload": "x" + raw[1:],
+		"garbage":         "not-a-cursor",
+	} {
+		key := secret
+		if tampered == "" {
+			tampered, key = raw, []byte("other-secret")
+		}
+		if _, err := decodeCursor(key, tampered); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor error = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestFeedFiltersDigest(t *testing.T) {
+	base := models.FeedParams{
+		Query:      "bike",
+		MinPrice:   1000,
+		CategoryID: 3,
+		Attributes: []models.AttributeFilter{{Key: "brand", Op: models.AttributeFilterEq, Value: "trek"}},
+	}
+	digest := feedFiltersDigest(base)
+
+	same := base
+	same.Page, same.Limit, same.SortBy, same.SortOrder = 3, 50, "price", "asc"
+	same.Status = models.ListingStatusActive
+	if feedFiltersDigest(same) != digest {
+		t.Errorf("digest changed with paging, sorting or the default status")
+	}
+
+	for name, change := range map[string]func(p *models.FeedParams){
+		"query":     func(p *models.FeedParams) { p.Query = "car" },
+		"min price": func(p *models.FeedParams) { p.MinPrice = 2000 },
+		"max price": func(p *models.FeedParams) { p.MaxPrice = 5000 },
+		"category":  func(p *models.FeedParams) { p.CategoryID = 4 },
+		"attribute": func(p *models.FeedParams) { p.Attributes[0].Value = "giant" },
+		"status":    func(p *models.FeedParams) { p.Status = models.ListingStatusDraft },
+		"seller":    func(p *models.FeedParams) { p.SellerID = 7 },
+		"favorites": func(p *models.FeedParams) { p.FavoritedBy = 7 },
+	} {
+		changed := base
+		changed.Attributes = append([]models.AttributeFilter(nil), base.Attributes...)
+		change(&changed)
+		if feedFiltersDigest(changed) == digest {
+			t.Errorf("digest did not change with the %s", name)
+		}
+	}
+}
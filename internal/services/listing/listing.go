@@ -2,36 +2,152 @@ package listing
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
 
+	"github.com/ocenb/marketplace/internal/config"
 	"github.com/ocenb/marketplace/internal/metrics"
 	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/notifier"
 	"github.com/ocenb/marketplace/internal/repos/listing"
+	"github.com/ocenb/marketplace/internal/services/audit"
+	"github.com/ocenb/marketplace/internal/services/category"
+	"github.com/ocenb/marketplace/internal/services/user"
 	"github.com/ocenb/marketplace/internal/storage"
 )
 
 type ListingServiceInterface interface {
-	Create(ctx context.Context, userID int64, title string, description string, imageUrl string, price int64) (*models.Listing, error)
-	GetFeed(ctx context.Context, userID int64, page, limit int, sortBy, sortOrder string, minPrice, maxPrice int64) (*models.ListingsFeed, error)
-	CheckExists(ctx context.Context, id int64) (bool, error)
+	Create(ctx context.Context, userID int64, params CreateParams) (*models.Listing, error)
+	GetFeed(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error)
+	GetSellerFeed(ctx context.Context, userID, sellerID int64, params models.FeedParams) (*models.ListingsFeed, error)
+	GetMyListings(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsDashboard, error)
+	GetByID(ctx context.Context, id, userID int64) (*models.Listing, error)
+	Update(ctx context.Context, userID, id int64, params UpdateParams) (*models.Listing, error)
+	ChangeStatus(ctx context.Context, userID, id int64, status string) (*models.Listing, error)
+	Delete(ctx context.Context, userID, id int64) error
+	ArchiveAsModerator(ctx context.Context, moderatorID, id int64, reason string) (*models.Listing, error)
+	DeleteAsModerator(ctx context.Context, moderatorID, id int64, reason string) error
+	AddFavorite(ctx context.Context, userID, id int64) error
+	RemoveFavorite(ctx context.Context, userID, id int64) error
+	GetFavorites(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error)
+	GetUserFavorites(ctx context.Context, userID int64) ([]models.Favorite, error)
+	GetUserListings(ctx context.Context, userID int64) ([]models.Listing, error)
+	RemoveUserListings(ctx context.Context, userID int64) (int64, error)
+	CreateSavedSearch(ctx context.Context, userID int64, name string, query models.FeedParams) (*models.SavedSearch, error)
+	GetSavedSearches(ctx context.Context, userID int64) ([]models.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, userID, id int64) error
+	CheckSavedSearches(ctx context.Context) (int, error)
+	ReindexSearch(ctx context.Context) error
+}
+
+var (
+	ErrListingNotFound          = errors.New("listing not found")
+	ErrNotListingOwner          = errors.New("listing belongs to another user")
+	ErrInvalidStatusTransition  = errors.New("listing status transition is not allowed")
+	ErrStatusFilterRequiresAuth = errors.New("authentication is required to filter listings by status")
+	ErrStatusFilterForbidden    = errors.New("listings of other sellers can only be filtered by the active status")
+	ErrSellerNotFound           = errors.New("seller not found")
+	ErrInvalidCursor            = errors.New("invalid cursor")
+	ErrCursorFiltersMismatch    = errors.New("cursor was issued for different feed filters")
+	ErrRelevanceRequiresQuery   = errors.New("sorting by relevance requires a search query")
+	ErrFavoriteOwnListing       = errors.New("own listings cannot be added to favorites")
+	ErrStatusNotPublic          = errors.New("listings in this status are visible only to their owner")
+	ErrSavedSearchNotFound      = errors.New("saved search not found")
+	ErrTooManySavedSearches     = errors.New("saved search limit reached")
+)
+
+// statusTransitions lists the statuses a listing may move to from each status.
+var statusTransitions = map[string][]string{
+	models.ListingStatusDraft:    {models.ListingStatusActive, models.ListingStatusArchived},
+	models.ListingStatusActive:   {models.ListingStatusReserved, models.ListingStatusSold, models.ListingStatusArchived},
+	models.ListingStatusReserved: {models.ListingStatusActive, models.ListingStatusSold, models.ListingStatusArchived},
+	models.ListingStatusSold:     {models.ListingStatusArchived},
+	models.ListingStatusArchived: {models.ListingStatusActive},
+}
+
+type CreateParams struct {
+	Title       string
+	Description string
+	ImageURL    string
+	Price       int64
+	CategoryID  int64
+	Attributes  map[string]any
+	Status      string
+}
+
+type UpdateParams struct {
+	Title       *string
+	Description *string
+	ImageURL    *string
+	Price       *int64
+	CategoryID  *int64
+	Attributes  map[string]any
 }
 
 type ListingService struct {
-	listingRepo listing.ListingRepoInterface
-	metrics     *metrics.Metrics
+	cfg             *config.Config
+	log             *slog.Logger
+	listingRepo     listing.ListingRepoInterface
+	categoryService category.CategoryServiceInterface
+	userService     user.UserServiceInterface
+	auditService    audit.AuditServiceInterface
+	notifier        notifier.Notifier
+	metrics         *metrics.Metrics
 }
 
-func New(listingRepo listing.ListingRepoInterface, metrics *metrics.Metrics) ListingServiceInterface {
+func New(
+	cfg *config.Config,
+	log *slog.Logger,
+	listingRepo listing.ListingRepoInterface,
+	categoryService category.CategoryServiceInterface,
+	userService user.UserServiceInterface,
+	auditService audit.AuditServiceInterface,
+	notifier notifier.Notifier,
+	metrics *metrics.Metrics,
+) ListingServiceInterface {
 	return &ListingService{
-		listingRepo: listingRepo,
-		metrics:     metrics,
+		cfg:             cfg,
+		log:             log,
+		listingRepo:     listingRepo,
+		categoryService: categoryService,
+		userService:     userService,
+		auditService:    auditService,
+		notifier:        notifier,
+		metrics:         metrics,
 	}
 }
 
-func (s *ListingService) Create(ctx context.Context, userID int64, title string, description string, imageUrl string, price int64) (*models.Listing, error) {
+func (s *ListingService) Create(ctx context.Context, userID int64, params CreateParams) (*models.Listing, error) {
 	var result *models.Listing
 
+	status := params.Status
+	if status == "" {
+		status = models.ListingStatusActive
+	}
+
 	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
-		listing, err := s.listingRepo.Create(txCtx, userID, title, description, imageUrl, price)
+		err := s.categoryService.CheckLeaf(txCtx, params.CategoryID)
+		if err != nil {
+			return err
+		}
+
+		attributes, err := s.categoryService.ValidateAttributes(txCtx, params.CategoryID, params.Attributes)
+		if err != nil {
+			return err
+		}
+
+		listing, err := s.listingRepo.Create(txCtx, &models.Listing{
+			UserID:      userID,
+			Title:       params.Title,
+			Description: params.Description,
+			ImageURL:    params.ImageURL,
+			Price:       params.Price,
+			CategoryID:  params.CategoryID,
+			Attributes:  attributes,
+			Status:      status,
+		})
 		if err != nil {
 			return err
 		}
@@ -48,10 +164,425 @@ func (s *ListingService) Create(ctx context.Context, userID int64, title string,
 	return result, nil
 }
 
-func (s *ListingService) GetFeed(ctx context.Context, userID int64, page, limit int, sortBy, sortOrder string, minPrice, maxPrice int64) (*models.ListingsFeed, error) {
-	return s.listingRepo.GetFeed(ctx, userID, page, limit, sortBy, sortOrder, minPrice, maxPrice)
+func (s *ListingService) GetFeed(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error) {
+	if params.Status != "" && params.Status != models.ListingStatusActive {
+		if userID <= 0 {
+			return nil, ErrStatusFilterRequiresAuth
+		}
+		params.SellerID = userID
+	}
+	if params.SortBy == "relevance" && params.Query == "" {
+		return nil, ErrRelevanceRequiresQuery
+	}
+	// Listings sorted by relevance always come most relevant first.
+	if params.SortBy == "relevance" {
+		params.SortOrder = "desc"
+	}
+
+	// In cursor mode the sort order is taken from the cursor, so that a
+	// client cannot continue a feed in a different order than it started,
+	// and the filters have to be the ones the cursor was issued for.
+	if params.Cursor != "" {
+		after, err := decodeCursor([]byte(s.cfg.Feed.CursorSecret), params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Filters != feedFiltersDigest(params) {
+			return nil, ErrCursorFiltersMismatch
+		}
+		params.After = after
+		params.SortBy = after.SortBy
+		params.SortOrder = after.SortOrder
+		params.Page = 0
+	}
+
+	var feed *models.ListingsFeed
+
+	// The count and the page are read from the same snapshot so that the
+	// pagination metadata always matches the returned listings.
+	txOpts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := storage.WithTransactionOptions(ctx, s.listingRepo, txOpts, func(txCtx context.Context) error {
+		total, err := s.listingRepo.CountFeed(txCtx, params)
+		if err != nil {
+			return err
+		}
+
+		feed, err = s.listingRepo.GetFeed(txCtx, userID, params)
+		if err != nil {
+			return err
+		}
+		feed.Total = total
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The repo fetches one extra row to find out whether another page exists.
+	// Relevance ranks are not stable enough to be used as a keyset, so no
+	// cursor is issued for them and such feeds are paged with page/limit.
+	hasMore := len(feed.Listings) > params.Limit
+	if hasMore {
+		feed.Listings = feed.Listings[:params.Limit]
+	}
+	if hasMore && params.SortBy != "relevance" {
+		last := feed.Listings[len(feed.Listings)-1]
+		feed.NextCursor, err = encodeCursor([]byte(s.cfg.Feed.CursorSecret), models.FeedCursor{
+			SortBy:    params.SortBy,
+			SortOrder: params.SortOrder,
+			CreatedAt: last.CreatedAt,
+			Price:     last.Price,
+			ID:        last.ID,
+			Filters:   feedFiltersDigest(params),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	feed.TotalPages = (feed.Total + params.Limit - 1) / params.Limit
+	if params.After != nil {
+		feed.HasNext = hasMore
+		feed.HasPrev = true
+	} else {
+		feed.HasNext = params.Page < feed.TotalPages
+		feed.HasPrev = params.Page > 1
+	}
+
+	return feed, nil
+}
+
+// GetSellerFeed returns the feed restricted to the listings of one seller.
+// Sellers may filter their own listings by any status, everyone else sees
+// active listings only.
+func (s *ListingService) GetSellerFeed(ctx context.Context, userID, sellerID int64, params models.FeedParams) (*models.ListingsFeed, error) {
+	_, err := s.userService.GetByID(ctx, sellerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSellerNotFound
+		}
+		return nil, err
+	}
+
+	if params.Status != "" && params.Status != models.ListingStatusActive && userID > 0 && userID != sellerID {
+		return nil, ErrStatusFilterForbidden
+	}
+	params.SellerID = sellerID
+
+	return s.GetFeed(ctx, userID, params)
+}
+
+// GetMyListings returns the user's own listings in every status, or in one
+// status if the params filter by it, with the number of listings per status.
+func (s *ListingService) GetMyListings(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsDashboard, error) {
+	params.SellerID = userID
+	params.AllStatuses = params.Status == ""
+
+	feed, err := s.GetFeed(ctx, userID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.listingRepo.CountByStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ListingsDashboard{ListingsFeed: *feed, StatusCounts: counts}, nil
+}
+
+func (s *ListingService) GetByID(ctx context.Context, id, userID int64) (*models.Listing, error) {
+	listing, err := s.listingRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrListingNotFound
+		}
+		return nil, err
+	}
+
+	if !listing.IsOwner && !isPubliclyVisible(listing.Status) {
+		return nil, ErrListingNotFound
+	}
+
+	return listing, nil
+}
+
+func (s *ListingService) Update(ctx context.Context, userID, id int64, params UpdateParams) (*models.Listing, error) {
+	var result *models.Listing
+
+	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		existing, err := s.getOwnedForUpdate(txCtx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		if params.Title != nil {
+			existing.Title = *params.Title
+		}
+		if params.Description != nil {
+			existing.Description = *params.Description
+		}
+		if params.ImageURL != nil {
+			existing.ImageURL = *params.ImageURL
+		}
+		if params.Price != nil {
+			existing.Price = *params.Price
+		}
+		categoryChanged := params.CategoryID != nil && *params.CategoryID != existing.CategoryID
+		if categoryChanged {
+			err = s.categoryService.CheckLeaf(txCtx, *params.CategoryID)
+			if err != nil {
+				return err
+			}
+			existing.CategoryID = *params.CategoryID
+		}
+		// Attributes are revalidated when the category changes, since the
+		// new category may define a different schema.
+		if params.Attributes != nil || categoryChanged {
+			attributes := existing.Attributes
+			if params.Attributes != nil {
+				attributes = params.Attributes
+			}
+			existing.Attributes, err = s.categoryService.ValidateAttributes(txCtx, existing.CategoryID, attributes)
+			if err != nil {
+				return err
+			}
+		}
+		existing.IsOwner = true
+
+		result, err = s.listingRepo.Update(txCtx, existing)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *ListingService) ChangeStatus(ctx context.Context, userID, id int64, status string) (*models.Listing, error) {
+	var result *models.Listing
+
+	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		existing, err := s.getOwnedForUpdate(txCtx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(statusTransitions[existing.Status], status) {
+			return ErrInvalidStatusTransition
+		}
+
+		result, err = s.listingRepo.UpdateStatus(txCtx, id, status)
+		if err != nil {
+			return err
+		}
+		result.IsOwner = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *ListingService) Delete(ctx context.Context, userID, id int64) error {
+	return storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		_, err := s.getOwnedForUpdate(txCtx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		return s.listingRepo.Delete(txCtx, id)
+	})
+}
+
+// ArchiveAsModerator takes down a listing of any user by archiving it, so that
+// the owner can still see and fix it. The action is recorded in the audit log.
+func (s *ListingService) ArchiveAsModerator(ctx context.Context, moderatorID, id int64, reason string) (*models.Listing, error) {
+	var result *models.Listing
+
+	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		existing, err := s.getForModeration(txCtx, id)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(statusTransitions[existing.Status], models.ListingStatusArchived) {
+			return ErrInvalidStatusTransition
+		}
+
+		result, err = s.listingRepo.UpdateStatus(txCtx, id, models.ListingStatusArchived)
+		if err != nil {
+			return err
+		}
+		result.IsOwner = result.UserID == moderatorID
+
+		return s.recordModeration(txCtx, moderatorID, existing, "archive", reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteAsModerator removes a listing of any user. The action is recorded in
+// the audit log.
+func (s *ListingService) DeleteAsModerator(ctx context.Context, moderatorID, id int64, reason string) error {
+	return storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		existing, err := s.getForModeration(txCtx, id)
+		if err != nil {
+			return err
+		}
+
+		err = s.listingRepo.Delete(txCtx, id)
+		if err != nil {
+			return err
+		}
+
+		return s.recordModeration(txCtx, moderatorID, existing, "delete", reason)
+	})
+}
+
+// AddFavorite saves a listing of another user to the favorites of the user.
+// Only listings that are publicly visible can be added.
+func (s *ListingService) AddFavorite(ctx context.Context, userID, id int64) error {
+	return storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		listing, err := s.listingRepo.GetByID(txCtx, id, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrListingNotFound
+			}
+			return err
+		}
+
+		if listing.IsOwner {
+			return ErrFavoriteOwnListing
+		}
+		if !isPubliclyVisible(listing.Status) {
+			return ErrListingNotFound
+		}
+
+		return s.listingRepo.AddFavorite(txCtx, userID, id)
+	})
+}
+
+// RemoveFavorite removes a listing from the favorites of the user. Removing a
+// listing that is not a favorite has no effect.
+func (s *ListingService) RemoveFavorite(ctx context.Context, userID, id int64) error {
+	return s.listingRepo.RemoveFavorite(ctx, userID, id)
+}
+
+// GetFavorites returns the favorites of the user with the same filtering,
+// sorting and pagination as the feed. Favorites that are no longer publicly
+// visible, such as archived listings, are left out.
+func (s *ListingService) GetFavorites(ctx context.Context, userID int64, params models.FeedParams) (*models.ListingsFeed, error) {
+	params.Statuses = publicStatuses
+	if params.Status != "" {
+		if !isPubliclyVisible(params.Status) {
+			return nil, ErrStatusNotPublic
+		}
+		params.Statuses = []string{params.Status}
+	}
+	// The statuses are filtered through Statuses, so that the feed does not
+	// take the status filter as a request for the user's own listings.
+	params.Status = ""
+	params.FavoritedBy = userID
+
+	return s.GetFeed(ctx, userID, params)
+}
+
+// GetUserFavorites returns every favorite of the user, for the export of the
+// account data.
+func (s *ListingService) GetUserFavorites(ctx context.Context, userID int64) ([]models.Favorite, error) {
+	return s.listingRepo.GetFavoritesByUser(ctx, userID)
+}
+
+// GetUserListings returns every listing of the user, for the export of the
+// account data.
+func (s *ListingService) GetUserListings(ctx context.Context, userID int64) ([]models.Listing, error) {
+	return s.listingRepo.GetByUser(ctx, userID)
+}
+
+// RemoveUserListings deletes or anonymizes the listings of an account being
+// deleted, according to the configured policy, and returns the number of
+// listings deleted or kept. It is meant to run in the transaction deleting
+// the account.
+func (s *ListingService) RemoveUserListings(ctx context.Context, userID int64) (int64, error) {
+	if s.cfg.Account.DeletionListingPolicy == config.ListingPolicyAnonymize {
+		return s.listingRepo.AnonymizeByUser(ctx, userID)
+	}
+
+	return s.listingRepo.DeleteByUser(ctx, userID)
+}
+
+func (s *ListingService) ReindexSearch(ctx context.Context) error {
+	return s.listingRepo.ReindexSearch(ctx)
+}
+
+func (s *ListingService) getOwnedForUpdate(ctx context.Context, userID, id int64) (*models.Listing, error) {
+	listing, err := s.listingRepo.GetForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrListingNotFound
+		}
+		return nil, err
+	}
+
+	if listing.UserID != userID {
+		return nil, ErrNotListingOwner
+	}
+
+	return listing, nil
 }
 
-func (s *ListingService) CheckExists(ctx context.Context, id int64) (bool, error) {
-	return s.listingRepo.CheckExists(ctx, id)
+func (s *ListingService) getForModeration(ctx context.Context, id int64) (*models.Listing, error) {
+	listing, err := s.listingRepo.GetForUpdate(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrListingNotFound
+		}
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+func (s *ListingService) recordModeration(ctx context.Context, moderatorID int64, listing *models.Listing, action, reason string) error {
+	// Listings kept from deleted accounts have no owner to attribute the
+	// event to.
+	var ownerID *int64
+	if listing.UserID > 0 {
+		ownerID = &listing.UserID
+	}
+
+	return s.auditService.Record(ctx, models.AuditEntry{
+		Event:  models.AuditEventListingModerated,
+		UserID: ownerID,
+		Details: map[string]any{
+			"moderator_id": moderatorID,
+			"listing_id":   listing.ID,
+			"title":        listing.Title,
+			"action":       action,
+			"reason":       reason,
+		},
+	})
+}
+
+// publicStatuses lists the statuses in which listings can be viewed by users
+// other than the owner.
+var publicStatuses = []string{models.ListingStatusActive, models.ListingStatusReserved, models.ListingStatusSold}
+
+// isPubliclyVisible reports whether listings in the given status can be
+// viewed by users other than the owner.
+func isPubliclyVisible(status string) bool {
+	return slices.Contains(publicStatuses, status)
 }
@@ -0,0 +1,199 @@
+package listing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/notifier"
+	"github.com/ocenb/marketplace/internal/storage"
+	"github.com/ocenb/marketplace/internal/utils"
+)
+
+// savedSearchNotificationListings is the number of matching listings listed
+// in one notification, the rest are only counted.
+const savedSearchNotificationListings = 10
+
+// CreateSavedSearch saves a feed query of the user under a name. Only the
+// filters and the sort order are kept; pagination and status filters do not
+// apply to saved searches, which always match active listings.
+func (s *ListingService) CreateSavedSearch(ctx context.Context, userID int64, name string, query models.FeedParams) (*models.SavedSearch, error) {
+	saved := models.FeedParams{
+		SortBy:     query.SortBy,
+		SortOrder:  query.SortOrder,
+		MinPrice:   query.MinPrice,
+		MaxPrice:   query.MaxPrice,
+		Query:      strings.TrimSpace(query.Query),
+		CategoryID: query.CategoryID,
+		Attributes: query.Attributes,
+	}
+	if saved.SortBy == "" {
+		saved.SortBy = "createdAt"
+		if saved.Query != "" {
+			saved.SortBy = "relevance"
+		}
+	}
+	if saved.SortOrder == "" || saved.SortBy == "relevance" {
+		saved.SortOrder = "desc"
+	}
+	if saved.SortBy == "relevance" && saved.Query == "" {
+		return nil, ErrRelevanceRequiresQuery
+	}
+
+	var result *models.SavedSearch
+
+	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		count, err := s.listingRepo.CountSavedSearches(txCtx, userID)
+		if err != nil {
+			return err
+		}
+		if count >= s.cfg.SavedSearch.MaxPerUser {
+			return ErrTooManySavedSearches
+		}
+
+		result, err = s.listingRepo.CreateSavedSearch(txCtx, &models.SavedSearch{
+			UserID: userID,
+			Name:   name,
+			Query:  saved,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetSavedSearches returns the saved searches of the user, also used for the
+// export of the account data.
+func (s *ListingService) GetSavedSearches(ctx context.Context, userID int64) ([]models.SavedSearch, error) {
+	return s.listingRepo.GetSavedSearchesByUser(ctx, userID)
+}
+
+func (s *ListingService) DeleteSavedSearch(ctx context.Context, userID, id int64) error {
+	deleted, err := s.listingRepo.DeleteSavedSearch(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSavedSearchNotFound
+	}
+
+	return nil
+}
+
+// CheckSavedSearches matches the listings that became active since the
+// previous check against every saved search and notifies the owners of the
+// searches with new matches. A failed search does not stop the others. It
+// returns the number of notifications sent.
+func (s *ListingService) CheckSavedSearches(ctx context.Context) (int, error) {
+	searches, err := s.listingRepo.GetSavedSearches(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent, failed := 0, 0
+	for _, search := range searches {
+		notified, err := s.checkSavedSearch(ctx, search)
+		if err != nil {
+			s.log.Error("Failed to check saved search", slog.Int64("saved_search_id", search.ID), utils.ErrLog(err))
+			failed++
+			continue
+		}
+		if notified {
+			sent++
+		}
+	}
+
+	s.log.Info("Saved searches checked",
+		slog.Int("searches", len(searches)),
+		slog.Int("notifications", sent),
+		slog.Int("failed", failed),
+	)
+
+	if failed > 0 {
+		return sent, fmt.Errorf("failed to check %d of %d saved searches", failed, len(searches))
+	}
+
+	return sent, nil
+}
+
+// checkSavedSearch notifies the owner of the search about the listings that
+// became active since its last check and reports whether a notification was
+// sent. The check reaches the configured overlap further back, because a
+// listing is stamped when its transaction starts and may commit after a check
+// that started later; the listings the search has already matched are
+// skipped. The check is committed before the notification is sent, so a
+// failed notification is not retried but a listing is never announced twice.
+func (s *ListingService) checkSavedSearch(ctx context.Context, search models.SavedSearch) (bool, error) {
+	var matches []models.Listing
+	var total int
+
+	err := storage.WithTransaction(ctx, s.listingRepo, func(txCtx context.Context) error {
+		checkedAt, err := s.listingRepo.ClaimSavedSearch(txCtx, search.ID, search.LastCheckedAt)
+		if err != nil {
+			return err
+		}
+
+		// Listings that were active when the search was saved are not new
+		// to its owner.
+		since := search.LastCheckedAt.Add(-s.cfg.SavedSearch.MatchOverlap)
+		if since.Before(search.CreatedAt) {
+			since = search.CreatedAt
+		}
+		err = s.listingRepo.DeleteSavedSearchMatches(txCtx, search.ID, since)
+		if err != nil {
+			return err
+		}
+
+		matches, total, err = s.listingRepo.RecordSavedSearchMatches(txCtx, search, since, checkedAt, savedSearchNotificationListings)
+		return err
+	})
+	if err != nil {
+		// The search has been checked by another instance meanwhile, or
+		// deleted.
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if total == 0 {
+		return false, nil
+	}
+
+	user, err := s.userService.GetByID(ctx, search.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.notifier.Notify(ctx, savedSearchNotification(user, search, matches, total))
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func savedSearchNotification(user *models.User, search models.SavedSearch, matches []models.Listing, total int) notifier.Notification {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello, %s!\r\n\r\n", user.Login)
+	fmt.Fprintf(&body, "There are %d new listings matching your saved search \"%s\":\r\n\r\n", total, search.Name)
+	for _, listing := range matches {
+		fmt.Fprintf(&body, "- %s, %d.%02d RUB: /listing/%d\r\n", listing.Title, listing.Price/100, listing.Price%100, listing.ID)
+	}
+	if more := total - len(matches); more > 0 {
+		fmt.Fprintf(&body, "- and %d more\r\n", more)
+	}
+
+	return notifier.Notification{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Subject: fmt.Sprintf("New listings for \"%s\"", search.Name),
+		Body:    body.String(),
+	}
+}
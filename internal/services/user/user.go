@@ -2,17 +2,32 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strconv"
 
 	"github.com/ocenb/marketplace/internal/models"
 	"github.com/ocenb/marketplace/internal/repos/user"
 )
 
 type UserServiceInterface interface {
-	Create(ctx context.Context, login, passwordHash string) (*models.UserPublic, error)
+	Create(ctx context.Context, login, email, passwordHash, role string) (*models.UserPublic, error)
 	GetByLogin(ctx context.Context, login string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByID(ctx context.Context, id int64) (*models.User, error)
 	CheckExists(ctx context.Context, login string) (bool, error)
+	CheckEmailExists(ctx context.Context, email string) (bool, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	UpdateRole(ctx context.Context, id int64, role string) error
+	Delete(ctx context.Context, id int64) error
+	GetProfile(ctx context.Context, idOrLogin string) (*models.UserProfile, error)
+	GetProfileByLogin(ctx context.Context, login string) (*models.UserProfile, error)
+	GetCurrent(ctx context.Context, id int64) (*models.CurrentUser, error)
+	UpdateProfile(ctx context.Context, id int64, update models.ProfileUpdate) (*models.CurrentUser, error)
 }
 
+var ErrUserNotFound = errors.New("user not found")
+
 type UserService struct {
 	userRepo user.UserRepoInterface
 }
@@ -23,8 +38,8 @@ func New(userRepo user.UserRepoInterface) UserServiceInterface {
 	}
 }
 
-func (s *UserService) Create(ctx context.Context, login, passwordHash string) (*models.UserPublic, error) {
-	user, err := s.userRepo.Create(ctx, login, passwordHash)
+func (s *UserService) Create(ctx context.Context, login, email, passwordHash, role string) (*models.UserPublic, error) {
+	user, err := s.userRepo.Create(ctx, login, email, passwordHash, role)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +56,24 @@ func (s *UserService) GetByLogin(ctx context.Context, login string) (*models.Use
 	return user, nil
 }
 
+func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (s *UserService) CheckExists(ctx context.Context, login string) (bool, error) {
 	exists, err := s.userRepo.CheckExists(ctx, login)
 	if err != nil {
@@ -49,3 +82,79 @@ func (s *UserService) CheckExists(ctx context.Context, login string) (bool, erro
 
 	return exists, nil
 }
+
+func (s *UserService) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	exists, err := s.userRepo.CheckEmailExists(ctx, email)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (s *UserService) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	return s.userRepo.UpdatePassword(ctx, id, passwordHash)
+}
+
+func (s *UserService) UpdateRole(ctx context.Context, id int64, role string) error {
+	return s.userRepo.UpdateRole(ctx, id, role)
+}
+
+func (s *UserService) Delete(ctx context.Context, id int64) error {
+	return s.userRepo.Delete(ctx, id)
+}
+
+// GetProfile returns the public profile of a user by ID or login. Logins may
+// consist of digits only, so a numeric value that is not a known ID is looked
+// up as a login as well. A numeric login equal to the ID of another user
+// resolves to that user; GetProfileByLogin has no such ambiguity.
+func (s *UserService) GetProfile(ctx context.Context, idOrLogin string) (*models.UserProfile, error) {
+	if id, err := strconv.ParseInt(idOrLogin, 10, 64); err == nil && id > 0 {
+		profile, err := s.userRepo.GetProfileByID(ctx, id)
+		if err == nil {
+			return profile, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return s.GetProfileByLogin(ctx, idOrLogin)
+}
+
+// GetProfileByLogin returns the public profile of a user by login only.
+func (s *UserService) GetProfileByLogin(ctx context.Context, login string) (*models.UserProfile, error) {
+	profile, err := s.userRepo.GetProfileByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (s *UserService) GetCurrent(ctx context.Context, id int64) (*models.CurrentUser, error) {
+	user, err := s.userRepo.GetCurrent(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, id int64, update models.ProfileUpdate) (*models.CurrentUser, error) {
+	user, err := s.userRepo.UpdateProfile(ctx, id, update)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
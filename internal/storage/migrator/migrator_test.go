@@ -0,0 +1,361 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/ocenb/marketplace/internal/storage/postgres/migrations"
+)
+
+var testMigrations = fstest.MapFS{
+	"000001_items.up.sql":        {Data: []byte(`CREATE TABLE items (id SERIAL PRIMARY KEY)`)},
+	"000001_items.down.sql":      {Data: []byte(`DROP TABLE items`)},
+	"000002_item_names.up.sql":   {Data: []byte(`ALTER TABLE items ADD COLUMN name TEXT`)},
+	"000002_item_names.down.sql": {Data: []byte(`ALTER TABLE items DROP COLUMN name`)},
+	"000010_tags.up.sql":         {Data: []byte(`CREATE TABLE tags (id SERIAL PRIMARY KEY)`)},
+	"000010_tags.down.sql":       {Data: []byte(`DROP TABLE tags`)},
+	"README.md":                  {Data: []byte(`not a migration`)},
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := load(testMigrations)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	var got []string
+	for _, migration := range loaded {
+		got = append(got, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		if migration.Up == "" || migration.Down == "" {
+			t.Errorf("migration %d_%s is missing a script", migration.Version, migration.Name)
+		}
+	}
+	if fmt.Sprint(got) != "[1_items 2_item_names 10_tags]" {
+		t.Errorf("load() = %v, want versions 1, 2 and 10 in order", got)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"no up script": {
+			"000001_items.down.sql": {Data: []byte(`DROP TABLE items`)},
+		},
+		"one version, two names": {
+			"000001_items.up.sql":   {Data: []byte(`CREATE TABLE items (id INT)`)},
+			"000001_things.up.sql":  {Data: []byte(`CREATE TABLE things (id INT)`)},
+			"000001_items.down.sql": {Data: []byte(`DROP TABLE items`)},
+		},
+	} {
+		if _, err := load(fsys); err == nil {
+			t.Errorf("%s: load() succeeded, want an error", name)
+		}
+	}
+}
+
+// TestEmbeddedMigrations checks that the schema migrations have consecutive
+// versions starting at 1 and can all be rolled back.
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	for i, migration := range loaded {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("migration %d_%s, want version %d", migration.Version, migration.Name, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestUpDownForceStatus(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, testMigrations)
+
+	assertApplied(t, m, nil)
+
+	applied, err := m.Up(ctx)
+	if err != nil || applied != 3 {
+		t.Fatalf("Up() = %d, %v, want 3 migrations applied", applied, err)
+	}
+	assertApplied(t, m, []int64{1, 2, 10})
+	assertTables(t, db, map[string]bool{"items": true, "tags": true})
+
+	applied, err = m.Up(ctx)
+	if err != nil || applied != 0 {
+		t.Fatalf("repeated Up() = %d, %v, want nothing applied", applied, err)
+	}
+
+	rolledBack, err := m.Down(ctx, 2)
+	if err != nil || rolledBack != 2 {
+		t.Fatalf("Down(2) = %d, %v, want 2 migrations rolled back", rolledBack, err)
+	}
+	assertApplied(t, m, []int64{1})
+	assertTables(t, db, map[string]bool{"items": true, "tags": false})
+	if _, err := db.Exec(`INSERT INTO items (name) VALUES ('x')`); err == nil {
+		t.Errorf("column added by migration 2 is still there after rolling it back")
+	}
+
+	if err := m.Force(ctx, 10); err != nil {
+		t.Fatalf("Force(10) error = %v", err)
+	}
+	assertApplied(t, m, []int64{1, 2, 10})
+	// Force only records versions, the rolled back table stays missing.
+	assertTables(t, db, map[string]bool{"items": true, "tags": false})
+
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatalf("Force(1) error = %v", err)
+	}
+	assertApplied(t, m, []int64{1})
+
+	if err := m.Force(ctx, 5); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("Force(5) error = %v, want ErrUnknownVersion", err)
+	}
+
+	if err := m.Force(ctx, 0); err != nil {
+		t.Fatalf("Force(0) error = %v", err)
+	}
+	assertApplied(t, m, nil)
+}
+
+func TestDownWithoutScript(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, fstest.MapFS{
+		"000001_items.up.sql": {Data: []byte(`CREATE TABLE items (id SERIAL PRIMARY KEY)`)},
+	})
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrMissingDown) {
+		t.Fatalf("Down(1) error = %v, want ErrMissingDown", err)
+	}
+	assertApplied(t, m, []int64{1})
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, fstest.MapFS{
+		"000001_items.up.sql":  {Data: []byte(`CREATE TABLE items (id SERIAL PRIMARY KEY)`)},
+		"000002_broken.up.sql": {Data: []byte(`CREATE TABLE tags (id INT); SELECT * FROM no_such_table`)},
+	})
+
+	applied, err := m.Up(ctx)
+	if err == nil || applied != 1 {
+		t.Fatalf("Up() = %d, %v, want an error after 1 applied migration", applied, err)
+	}
+	assertApplied(t, m, []int64{1})
+	assertTables(t, db, map[string]bool{"items": true, "tags": false})
+}
+
+func TestLock(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, testMigrations)
+
+	holder, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to get connection: %v", err)
+	}
+	defer func() {
+		if err := holder.Close(); err != nil {
+			t.Errorf("failed to close connection: %v", err)
+		}
+	}()
+	if _, err := holder.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		t.Fatalf("failed to take the migrations lock: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Up(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Up() returned %v while another session held the lock", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	assertTables(t, db, map[string]bool{"items": false})
+
+	if _, err := holder.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+		t.Fatalf("failed to release the migrations lock: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Up() did not finish after the lock was released")
+	}
+	assertApplied(t, m, []int64{1, 2, 10})
+}
+
+// TestSchemaMigrations runs the schema migrations on an empty database and on
+// a database created from the former deployments/init.sql, which matches the
+// first migration.
+func TestSchemaMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("empty database", func(t *testing.T) {
+		db := testDB(t)
+		m := newTestMigrator(t, db, migrations.FS)
+
+		applied, err := m.Up(ctx)
+		if err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+		if rolledBack, err := m.Down(ctx, applied); err != nil || rolledBack != applied {
+			t.Fatalf("Down(%d) = %d, %v", applied, rolledBack, err)
+		}
+		assertTables(t, db, map[string]bool{"users": false, "listings": false})
+		if reapplied, err := m.Up(ctx); err != nil || reapplied != applied {
+			t.Fatalf("Up() after rolling back = %d, %v, want %d", reapplied, err, applied)
+		}
+	})
+
+	t.Run("baseline database", func(t *testing.T) {
+		db := testDB(t)
+		m := newTestMigrator(t, db, migrations.FS)
+
+		baseline, err := migrations.FS.ReadFile("000001_init.up.sql")
+		if err != nil {
+			t.Fatalf("failed to read the first migration: %v", err)
+		}
+		_, err = db.Exec(string(baseline))
+		if err != nil {
+			t.Fatalf("failed to create the baseline schema: %v", err)
+		}
+		_, err = db.Exec(`
+			INSERT INTO users (login, password_hash) VALUES ('seller', 'hash');
+			INSERT INTO listings (user_id, title, price) SELECT id, 'Old listing', 100 FROM users;
+		`)
+		if err != nil {
+			t.Fatalf("failed to insert baseline data: %v", err)
+		}
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatalf("Up() on the baseline schema error = %v", err)
+		}
+
+		var status string
+		var categoryID int64
+		err = db.QueryRow(`SELECT status, category_id FROM listings WHERE title = 'Old listing'`).Scan(&status, &categoryID)
+		if err != nil {
+			t.Fatalf("failed to read the baseline listing: %v", err)
+		}
+		if status != "active" || categoryID != 19 {
+			t.Errorf("baseline listing has status %q and category %d, want active in category 19", status, categoryID)
+		}
+	})
+}
+
+// testDB returns a connection to TEST_DATABASE_URL limited to a new schema
+// that is dropped after the test. Tests that need a database are skipped
+// without it.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	rawURL := os.Getenv("TEST_DATABASE_URL")
+	if rawURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", rawURL)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	schema := fmt.Sprintf("migrator_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+
+	dbURL, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("failed to parse TEST_DATABASE_URL: %v", err)
+	}
+	query := dbURL.Query()
+	query.Set("search_path", schema)
+	dbURL.RawQuery = query.Encode()
+
+	db, err := sql.Open("postgres", dbURL.String())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close database: %v", err)
+		}
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("failed to drop schema: %v", err)
+		}
+		if err := admin.Close(); err != nil {
+			t.Errorf("failed to close database: %v", err)
+		}
+	})
+
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, fsys fs.FS) *Migrator {
+	t.Helper()
+
+	m, err := New(db, slog.New(slog.NewTextHandler(io.Discard, nil)), fsys)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return m
+}
+
+func assertApplied(t *testing.T, m *Migrator, want []int64) {
+	t.Helper()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	var applied []int64
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			applied = append(applied, status.Version)
+		}
+	}
+	if fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Fatalf("applied versions = %v, want %v", applied, want)
+	}
+}
+
+func assertTables(t *testing.T, db *sql.DB, want map[string]bool) {
+	t.Helper()
+
+	for table, exists := range want {
+		var found bool
+		err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, table).Scan(&found)
+		if err != nil {
+			t.Fatalf("failed to look up table %s: %v", table, err)
+		}
+		if found != exists {
+			t.Errorf("table %s exists = %t, want %t", table, found, exists)
+		}
+	}
+}
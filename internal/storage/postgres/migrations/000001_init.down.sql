@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS listings;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS users;
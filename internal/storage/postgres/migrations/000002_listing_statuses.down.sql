@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_listings_status_price;
+DROP INDEX IF EXISTS idx_listings_status_created_at;
+
+ALTER TABLE listings DROP CONSTRAINT IF EXISTS status_valid;
+ALTER TABLE listings DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE listings DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE listings DROP COLUMN IF EXISTS status;
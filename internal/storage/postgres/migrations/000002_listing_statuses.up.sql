@@ -0,0 +1,11 @@
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE listings SET status_changed_at = created_at, updated_at = created_at;
+
+ALTER TABLE listings DROP CONSTRAINT IF EXISTS status_valid;
+ALTER TABLE listings ADD CONSTRAINT status_valid CHECK (status IN ('draft', 'active', 'reserved', 'sold', 'archived'));
+
+CREATE INDEX IF NOT EXISTS idx_listings_status_created_at ON listings(status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_listings_status_price ON listings(status, price, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_listings_search_vector;
+ALTER TABLE listings DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_listings_category_id;
+
+ALTER TABLE listings DROP COLUMN IF EXISTS attributes;
+ALTER TABLE listings DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS category_attributes;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    parent_id INT REFERENCES categories(id) ON DELETE RESTRICT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id SERIAL PRIMARY KEY,
+    category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    key VARCHAR(50) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    options TEXT[],
+    min_value BIGINT,
+    max_value BIGINT,
+
+    CONSTRAINT category_attribute_key_unique UNIQUE (category_id, key),
+    CONSTRAINT attribute_type_valid CHECK (type IN ('enum', 'int', 'bool', 'string'))
+);
+
+-- The column becomes NOT NULL once the existing listings are moved to a
+-- category by the seed migration.
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories(id) ON DELETE RESTRICT;
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_listings_category_id ON listings(category_id);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_category_attributes_category_id ON category_attributes(category_id);
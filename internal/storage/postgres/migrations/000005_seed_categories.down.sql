@@ -0,0 +1,6 @@
+ALTER TABLE listings ALTER COLUMN category_id DROP NOT NULL;
+UPDATE listings SET category_id = NULL WHERE category_id BETWEEN 1 AND 19;
+
+DELETE FROM category_attributes WHERE category_id BETWEEN 1 AND 19;
+DELETE FROM categories WHERE id BETWEEN 1 AND 19 AND parent_id IS NOT NULL;
+DELETE FROM categories WHERE id BETWEEN 1 AND 19;
@@ -0,0 +1,48 @@
+INSERT INTO categories (id, parent_id, name, slug) VALUES
+    (1, NULL, 'Электроника', 'electronics'),
+    (2, 1, 'Телефоны', 'phones'),
+    (3, 1, 'Ноутбуки', 'laptops'),
+    (4, 1, 'Аудио и видео', 'audio-video'),
+    (5, NULL, 'Транспорт', 'transport'),
+    (6, 5, 'Автомобили', 'cars'),
+    (7, 5, 'Мотоциклы', 'motorcycles'),
+    (8, 5, 'Велосипеды', 'bicycles'),
+    (9, NULL, 'Одежда и обувь', 'clothing'),
+    (10, 9, 'Одежда', 'apparel'),
+    (11, 9, 'Обувь', 'shoes'),
+    (12, 9, 'Аксессуары', 'accessories'),
+    (13, NULL, 'Дом и сад', 'home'),
+    (14, 13, 'Мебель', 'furniture'),
+    (15, 13, 'Бытовая техника', 'appliances'),
+    (16, NULL, 'Хобби и отдых', 'hobby'),
+    (17, 16, 'Книги', 'books'),
+    (18, 16, 'Спорт', 'sports'),
+    (19, NULL, 'Другое', 'other')
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval('categories_id_seq', (SELECT MAX(id) FROM categories));
+
+INSERT INTO category_attributes (category_id, key, name, type, required, options, min_value, max_value) VALUES
+    (1, 'condition', 'Состояние', 'enum', TRUE, ARRAY['new', 'used', 'refurbished'], NULL, NULL),
+    (1, 'brand', 'Производитель', 'string', FALSE, NULL, NULL, NULL),
+    (2, 'memory_gb', 'Встроенная память, ГБ', 'int', FALSE, NULL, 1, 4096),
+    (3, 'ram_gb', 'Оперативная память, ГБ', 'int', FALSE, NULL, 1, 1024),
+    (5, 'condition', 'Состояние', 'enum', TRUE, ARRAY['new', 'used', 'damaged'], NULL, NULL),
+    (5, 'year', 'Год выпуска', 'int', TRUE, NULL, 1900, 2100),
+    (5, 'brand', 'Марка', 'string', FALSE, NULL, NULL, NULL),
+    (6, 'mileage', 'Пробег, км', 'int', FALSE, NULL, 0, 10000000),
+    (7, 'mileage', 'Пробег, км', 'int', FALSE, NULL, 0, 10000000),
+    (9, 'condition', 'Состояние', 'enum', TRUE, ARRAY['new', 'used'], NULL, NULL),
+    (9, 'brand', 'Бренд', 'string', FALSE, NULL, NULL, NULL),
+    (10, 'size', 'Размер', 'enum', FALSE, ARRAY['XS', 'S', 'M', 'L', 'XL', 'XXL'], NULL, NULL),
+    (11, 'size', 'Размер (EU)', 'int', FALSE, NULL, 16, 50),
+    (14, 'condition', 'Состояние', 'enum', FALSE, ARRAY['new', 'used'], NULL, NULL),
+    (15, 'condition', 'Состояние', 'enum', FALSE, ARRAY['new', 'used', 'refurbished'], NULL, NULL),
+    (15, 'brand', 'Производитель', 'string', FALSE, NULL, NULL, NULL),
+    (17, 'author', 'Автор', 'string', FALSE, NULL, NULL, NULL),
+    (18, 'used', 'Был в использовании', 'bool', FALSE, NULL, NULL, NULL)
+ON CONFLICT (category_id, key) DO NOTHING;
+
+-- Listings created before categories existed go to "Другое".
+UPDATE listings SET category_id = 19 WHERE category_id IS NULL;
+ALTER TABLE listings ALTER COLUMN category_id SET NOT NULL;
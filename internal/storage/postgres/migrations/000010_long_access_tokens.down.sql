@@ -0,0 +1,2 @@
+DELETE FROM tokens WHERE LENGTH(token) > 255;
+ALTER TABLE tokens ALTER COLUMN token TYPE VARCHAR(255);
@@ -0,0 +1,2 @@
+-- Tokens signed with RS256 do not fit into VARCHAR(255).
+ALTER TABLE tokens ALTER COLUMN token TYPE TEXT;
@@ -0,0 +1,9 @@
+-- Revoked sessions that still have unexpired access tokens. Replicas keep
+-- this table in memory to validate tokens without a database round-trip.
+CREATE TABLE IF NOT EXISTS revocations (
+    session_id INT PRIMARY KEY REFERENCES sessions(id) ON DELETE CASCADE,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revocations_expires_at ON revocations(expires_at);
@@ -0,0 +1,25 @@
+-- Failed login attempts per login and per client IP. A row expires once its
+-- failure window and block have both passed.
+CREATE TABLE IF NOT EXISTS login_throttle (
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    blocked_until TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttle_expires_at ON login_throttle(expires_at);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    event VARCHAR(64) NOT NULL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS contact_preferences;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,7 @@
+DELETE FROM revocations WHERE session_id NOT IN (SELECT id FROM sessions);
+ALTER TABLE revocations DROP CONSTRAINT IF EXISTS revocations_session_id_fkey;
+ALTER TABLE revocations ADD CONSTRAINT revocations_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+DELETE FROM listings WHERE user_id IS NULL;
+ALTER TABLE listings ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,6 @@
+-- Listings of deleted accounts can be kept without an author.
+ALTER TABLE listings ALTER COLUMN user_id DROP NOT NULL;
+
+-- Revocations have to outlive the sessions deleted together with an account,
+-- until the access tokens of those sessions expire.
+ALTER TABLE revocations DROP CONSTRAINT IF EXISTS revocations_session_id_fkey;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, listing_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_favorites_listing_id ON favorites(listing_id);
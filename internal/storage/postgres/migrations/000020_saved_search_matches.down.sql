@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_listings_status_changed_at;
+DROP TABLE IF EXISTS saved_search_matches;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    saved_search_id INT NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    matched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (saved_search_id, listing_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_search_matches_listing_id ON saved_search_matches(listing_id);
+CREATE INDEX IF NOT EXISTS idx_listings_status_changed_at ON listings(status, status_changed_at);
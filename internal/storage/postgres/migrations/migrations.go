@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the marketplace schema.
+//
+// Every migration consists of a NNNNNN_name.up.sql file and a matching
+// NNNNNN_name.down.sql file. Versions are applied in ascending order.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
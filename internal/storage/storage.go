@@ -21,7 +21,11 @@ type BeginTx interface {
 }
 
 func WithTransaction(ctx context.Context, repo BeginTx, fn func(txCtx context.Context) error) error {
-	tx, err := repo.BeginTx(ctx, nil)
+	return WithTransactionOptions(ctx, repo, nil, fn)
+}
+
+func WithTransactionOptions(ctx context.Context, repo BeginTx, opts *sql.TxOptions, fn func(txCtx context.Context) error) error {
+	tx, err := repo.BeginTx(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %w", err)
 	}
@@ -58,4 +58,12 @@ var (
 	ForbiddenError = func(w http.ResponseWriter, log *slog.Logger) {
 		WriteJSON(w, ErrorResponse{Message: "forbidden"}, http.StatusForbidden, log)
 	}
+
+	TooManyRequestsError = func(w http.ResponseWriter, log *slog.Logger, msg string) {
+		WriteJSON(w, ErrorResponse{Message: msg}, http.StatusTooManyRequests, log)
+	}
+
+	BadGatewayError = func(w http.ResponseWriter, log *slog.Logger, msg string) {
+		WriteJSON(w, ErrorResponse{Message: msg}, http.StatusBadGateway, log)
+	}
 )
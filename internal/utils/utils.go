@@ -7,6 +7,14 @@ import (
 
 type UserIDKey struct{}
 
+type SessionIDKey struct{}
+
+type RoleKey struct{}
+
+// ScopesKey holds the scopes of the API key a request was authenticated
+// with. It is not set for requests authenticated with an access token.
+type ScopesKey struct{}
+
 func ErrLog(err error) slog.Attr {
 	if err == nil {
 		return slog.Any("error", nil)
@@ -26,3 +34,21 @@ func GetInfoFromContext(ctx context.Context, log *slog.Logger) (int64, bool) {
 	}
 	return userID, true
 }
+
+func GetSessionFromContext(ctx context.Context, log *slog.Logger) (int64, bool) {
+	sessionID, ok := ctx.Value(SessionIDKey{}).(int64)
+	if !ok {
+		log.Info("Failed to get session from context")
+		return -1, false
+	}
+	return sessionID, true
+}
+
+func GetRoleFromContext(ctx context.Context, log *slog.Logger) (string, bool) {
+	role, ok := ctx.Value(RoleKey{}).(string)
+	if !ok {
+		log.Info("Failed to get role from context")
+		return "", false
+	}
+	return role, true
+}
@@ -1,14 +1,29 @@
 package tests
 
 import (
+	"archive/zip"
 	"bytes"
+	"cmp"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	authhandler "github.com/ocenb/marketplace/internal/handlers/auth"
 	listinghandler "github.com/ocenb/marketplace/internal/handlers/listing"
+	userhandler "github.com/ocenb/marketplace/internal/handlers/user"
+	"github.com/ocenb/marketplace/internal/jwtkeys"
 	"github.com/ocenb/marketplace/internal/models"
+	"github.com/ocenb/marketplace/internal/totp"
 	"github.com/ocenb/marketplace/tests/suite"
 )
 
@@ -16,7 +31,7 @@ func TestMarketplaceWorkflow(t *testing.T) {
 	s := suite.New(t)
 
 	// 1. Register User
-	registerReq := authhandler.RegisterRequest{Login: "testuser", Password: "password123"}
+	registerReq := authhandler.RegisterRequest{Login: "testuser", Email: "testuser@example.com", Password: "password123"}
 	registerBody, _ := json.Marshal(registerReq)
 	resp, err := s.Client.Post(s.BaseURL+"/auth/register", "application/json", bytes.NewReader(registerBody))
 	if err != nil {
@@ -56,12 +71,54 @@ func TestMarketplaceWorkflow(t *testing.T) {
 
 	authToken := "Bearer " + loginResp.Token
 
-	// 3. Create Listing
+	// 3. Get Categories and Create Listing
+	resp, err = s.Client.Get(s.BaseURL + "/categories")
+	if err != nil {
+		s.Fatalf("Failed to get categories: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get Categories expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var categories []*models.Category
+	err = json.NewDecoder(resp.Body).Decode(&categories)
+	if err != nil {
+		s.Fatalf("Failed to decode categories response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	leafCategory := findLeafCategory(categories)
+	if leafCategory == nil {
+		s.Fatalf("Expected at least one leaf category, got none")
+	}
+
+	resp, err = s.Client.Get(fmt.Sprintf("%s/categories/%d/attributes", s.BaseURL, leafCategory.ID))
+	if err != nil {
+		s.Fatalf("Failed to get category attributes: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get Category Attributes expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var categoryAttributes []models.CategoryAttribute
+	err = json.NewDecoder(resp.Body).Decode(&categoryAttributes)
+	if err != nil {
+		s.Fatalf("Failed to decode category attributes response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
 	createListingReq := listinghandler.CreateListingRequest{
 		Title:       "Test Listing 1",
 		Description: "A description for test listing 1.",
 		ImageURL:    "https://images.unsplash.com/photo-1752564627655-168bd1be3202?q=80&w=928&auto=format&fit=crop&ixlib=rb-4.1.0&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D",
 		Price:       150000,
+		CategoryID:  leafCategory.ID,
+		Attributes:  requiredAttributeValues(categoryAttributes),
 	}
 	createListingBody, _ := json.Marshal(createListingReq)
 	req, err := http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createListingBody))
@@ -95,6 +152,8 @@ func TestMarketplaceWorkflow(t *testing.T) {
 		Description: "This listing has an invalid image URL.",
 		ImageURL:    "not-a-valid-url",
 		Price:       200000,
+		CategoryID:  leafCategory.ID,
+		Attributes:  requiredAttributeValues(categoryAttributes),
 	}
 	createBadImageListingBody, _ := json.Marshal(createBadImageListingReq)
 	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createBadImageListingBody))
@@ -122,6 +181,7 @@ func TestMarketplaceWorkflow(t *testing.T) {
 		Description: "This listing should not be created.",
 		ImageURL:    "http://example.com/unauth.jpg",
 		Price:       300000,
+		CategoryID:  leafCategory.ID,
 	}
 	createUnauthorizedListingBody, _ := json.Marshal(createUnauthorizedListingReq)
 	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createUnauthorizedListingBody))
@@ -170,6 +230,12 @@ func TestMarketplaceWorkflow(t *testing.T) {
 	if len(feedResp.Listings) == 0 {
 		s.Fatalf("Expected at least one listing in the feed, got 0")
 	}
+	if feedResp.Total < len(feedResp.Listings) {
+		s.Errorf("Feed total %d is less than the number of listings on the page %d", feedResp.Total, len(feedResp.Listings))
+	}
+	if feedResp.TotalPages < 1 || feedResp.HasPrev {
+		s.Errorf("Unexpected first page metadata: total_pages=%d has_prev=%t", feedResp.TotalPages, feedResp.HasPrev)
+	}
 
 	found := false
 	for _, l := range feedResp.Listings {
@@ -191,4 +257,2058 @@ func TestMarketplaceWorkflow(t *testing.T) {
 	if !found {
 		s.Fatalf("Created listing with ID %q not found in feed", createListingRes.ID)
 	}
+
+	// 7. Get Listing By ID Without Token
+	resp, err = s.Client.Get(fmt.Sprintf("%s/listing/%d", s.BaseURL, createListingRes.ID))
+	if err != nil {
+		s.Fatalf("Failed to get listing by id: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get Listing expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var getListingRes models.Listing
+	err = json.NewDecoder(resp.Body).Decode(&getListingRes)
+	if err != nil {
+		s.Fatalf("Failed to decode get listing response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if getListingRes.ID != createListingRes.ID {
+		s.Errorf("Listing id mismatch: expected %d, got %d", createListingRes.ID, getListingRes.ID)
+	}
+	if getListingRes.IsOwner {
+		s.Errorf("IsOwner expected to be false for anonymous request, got true")
+	}
+
+	// 8. Get Nonexistent Listing
+	resp, err = s.Client.Get(s.BaseURL + "/listing/999999999")
+	if err != nil {
+		s.Fatalf("Failed to get nonexistent listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		s.Fatalf("Get nonexistent Listing expected 404 Not Found, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// 9. Update Listing
+	newTitle := "Updated Test Listing 1"
+	updateListingBody, _ := json.Marshal(listinghandler.UpdateListingRequest{Title: &newTitle})
+	req, err = http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/listing/%d", s.BaseURL, createListingRes.ID), bytes.NewReader(updateListingBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing update: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to update listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Update Listing expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var updateListingRes models.Listing
+	err = json.NewDecoder(resp.Body).Decode(&updateListingRes)
+	if err != nil {
+		s.Fatalf("Failed to decode update listing response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if updateListingRes.Title != newTitle {
+		s.Errorf("Listing title mismatch after update: expected %q, got %q", newTitle, updateListingRes.Title)
+	}
+	if updateListingRes.Price != createListingReq.Price {
+		s.Errorf("Listing price changed by partial update: expected %d, got %d", createListingReq.Price, updateListingRes.Price)
+	}
+
+	// 10. Delete Listing
+	req, err = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/listing/%d", s.BaseURL, createListingRes.ID), nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing deletion: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to delete listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		s.Fatalf("Delete Listing expected 204 No Content, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	resp, err = s.Client.Get(fmt.Sprintf("%s/listing/%d", s.BaseURL, createListingRes.ID))
+	if err != nil {
+		s.Fatalf("Failed to get deleted listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		s.Fatalf("Get deleted Listing expected 404 Not Found, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// 11. Refresh Tokens, then replay the used refresh token
+	refreshBody, _ := json.Marshal(authhandler.RefreshRequest{RefreshToken: loginResp.RefreshToken})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/refresh", "application/json", bytes.NewReader(refreshBody))
+	if err != nil {
+		s.Fatalf("Failed to refresh tokens: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Refresh expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var refreshResp authhandler.RefreshResponse
+	err = json.NewDecoder(resp.Body).Decode(&refreshResp)
+	if err != nil {
+		s.Fatalf("Failed to decode refresh response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if refreshResp.Token == "" || refreshResp.RefreshToken == "" || refreshResp.RefreshToken == loginResp.RefreshToken {
+		s.Fatalf("Refresh expected a new token pair, got %+v", refreshResp)
+	}
+
+	resp, err = s.Client.Post(s.BaseURL+"/auth/refresh", "application/json", bytes.NewReader(refreshBody))
+	if err != nil {
+		s.Fatalf("Failed to replay refresh token: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Replayed refresh token expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	rotatedBody, _ := json.Marshal(authhandler.RefreshRequest{RefreshToken: refreshResp.RefreshToken})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/refresh", "application/json", bytes.NewReader(rotatedBody))
+	if err != nil {
+		s.Fatalf("Failed to refresh with rotated token: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Refresh in revoked session expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// 12. List Sessions and Log Out
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(loginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	authToken = "Bearer " + loginResp.Token
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/auth/sessions", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for sessions: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get sessions: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get Sessions expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var sessions []models.Session
+	err = json.NewDecoder(resp.Body).Decode(&sessions)
+	if err != nil {
+		s.Fatalf("Failed to decode sessions response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	hasCurrent := false
+	for _, session := range sessions {
+		if session.Current {
+			hasCurrent = true
+		}
+	}
+	if !hasCurrent {
+		s.Errorf("Get Sessions expected the current session to be listed, got %+v", sessions)
+	}
+
+	changePasswordBody, _ := json.Marshal(authhandler.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "password456"})
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/auth/password", bytes.NewReader(changePasswordBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for password change: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to change password: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		s.Fatalf("Change Password expected 204 No Content, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/auth/logout", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for logout: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to logout: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		s.Fatalf("Logout expected 204 No Content, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/auth/sessions", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for sessions: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get sessions: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Get Sessions after logout expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// 13. Login With the New Password and Request a Password Reset
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(loginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Login with the old password expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	newLoginBody, _ := json.Marshal(authhandler.LoginRequest{Login: "testuser", Password: "password456"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(newLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login with the new password expected 200 OK, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	forgotBody, _ := json.Marshal(authhandler.ForgotPasswordRequest{Email: registerReq.Email})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/password/forgot", "application/json", bytes.NewReader(forgotBody))
+	if err != nil {
+		s.Fatalf("Failed to request password reset: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		s.Fatalf("Forgot Password expected 202 Accepted, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	resetBody, _ := json.Marshal(authhandler.ResetPasswordRequest{Token: "invalid", NewPassword: "password789"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/password/reset", "application/json", bytes.NewReader(resetBody))
+	if err != nil {
+		s.Fatalf("Failed to reset password: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		s.Fatalf("Reset Password with an invalid token expected 400 Bad Request, got %d", resp.StatusCode)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// 14. Enable Two-Factor Authentication and Log In With a Recovery Code
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(newLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	loginResp = authhandler.LoginResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	authToken = "Bearer " + loginResp.Token
+
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/auth/2fa/setup", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for 2fa setup: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to set up 2fa: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("2FA Setup expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var setup models.TOTPSetup
+	err = json.NewDecoder(resp.Body).Decode(&setup)
+	if err != nil {
+		s.Fatalf("Failed to decode 2fa setup response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		s.Fatalf("Failed to generate totp code: %v", err)
+	}
+	confirmBody, _ := json.Marshal(authhandler.ConfirmTwoFactorRequest{Code: code})
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/auth/2fa/confirm", bytes.NewReader(confirmBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for 2fa confirmation: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to confirm 2fa: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("2FA Confirm expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var confirmResp authhandler.ConfirmTwoFactorResponse
+	err = json.NewDecoder(resp.Body).Decode(&confirmResp)
+	if err != nil {
+		s.Fatalf("Failed to decode 2fa confirm response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if len(confirmResp.RecoveryCodes) == 0 {
+		s.Fatalf("2FA Confirm expected recovery codes")
+	}
+
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(newLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	loginResp = authhandler.LoginResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if !loginResp.TwoFactorRequired || loginResp.ChallengeToken == "" || loginResp.Token != "" {
+		s.Fatalf("Login with 2FA expected a challenge instead of tokens, got %+v", loginResp)
+	}
+
+	twoFactorBody, _ := json.Marshal(authhandler.LoginTwoFactorRequest{
+		ChallengeToken: loginResp.ChallengeToken,
+		Code:           confirmResp.RecoveryCodes[0],
+	})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login/2fa", "application/json", bytes.NewReader(twoFactorBody))
+	if err != nil {
+		s.Fatalf("Failed to complete 2fa login: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("2FA Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	loginResp = authhandler.LoginResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode 2fa login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if loginResp.Token == "" {
+		s.Fatalf("2FA Login response token is empty")
+	}
+
+	// 15. Fetch Token Verification Keys
+	resp, err = s.Client.Get(s.BaseURL + "/.well-known/jwks.json")
+	if err != nil {
+		s.Fatalf("Failed to get jwks: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get JWKS expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	var jwks jwtkeys.JWKS
+	err = json.NewDecoder(resp.Body).Decode(&jwks)
+	if err != nil {
+		s.Fatalf("Failed to decode jwks response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if jwks.Keys == nil {
+		s.Fatalf("JWKS response has no keys array")
+	}
+
+	// 16. Throttle Repeated Failed Logins
+	wrongLoginBody, _ := json.Marshal(authhandler.LoginRequest{Login: "bruteforce", Password: "wrongpassword"})
+	throttled := false
+	for attempt := 1; attempt <= 5 && !throttled; attempt++ {
+		resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(wrongLoginBody))
+		if err != nil {
+			s.Fatalf("Failed to login user: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+		case http.StatusTooManyRequests:
+			if resp.Header.Get("Retry-After") == "" {
+				s.Fatalf("Throttled login expected a Retry-After header")
+			}
+			throttled = true
+		default:
+			s.Fatalf("Failed login expected 401 or 429, got %d", resp.StatusCode)
+		}
+	}
+	if !throttled {
+		s.Fatalf("Repeated failed logins expected 429 Too Many Requests")
+	}
+
+	// 17. Regular Users Cannot Use Admin and Moderation Endpoints
+	if loginResp.User.Role != models.RoleUser {
+		s.Fatalf("Expected role %q, got %q", models.RoleUser, loginResp.User.Role)
+	}
+	authToken = "Bearer " + loginResp.Token
+
+	changeRoleBody, _ := json.Marshal(authhandler.ChangeRoleRequest{Role: models.RoleAdmin})
+	req, err = http.NewRequest(http.MethodPut, fmt.Sprintf("%s/admin/users/%d/role", s.BaseURL, loginResp.User.ID), bytes.NewReader(changeRoleBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for role change: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to change role: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		s.Fatalf("Role change by a regular user expected 403 Forbidden, got %d", resp.StatusCode)
+	}
+
+	moderateBody, _ := json.Marshal(listinghandler.ModerateListingRequest{Reason: "spam"})
+	req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/moderation/listing/%d/archive", s.BaseURL, createListingRes.ID), bytes.NewReader(moderateBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for moderation: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to moderate listing: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		s.Fatalf("Moderation by a regular user expected 403 Forbidden, got %d", resp.StatusCode)
+	}
+
+	// 18. Use a Scoped API Key
+	createAPIKeyBody, _ := json.Marshal(authhandler.CreateAPIKeyRequest{Name: "feed reader", Scopes: []string{models.ScopeListingsRead}})
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/auth/api-keys", bytes.NewReader(createAPIKeyBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for API key: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to create API key: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Create API key expected 201 Created, got %d", resp.StatusCode)
+	}
+	var createAPIKeyRes authhandler.CreateAPIKeyResponse
+	err = json.NewDecoder(resp.Body).Decode(&createAPIKeyRes)
+	if err != nil {
+		s.Fatalf("Failed to decode API key response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if createAPIKeyRes.Key == "" {
+		s.Fatalf("Create API key response has no key")
+	}
+
+	apiKeyChecks := []struct {
+		method string
+		path   string
+		body   []byte
+		status int
+	}{
+		{http.MethodGet, "/listing/feed", nil, http.StatusOK},
+		{http.MethodPost, "/listing", createListingBody, http.StatusForbidden},
+		{http.MethodGet, "/auth/sessions", nil, http.StatusForbidden},
+	}
+	for _, check := range apiKeyChecks {
+		req, err = http.NewRequest(check.method, s.BaseURL+check.path, bytes.NewReader(check.body))
+		if err != nil {
+			s.Fatalf("Failed to create new request for %s %s: %v", check.method, check.path, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", createAPIKeyRes.Key)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to call %s %s with API key: %v", check.method, check.path, err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode != check.status {
+			s.Fatalf("%s %s with API key expected %d, got %d", check.method, check.path, check.status, resp.StatusCode)
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/auth/api-keys/%d", s.BaseURL, createAPIKeyRes.APIKey.ID), nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for API key revocation: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to revoke API key: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		s.Fatalf("Revoke API key expected 204 No Content, got %d", resp.StatusCode)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createListingBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for revoked API key: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", createAPIKeyRes.Key)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to call API with revoked key: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Revoked API key expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+
+	// 19. Log In with the Stand-in OIDC Provider
+	var oidcUserID int64
+	var callbackURL string
+	for attempt := 1; attempt <= 2; attempt++ {
+		callbackURL = oidcCallbackURL(s, "oidcuser@example.com")
+
+		resp, err = s.Client.Get(callbackURL)
+		if err != nil {
+			s.Fatalf("Failed to complete OIDC login: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			s.Fatalf("OIDC callback expected 200 OK, got %d", resp.StatusCode)
+		}
+		var oidcLoginResp authhandler.LoginResponse
+		err = json.NewDecoder(resp.Body).Decode(&oidcLoginResp)
+		if err != nil {
+			s.Fatalf("Failed to decode OIDC login response: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if oidcLoginResp.Token == "" || oidcLoginResp.RefreshToken == "" {
+			s.Fatalf("OIDC login response has no tokens")
+		}
+
+		// The first login provisions the account, the second one reuses it.
+		if attempt == 1 {
+			oidcUserID = oidcLoginResp.User.ID
+		} else if oidcLoginResp.User.ID != oidcUserID {
+			s.Fatalf("Repeated OIDC login expected user %d, got %d", oidcUserID, oidcLoginResp.User.ID)
+		}
+	}
+
+	resp, err = s.Client.Get(callbackURL)
+	if err != nil {
+		s.Fatalf("Failed to replay OIDC callback: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Replayed OIDC callback expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+
+	// 20. View a Public Profile and the Seller's Listings
+	var profileIDs []int64
+	for _, ref := range []string{fmt.Sprint(loginResp.User.ID), loginResp.User.Login} {
+		resp, err = s.Client.Get(s.BaseURL + "/users/" + ref)
+		if err != nil {
+			s.Fatalf("Failed to get profile: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			s.Fatalf("Get profile %q expected 200 OK, got %d", ref, resp.StatusCode)
+		}
+		var profile models.UserProfile
+		err = json.NewDecoder(resp.Body).Decode(&profile)
+		if err != nil {
+			s.Fatalf("Failed to decode profile response: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if profile.Login != loginResp.User.Login || profile.MemberSince.IsZero() {
+			s.Fatalf("Unexpected profile for %q: %+v", ref, profile)
+		}
+		profileIDs = append(profileIDs, profile.ID)
+	}
+	if profileIDs[0] != profileIDs[1] {
+		s.Fatalf("Profiles by ID and login differ: %v", profileIDs)
+	}
+
+	// A numeric login is looked up as an ID by /users/{id}, /users/by-login
+	// always finds it as a login.
+	numericLogin := registerAndLogin(s, "4242", "password123")
+	for _, tc := range []struct {
+		login  string
+		status int
+		userID int64
+	}{
+		{numericLogin.User.Login, http.StatusOK, numericLogin.User.ID},
+		{loginResp.User.Login, http.StatusOK, loginResp.User.ID},
+		{fmt.Sprint(loginResp.User.ID), http.StatusNotFound, 0},
+		{"nosuchuser", http.StatusNotFound, 0},
+	} {
+		var profile models.UserProfile
+		status := doRequest(s, http.MethodGet, s.BaseURL+"/users/by-login/"+tc.login, "", nil, &profile)
+		if status != tc.status {
+			s.Fatalf("Get profile by login %q expected %d, got %d", tc.login, tc.status, status)
+		}
+		if status == http.StatusOK && profile.ID != tc.userID {
+			s.Fatalf("Get profile by login %q expected user %d, got %d", tc.login, tc.userID, profile.ID)
+		}
+	}
+
+	resp, err = s.Client.Get(fmt.Sprintf("%s/users/%d/listings?sortBy=price&sortOrder=asc", s.BaseURL, loginResp.User.ID))
+	if err != nil {
+		s.Fatalf("Failed to get seller listings: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get seller listings expected 200 OK, got %d", resp.StatusCode)
+	}
+	var sellerFeed models.ListingsFeed
+	err = json.NewDecoder(resp.Body).Decode(&sellerFeed)
+	if err != nil {
+		s.Fatalf("Failed to decode seller listings response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	for _, l := range sellerFeed.Listings {
+		if l.UserID != loginResp.User.ID {
+			s.Fatalf("Seller listings contain listing %d of user %d", l.ID, l.UserID)
+		}
+	}
+
+	resp, err = s.Client.Get(s.BaseURL + "/users/999999999/listings")
+	if err != nil {
+		s.Fatalf("Failed to get listings of unknown seller: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		s.Fatalf("Listings of unknown seller expected 404 Not Found, got %d", resp.StatusCode)
+	}
+
+	// 21. Edit the Own Profile and View the Listings Dashboard
+	displayName := "Test Seller"
+	bio := "Selling things I no longer need."
+	updateMeBody, _ := json.Marshal(userhandler.UpdateMeRequest{
+		DisplayName: &displayName,
+		Bio:         &bio,
+		ContactPreferences: &models.ContactPreferences{
+			Phone:            "+15550100",
+			PreferredChannel: "phone",
+		},
+	})
+	req, err = http.NewRequest(http.MethodPatch, s.BaseURL+"/me", bytes.NewReader(updateMeBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for profile update: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to update profile: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Update profile expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for current user: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get current user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get current user expected 200 OK, got %d", resp.StatusCode)
+	}
+	var me models.CurrentUser
+	err = json.NewDecoder(resp.Body).Decode(&me)
+	if err != nil {
+		s.Fatalf("Failed to decode current user response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if me.Login != loginResp.User.Login || me.DisplayName != displayName || me.Bio != bio || me.ContactPreferences.PreferredChannel != "phone" {
+		s.Fatalf("Unexpected current user: %+v", me)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me/listings", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for own listings: %v", err)
+	}
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get own listings: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get own listings expected 200 OK, got %d", resp.StatusCode)
+	}
+	var dashboard models.ListingsDashboard
+	err = json.NewDecoder(resp.Body).Decode(&dashboard)
+	if err != nil {
+		s.Fatalf("Failed to decode own listings response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if len(dashboard.StatusCounts) != 5 {
+		s.Fatalf("Own listings expected counts for 5 statuses, got %v", dashboard.StatusCounts)
+	}
+	for _, l := range dashboard.Listings {
+		if !l.IsOwner {
+			s.Fatalf("Own listings contain listing %d of user %d", l.ID, l.UserID)
+		}
+	}
+
+	// 22. Export the Account Data and Delete the Account
+	leavingRegisterBody, _ := json.Marshal(authhandler.RegisterRequest{Login: "leavinguser", Password: "password123"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/register", "application/json", bytes.NewReader(leavingRegisterBody))
+	if err != nil {
+		s.Fatalf("Failed to register user: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Registration expected 201 Created, got %d", resp.StatusCode)
+	}
+
+	leavingLoginBody, _ := json.Marshal(authhandler.LoginRequest{Login: "leavinguser", Password: "password123"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(leavingLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	var leavingLoginResp authhandler.LoginResponse
+	err = json.NewDecoder(resp.Body).Decode(&leavingLoginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	leavingToken := "Bearer " + leavingLoginResp.Token
+
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createListingBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", leavingToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to create listing: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Create listing expected 201 Created, got %d", resp.StatusCode)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me/export", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for export: %v", err)
+	}
+	req.Header.Set("Authorization", leavingToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to export account data: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Export expected 200 OK, got %d", resp.StatusCode)
+	}
+	var export models.AccountExport
+	err = json.NewDecoder(resp.Body).Decode(&export)
+	if err != nil {
+		s.Fatalf("Failed to decode export: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if export.Profile.Login != "leavinguser" || len(export.Listings) != 1 || len(export.Sessions) != 1 {
+		s.Fatalf("Unexpected export: %+v", export)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me/export?format=zip", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for export: %v", err)
+	}
+	req.Header.Set("Authorization", leavingToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to export account data: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		s.Fatalf("ZIP export expected 200 OK with application/zip, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	archive, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.Fatalf("Failed to read export archive: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		s.Fatalf("Failed to open export archive: %v", err)
+	}
+	var archiveFiles []string
+	for _, f := range zipReader.File {
+		archiveFiles = append(archiveFiles, f.Name)
+	}
+	if !slices.Contains(archiveFiles, "profile.json") || !slices.Contains(archiveFiles, "listings.json") {
+		s.Fatalf("Export archive is missing files: %v", archiveFiles)
+	}
+
+	for _, tc := range []struct {
+		password string
+		status   int
+	}{
+		{"wrongpassword", http.StatusBadRequest},
+		{"password123", http.StatusNoContent},
+	} {
+		deleteAccountBody, _ := json.Marshal(authhandler.DeleteAccountRequest{Password: tc.password})
+		req, err = http.NewRequest(http.MethodDelete, s.BaseURL+"/me", bytes.NewReader(deleteAccountBody))
+		if err != nil {
+			s.Fatalf("Failed to create new request for account deletion: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", leavingToken)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to delete account: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			s.Fatalf("Account deletion with %q expected %d, got %d", tc.password, tc.status, resp.StatusCode)
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me", nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for current user: %v", err)
+	}
+	req.Header.Set("Authorization", leavingToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get current user: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Token of a deleted account expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(leavingLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		s.Fatalf("Login of a deleted account expected 401 Unauthorized, got %d", resp.StatusCode)
+	}
+
+	// 23. Save a Listing of Another User to Favorites
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createListingBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to create listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Create listing expected 201 Created, got %d", resp.StatusCode)
+	}
+	var favoriteListing models.Listing
+	err = json.NewDecoder(resp.Body).Decode(&favoriteListing)
+	if err != nil {
+		s.Fatalf("Failed to decode listing response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	buyerRegisterBody, _ := json.Marshal(authhandler.RegisterRequest{Login: "buyeruser", Email: "buyeruser@example.com", Password: "password123"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/register", "application/json", bytes.NewReader(buyerRegisterBody))
+	if err != nil {
+		s.Fatalf("Failed to register user: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Registration expected 201 Created, got %d", resp.StatusCode)
+	}
+
+	buyerLoginBody, _ := json.Marshal(authhandler.LoginRequest{Login: "buyeruser", Password: "password123"})
+	resp, err = s.Client.Post(s.BaseURL+"/auth/login", "application/json", bytes.NewReader(buyerLoginBody))
+	if err != nil {
+		s.Fatalf("Failed to login user: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", resp.StatusCode)
+	}
+	var buyerLoginResp authhandler.LoginResponse
+	err = json.NewDecoder(resp.Body).Decode(&buyerLoginResp)
+	if err != nil {
+		s.Fatalf("Failed to decode login response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	buyerToken := "Bearer " + buyerLoginResp.Token
+
+	favoriteURL := fmt.Sprintf("%s/listing/%d/favorite", s.BaseURL, favoriteListing.ID)
+	for _, tc := range []struct {
+		method string
+		token  string
+		status int
+	}{
+		{http.MethodPost, authToken, http.StatusBadRequest},
+		{http.MethodPost, buyerToken, http.StatusNoContent},
+		{http.MethodPost, buyerToken, http.StatusNoContent},
+	} {
+		req, err = http.NewRequest(tc.method, favoriteURL, nil)
+		if err != nil {
+			s.Fatalf("Failed to create new request for favorite: %v", err)
+		}
+		req.Header.Set("Authorization", tc.token)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to add favorite: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			s.Fatalf("Add favorite expected %d, got %d", tc.status, resp.StatusCode)
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/listing/%d", s.BaseURL, favoriteListing.ID), nil)
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing: %v", err)
+	}
+	req.Header.Set("Authorization", buyerToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to get listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.Fatalf("Get listing expected 200 OK, got %d", resp.StatusCode)
+	}
+	var favoritedListing models.Listing
+	err = json.NewDecoder(resp.Body).Decode(&favoritedListing)
+	if err != nil {
+		s.Fatalf("Failed to decode listing response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if !favoritedListing.IsFavorite || favoritedListing.FavoritesCount != 1 {
+		s.Fatalf("Favorited listing expected is_favorite and favorites_count 1, got %v and %d",
+			favoritedListing.IsFavorite, favoritedListing.FavoritesCount)
+	}
+
+	for _, expected := range []int{1, 0} {
+		req, err = http.NewRequest(http.MethodGet, s.BaseURL+"/me/favorites", nil)
+		if err != nil {
+			s.Fatalf("Failed to create new request for favorites: %v", err)
+		}
+		req.Header.Set("Authorization", buyerToken)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to get favorites: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			s.Fatalf("Get favorites expected 200 OK, got %d", resp.StatusCode)
+		}
+		var favorites models.ListingsFeed
+		err = json.NewDecoder(resp.Body).Decode(&favorites)
+		if err != nil {
+			s.Fatalf("Failed to decode favorites response: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if favorites.Total != expected {
+			s.Fatalf("Favorites expected %d listings, got %d", expected, favorites.Total)
+		}
+		if expected == 0 {
+			break
+		}
+		if !favorites.Listings[0].IsFavorite || favorites.Listings[0].ID != favoriteListing.ID {
+			s.Fatalf("Unexpected favorite: %+v", favorites.Listings[0])
+		}
+
+		req, err = http.NewRequest(http.MethodDelete, favoriteURL, nil)
+		if err != nil {
+			s.Fatalf("Failed to create new request for favorite: %v", err)
+		}
+		req.Header.Set("Authorization", buyerToken)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to remove favorite: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode != http.StatusNoContent {
+			s.Fatalf("Remove favorite expected 204 No Content, got %d", resp.StatusCode)
+		}
+	}
+
+	// 24. Save a Search and Get Its New Matches Checked
+	savedSearchURL := s.BaseURL + "/me/saved-searches"
+	var savedSearch models.SavedSearch
+	for _, tc := range []struct {
+		query  listinghandler.SavedSearchQuery
+		status int
+	}{
+		{listinghandler.SavedSearchQuery{SortBy: "relevance"}, http.StatusBadRequest},
+		{listinghandler.SavedSearchQuery{MinPrice: 200000, MaxPrice: 100000}, http.StatusBadRequest},
+		{listinghandler.SavedSearchQuery{MinPrice: 100000, MaxPrice: 200000, SortBy: "price", SortOrder: "asc"}, http.StatusCreated},
+	} {
+		savedSearchBody, _ := json.Marshal(listinghandler.CreateSavedSearchRequest{Name: "Mid-range", Query: tc.query})
+		req, err = http.NewRequest(http.MethodPost, savedSearchURL, bytes.NewReader(savedSearchBody))
+		if err != nil {
+			s.Fatalf("Failed to create new request for saved search: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", buyerToken)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to save search: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			s.Fatalf("Save search expected %d, got %d", tc.status, resp.StatusCode)
+		}
+		if tc.status == http.StatusCreated {
+			err = json.NewDecoder(resp.Body).Decode(&savedSearch)
+			if err != nil {
+				s.Fatalf("Failed to decode saved search response: %v", err)
+			}
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+	}
+	if savedSearch.Query.MinPrice != 100000 || savedSearch.Query.SortBy != "price" || savedSearch.Query.Limit != 0 {
+		s.Fatalf("Unexpected saved search query: %+v", savedSearch.Query)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, s.BaseURL+"/listing", bytes.NewReader(createListingBody))
+	if err != nil {
+		s.Fatalf("Failed to create new request for listing: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authToken)
+
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to create listing: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		s.Fatalf("Create listing expected 201 Created, got %d", resp.StatusCode)
+	}
+	var matchingListing models.Listing
+	err = json.NewDecoder(resp.Body).Decode(&matchingListing)
+	if err != nil {
+		s.Fatalf("Failed to decode listing response: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+
+	// The server checks saved searches every SAVED_SEARCH_CHECK_INTERVAL and
+	// mails the matches with SAVED_SEARCH_NOTIFIER=mail in .env.test.
+	searchMail := waitForMail(s, "buyeruser@example.com", fmt.Sprintf("New listings for \"%s\"", savedSearch.Name))
+	matchLine := fmt.Sprintf("- %s, %d.%02d RUB: /listing/%d\r\n",
+		matchingListing.Title, matchingListing.Price/100, matchingListing.Price%100, matchingListing.ID)
+	if !strings.Contains(searchMail, matchLine) {
+		s.Fatalf("Saved search mail does not name listing %d:\n%s", matchingListing.ID, searchMail)
+	}
+
+	// A draft is matched once it is published, although it was created
+	// before the previous check.
+	laterReq := createListingReq
+	laterReq.Title = "Published Later"
+	laterReq.Status = models.ListingStatusDraft
+	var publishedDraft models.Listing
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", authToken, laterReq, &publishedDraft); status != http.StatusCreated {
+		s.Fatalf("Create draft expected 201 Created, got %d", status)
+	}
+	time.Sleep(2 * time.Second)
+	if status := doRequest(s, http.MethodPost, fmt.Sprintf("%s/listing/%d/publish", s.BaseURL, publishedDraft.ID), authToken, nil, nil); status != http.StatusOK {
+		s.Fatalf("Publish draft expected 200 OK, got %d", status)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		searchMail = waitForMail(s, "buyeruser@example.com", fmt.Sprintf("New listings for \"%s\"", savedSearch.Name))
+		if strings.Contains(searchMail, fmt.Sprintf("/listing/%d\r\n", publishedDraft.ID)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			s.Fatalf("No saved search mail names the published draft %d", publishedDraft.ID)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	// Listings already announced are not announced again, although the
+	// checks overlap.
+	if strings.Contains(searchMail, fmt.Sprintf("/listing/%d\r\n", matchingListing.ID)) {
+		s.Fatalf("Saved search mail names listing %d again:\n%s", matchingListing.ID, searchMail)
+	}
+
+	for _, expected := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req, err = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%d", savedSearchURL, savedSearch.ID), nil)
+		if err != nil {
+			s.Fatalf("Failed to create new request for saved search: %v", err)
+		}
+		req.Header.Set("Authorization", buyerToken)
+
+		resp, err = s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to delete saved search: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode != expected {
+			s.Fatalf("Delete saved search expected %d, got %d", expected, resp.StatusCode)
+		}
+	}
+
+	// 25. Move Listings Through Their Statuses
+	draftReq := createListingReq
+	draftReq.Status = models.ListingStatusDraft
+	var draft models.Listing
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", authToken, draftReq, &draft); status != http.StatusCreated {
+		s.Fatalf("Create draft expected 201 Created, got %d", status)
+	}
+	if draft.Status != models.ListingStatusDraft {
+		s.Fatalf("Created draft has status %q", draft.Status)
+	}
+
+	draftURL := fmt.Sprintf("%s/listing/%d", s.BaseURL, draft.ID)
+	for _, tc := range []struct {
+		method string
+		url    string
+		token  string
+		status int
+	}{
+		{http.MethodGet, draftURL, "", http.StatusNotFound},
+		{http.MethodGet, draftURL, buyerToken, http.StatusNotFound},
+		{http.MethodGet, draftURL, authToken, http.StatusOK},
+		{http.MethodGet, s.BaseURL + "/listing/feed?status=draft", "", http.StatusUnauthorized},
+		{http.MethodPost, draftURL + "/publish", buyerToken, http.StatusForbidden},
+		{http.MethodPost, draftURL + "/archive", buyerToken, http.StatusForbidden},
+	} {
+		if status := doRequest(s, tc.method, tc.url, tc.token, nil, nil); status != tc.status {
+			s.Fatalf("%s %s expected %d, got %d", tc.method, tc.url, tc.status, status)
+		}
+	}
+
+	var ownDrafts models.ListingsFeed
+	if status := doRequest(s, http.MethodGet, s.BaseURL+"/listing/feed?status=draft&limit=100", authToken, nil, &ownDrafts); status != http.StatusOK {
+		s.Fatalf("Get own drafts expected 200 OK, got %d", status)
+	}
+	if !slices.ContainsFunc(ownDrafts.Listings, func(l models.Listing) bool { return l.ID == draft.ID }) {
+		s.Fatalf("Own drafts do not contain draft %d", draft.ID)
+	}
+	for _, l := range ownDrafts.Listings {
+		if l.Status != models.ListingStatusDraft || !l.IsOwner {
+			s.Fatalf("Own drafts contain listing %d of user %d in status %q", l.ID, l.UserID, l.Status)
+		}
+	}
+
+	var secondDraft models.Listing
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", authToken, draftReq, &secondDraft); status != http.StatusCreated {
+		s.Fatalf("Create draft expected 201 Created, got %d", status)
+	}
+
+	// Together the two sequences take every allowed transition once.
+	for _, tc := range []struct {
+		listingID int64
+		action    string
+		status    int
+		result    string
+	}{
+		{draft.ID, "reserve", http.StatusConflict, ""},
+		{draft.ID, "sell", http.StatusConflict, ""},
+		{draft.ID, "publish", http.StatusOK, models.ListingStatusActive},
+		{draft.ID, "publish", http.StatusConflict, ""},
+		{draft.ID, "reserve", http.StatusOK, models.ListingStatusReserved},
+		{draft.ID, "publish", http.StatusOK, models.ListingStatusActive},
+		{draft.ID, "reserve", http.StatusOK, models.ListingStatusReserved},
+		{draft.ID, "sell", http.StatusOK, models.ListingStatusSold},
+		{draft.ID, "publish", http.StatusConflict, ""},
+		{draft.ID, "reserve", http.StatusConflict, ""},
+		{draft.ID, "archive", http.StatusOK, models.ListingStatusArchived},
+		{draft.ID, "sell", http.StatusConflict, ""},
+		{draft.ID, "publish", http.StatusOK, models.ListingStatusActive},
+		{draft.ID, "sell", http.StatusOK, models.ListingStatusSold},
+		{secondDraft.ID, "archive", http.StatusOK, models.ListingStatusArchived},
+		{secondDraft.ID, "publish", http.StatusOK, models.ListingStatusActive},
+		{secondDraft.ID, "archive", http.StatusOK, models.ListingStatusArchived},
+		{secondDraft.ID, "publish", http.StatusOK, models.ListingStatusActive},
+		{secondDraft.ID, "reserve", http.StatusOK, models.ListingStatusReserved},
+		{secondDraft.ID, "archive", http.StatusOK, models.ListingStatusArchived},
+	} {
+		var changed models.Listing
+		status := doRequest(s, http.MethodPost, fmt.Sprintf("%s/listing/%d/%s", s.BaseURL, tc.listingID, tc.action), authToken, nil, &changed)
+		if status != tc.status {
+			s.Fatalf("Listing %d %s expected %d, got %d", tc.listingID, tc.action, tc.status, status)
+		}
+		if tc.result != "" && changed.Status != tc.result {
+			s.Fatalf("Listing %d %s expected status %q, got %q", tc.listingID, tc.action, tc.result, changed.Status)
+		}
+	}
+
+	for _, tc := range []struct {
+		token  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{buyerToken, http.StatusOK},
+	} {
+		var sold models.Listing
+		if status := doRequest(s, http.MethodGet, draftURL, tc.token, nil, &sold); status != tc.status {
+			s.Fatalf("Get sold listing expected %d, got %d", tc.status, status)
+		}
+		if sold.Status != models.ListingStatusSold || sold.IsOwner {
+			s.Fatalf("Unexpected sold listing: %+v", sold)
+		}
+	}
+	if status := doRequest(s, http.MethodGet, fmt.Sprintf("%s/listing/%d", s.BaseURL, secondDraft.ID), buyerToken, nil, nil); status != http.StatusNotFound {
+		s.Fatalf("Get archived listing of another user expected 404 Not Found, got %d", status)
+	}
+
+	// 26. Page Through a Seller's Listings with Cursors
+	cursorLogin := registerAndLogin(s, "cursoruser", "password123")
+	cursorToken := "Bearer " + cursorLogin.Token
+	cursorPrices := []int64{300000, 100000, 500000, 200000, 400000}
+	for i, price := range cursorPrices {
+		cursorReq := createListingReq
+		cursorReq.Title = fmt.Sprintf("Cursor Listing %d", i+1)
+		cursorReq.Price = price
+		if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", cursorToken, cursorReq, nil); status != http.StatusCreated {
+			s.Fatalf("Create listing expected 201 Created, got %d", status)
+		}
+	}
+
+	sellerFeedURL := fmt.Sprintf("%s/users/%d/listings", s.BaseURL, cursorLogin.User.ID)
+	for _, sortQuery := range []string{"sortBy=price&sortOrder=asc", "sortBy=price&sortOrder=desc", "sortBy=createdAt"} {
+		var pagePrices []int64
+		seen := map[int64]bool{}
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(cursorPrices) {
+				s.Fatalf("Cursor pagination with %s did not end", sortQuery)
+			}
+			pageURL := sellerFeedURL + "?limit=2&" + sortQuery
+			if cursor != "" {
+				pageURL += "&cursor=" + url.QueryEscape(cursor)
+			}
+			var page models.ListingsFeed
+			if status := doRequest(s, http.MethodGet, pageURL, "", nil, &page); status != http.StatusOK {
+				s.Fatalf("Get seller listings with %s expected 200 OK, got %d", sortQuery, status)
+			}
+			for _, l := range page.Listings {
+				if seen[l.ID] {
+					s.Fatalf("Cursor pagination with %s returned listing %d twice", sortQuery, l.ID)
+				}
+				seen[l.ID] = true
+				pagePrices = append(pagePrices, l.Price)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+
+		if len(pagePrices) != len(cursorPrices) {
+			s.Fatalf("Cursor pagination with %s returned %d listings, expected %d", sortQuery, len(pagePrices), len(cursorPrices))
+		}
+		switch sortQuery {
+		case "sortBy=price&sortOrder=asc":
+			if !slices.IsSorted(pagePrices) {
+				s.Fatalf("Listings sorted by price ascending came in order %v", pagePrices)
+			}
+		case "sortBy=price&sortOrder=desc":
+			if !slices.IsSortedFunc(pagePrices, func(a, b int64) int { return cmp.Compare(b, a) }) {
+				s.Fatalf("Listings sorted by price descending came in order %v", pagePrices)
+			}
+		default:
+			// Newest first, the reverse of the order of creation.
+			newestFirst := slices.Clone(cursorPrices)
+			slices.Reverse(newestFirst)
+			if !slices.Equal(pagePrices, newestFirst) {
+				s.Fatalf("Listings sorted by creation time came in order %v", pagePrices)
+			}
+		}
+	}
+
+	var firstPage models.ListingsFeed
+	if status := doRequest(s, http.MethodGet, sellerFeedURL+"?limit=2&sortBy=price", "", nil, &firstPage); status != http.StatusOK {
+		s.Fatalf("Get seller listings expected 200 OK, got %d", status)
+	}
+	if firstPage.NextCursor == "" {
+		s.Fatalf("Seller listings expected a next cursor")
+	}
+	payload, signature, _ := strings.Cut(firstPage.NextCursor, ".")
+	tamperedPayload := []byte(payload)
+	tamperedPayload[len(tamperedPayload)/2] ^= 1
+	for _, tc := range []struct {
+		name string
+		url  string
+	}{
+		{"tampered cursor", sellerFeedURL + "?cursor=" + url.QueryEscape(string(tamperedPayload)+"."+signature)},
+		{"cursor and another filter", sellerFeedURL + "?minPrice=150000&cursor=" + url.QueryEscape(firstPage.NextCursor)},
+		{"cursor of another seller", fmt.Sprintf("%s/users/%d/listings?cursor=%s", s.BaseURL, buyerLoginResp.User.ID, url.QueryEscape(firstPage.NextCursor))},
+	} {
+		if status := doRequest(s, http.MethodGet, tc.url, "", nil, nil); status != http.StatusBadRequest {
+			s.Fatalf("Seller listings with a %s expected 400 Bad Request, got %d", tc.name, status)
+		}
+	}
+
+	// 27. Search Listings by Text
+	var searchListingIDs []int64
+	for _, text := range [][2]string{
+		{"<script>alert(1)</script> Zebracycle", "Zebracycle in mint condition, a <b>rare</b> zebracycle."},
+		{"Old bicycle", "Rides like a zebracycle."},
+	} {
+		searchReq := createListingReq
+		searchReq.Title, searchReq.Description = text[0], text[1]
+		var created models.Listing
+		if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", cursorToken, searchReq, &created); status != http.StatusCreated {
+			s.Fatalf("Create listing expected 201 Created, got %d", status)
+		}
+		searchListingIDs = append(searchListingIDs, created.ID)
+	}
+
+	for _, sortOrder := range []string{"", "&sortOrder=asc", "&sortBy=relevance&sortOrder=asc"} {
+		var found models.ListingsFeed
+		if status := doRequest(s, http.MethodGet, s.BaseURL+"/listing/feed?q=zebracycle"+sortOrder, "", nil, &found); status != http.StatusOK {
+			s.Fatalf("Search with %q expected 200 OK, got %d", sortOrder, status)
+		}
+		if found.Total != 2 || len(found.Listings) != 2 {
+			s.Fatalf("Search with %q expected 2 listings, got %d", sortOrder, found.Total)
+		}
+		mostRelevant, lessRelevant := found.Listings[0], found.Listings[1]
+		if mostRelevant.ID != searchListingIDs[0] || mostRelevant.Relevance <= lessRelevant.Relevance {
+			s.Fatalf("Search with %q expected listing %d first, got %d (%f) before %d (%f)", sortOrder,
+				searchListingIDs[0], mostRelevant.ID, mostRelevant.Relevance, lessRelevant.ID, lessRelevant.Relevance)
+		}
+		if mostRelevant.Highlight == nil {
+			s.Fatalf("Search result has no highlight")
+		}
+		title := mostRelevant.Highlight.Title
+		if !strings.Contains(title, "&lt;script&gt;") || strings.Contains(title, "<script>") || !strings.Contains(title, "<mark>Zebracycle</mark>") {
+			s.Fatalf("Unexpected title highlight: %q", title)
+		}
+		description := mostRelevant.Highlight.Description
+		if strings.Contains(description, "<b>") || !strings.Contains(description, "<mark>zebracycle</mark>") {
+			s.Fatalf("Unexpected description highlight: %q", description)
+		}
+	}
+
+	for _, query := range []string{"sortBy=relevance", "q=" + strings.Repeat("a", 201)} {
+		if status := doRequest(s, http.MethodGet, s.BaseURL+"/listing/feed?"+query, "", nil, nil); status != http.StatusBadRequest {
+			s.Fatalf("Search with %s expected 400 Bad Request, got %d", query, status)
+		}
+	}
+
+	// 28. Validate Listing Attributes and Filter the Feed by Them
+	var enumAttribute, intAttribute, requiredAttribute *models.CategoryAttribute
+	for i, a := range categoryAttributes {
+		switch {
+		case a.Type == models.AttributeTypeEnum && len(a.Options) > 1 && enumAttribute == nil:
+			enumAttribute = &categoryAttributes[i]
+		case a.Type == models.AttributeTypeInt && a.Min != nil && a.Max != nil && intAttribute == nil:
+			intAttribute = &categoryAttributes[i]
+		}
+		if a.Required && requiredAttribute == nil {
+			requiredAttribute = &categoryAttributes[i]
+		}
+	}
+	if enumAttribute == nil || intAttribute == nil || requiredAttribute == nil {
+		s.Fatalf("Category %d expected enum, bounded int and required attributes, got %+v", leafCategory.ID, categoryAttributes)
+	}
+
+	withAttribute := func(key string, value any) map[string]any {
+		attributes := requiredAttributeValues(categoryAttributes)
+		if value == nil {
+			delete(attributes, key)
+		} else {
+			attributes[key] = value
+		}
+		return attributes
+	}
+	for _, tc := range []struct {
+		name       string
+		attributes map[string]any
+	}{
+		{"an unknown key", withAttribute("no_such_attribute", "value")},
+		{"a wrong type", withAttribute(intAttribute.Key, "many")},
+		{"an enum value outside the options", withAttribute(enumAttribute.Key, "no-such-option")},
+		{"an int below the minimum", withAttribute(intAttribute.Key, *intAttribute.Min-1)},
+		{"an int above the maximum", withAttribute(intAttribute.Key, *intAttribute.Max+1)},
+		{"a missing required attribute", withAttribute(requiredAttribute.Key, nil)},
+	} {
+		attributeReq := createListingReq
+		attributeReq.Attributes = tc.attributes
+		if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", authToken, attributeReq, nil); status != http.StatusBadRequest {
+			s.Fatalf("Create listing with %s expected 400 Bad Request, got %d", tc.name, status)
+		}
+	}
+
+	attributeLogin := registerAndLogin(s, "attributeuser", "password123")
+	low, mid, high := *intAttribute.Min, *intAttribute.Min+1, *intAttribute.Max
+	for _, tc := range []struct {
+		number int64
+		option string
+	}{
+		{low, enumAttribute.Options[0]},
+		{mid, enumAttribute.Options[0]},
+		{high, enumAttribute.Options[1]},
+	} {
+		attributeReq := createListingReq
+		attributeReq.Attributes = withAttribute(intAttribute.Key, tc.number)
+		attributeReq.Attributes[enumAttribute.Key] = tc.option
+		if status := doRequest(s, http.MethodPost, s.BaseURL+"/listing", "Bearer "+attributeLogin.Token, attributeReq, nil); status != http.StatusCreated {
+			s.Fatalf("Create listing expected 201 Created, got %d", status)
+		}
+	}
+
+	attributeFeedURL := fmt.Sprintf("%s/users/%d/listings?", s.BaseURL, attributeLogin.User.ID)
+	intKey, enumKey := "attr."+intAttribute.Key, "attr."+enumAttribute.Key
+	for _, tc := range []struct {
+		query  url.Values
+		status int
+		total  int
+	}{
+		{url.Values{intKey: {fmt.Sprint(mid)}}, http.StatusOK, 1},
+		{url.Values{intKey + "_gte": {fmt.Sprint(mid)}}, http.StatusOK, 2},
+		{url.Values{intKey + "_lte": {fmt.Sprint(mid)}}, http.StatusOK, 2},
+		{url.Values{intKey + "_gte": {fmt.Sprint(mid)}, intKey + "_lte": {fmt.Sprint(high - 1)}}, http.StatusOK, 1},
+		{url.Values{intKey + "_gte": {fmt.Sprint(high + 1)}}, http.StatusOK, 0},
+		{url.Values{enumKey: {enumAttribute.Options[1]}}, http.StatusOK, 1},
+		{url.Values{enumKey: {enumAttribute.Options[0]}, intKey + "_lte": {fmt.Sprint(low)}}, http.StatusOK, 1},
+		{url.Values{intKey + "_gte": {"many"}}, http.StatusBadRequest, 0},
+		{url.Values{"attr.Bad-Key": {"1"}}, http.StatusBadRequest, 0},
+		{url.Values{intKey: {""}}, http.StatusBadRequest, 0},
+	} {
+		var filtered models.ListingsFeed
+		status := doRequest(s, http.MethodGet, attributeFeedURL+tc.query.Encode(), "", nil, &filtered)
+		if status != tc.status {
+			s.Fatalf("Feed filtered by %s expected %d, got %d", tc.query.Encode(), tc.status, status)
+		}
+		if status == http.StatusOK && filtered.Total != tc.total {
+			s.Fatalf("Feed filtered by %s expected %d listings, got %d", tc.query.Encode(), tc.total, filtered.Total)
+		}
+	}
+
+	// 29. Reset a Forgotten Password with the Link from the Outbox
+	resetRegisterReq := authhandler.RegisterRequest{Login: "resetuser", Email: "resetuser@example.com", Password: "password123"}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/register", "", resetRegisterReq, nil); status != http.StatusCreated {
+		s.Fatalf("Registration expected 201 Created, got %d", status)
+	}
+	var resetLogin authhandler.LoginResponse
+	status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "",
+		authhandler.LoginRequest{Login: resetRegisterReq.Login, Password: resetRegisterReq.Password}, &resetLogin)
+	if status != http.StatusOK {
+		s.Fatalf("Login expected 200 OK, got %d", status)
+	}
+
+	forgotReq := authhandler.ForgotPasswordRequest{Email: resetRegisterReq.Email}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/password/forgot", "", forgotReq, nil); status != http.StatusAccepted {
+		s.Fatalf("Password reset request expected 202 Accepted, got %d", status)
+	}
+	unknownReq := authhandler.ForgotPasswordRequest{Email: "nobody@example.com"}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/password/forgot", "", unknownReq, nil); status != http.StatusAccepted {
+		s.Fatalf("Password reset request for an unknown email expected 202 Accepted, got %d", status)
+	}
+
+	resetMail := waitForMail(s, resetRegisterReq.Email, "Password reset")
+	var resetToken string
+	for _, field := range strings.Fields(resetMail) {
+		if link, err := url.Parse(field); err == nil && link.Query().Get("token") != "" {
+			resetToken = link.Query().Get("token")
+		}
+	}
+	if resetToken == "" {
+		s.Fatalf("Password reset mail has no reset link:\n%s", resetMail)
+	}
+
+	resetReq := authhandler.ResetPasswordRequest{Token: resetToken, NewPassword: "password456"}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/password/reset", "", resetReq, nil); status != http.StatusNoContent {
+		s.Fatalf("Password reset expected 204 No Content, got %d", status)
+	}
+	resetReq.NewPassword = "password789"
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/password/reset", "", resetReq, nil); status != http.StatusBadRequest {
+		s.Fatalf("Reusing the reset token expected 400 Bad Request, got %d", status)
+	}
+
+	if status := doRequest(s, http.MethodGet, s.BaseURL+"/auth/sessions", "Bearer "+resetLogin.Token, nil, nil); status != http.StatusUnauthorized {
+		s.Fatalf("Access token issued before the reset expected 401 Unauthorized, got %d", status)
+	}
+	refreshReq := authhandler.RefreshRequest{RefreshToken: resetLogin.RefreshToken}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/refresh", "", refreshReq, nil); status != http.StatusUnauthorized {
+		s.Fatalf("Refresh token issued before the reset expected 401 Unauthorized, got %d", status)
+	}
+	status = doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "",
+		authhandler.LoginRequest{Login: resetRegisterReq.Login, Password: resetRegisterReq.Password}, nil)
+	if status != http.StatusUnauthorized {
+		s.Fatalf("Login with the old password expected 401 Unauthorized, got %d", status)
+	}
+	status = doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "",
+		authhandler.LoginRequest{Login: resetRegisterReq.Login, Password: "password456"}, nil)
+	if status != http.StatusOK {
+		s.Fatalf("Login with the new password expected 200 OK, got %d", status)
+	}
+
+	// Reset requests are limited per IP (PASSWORD_RESET_IP_MAX_REQUESTS in
+	// .env.test), after which they are refused with a Retry-After header.
+	throttled = false
+	for range 10 {
+		forgotBody, _ := json.Marshal(unknownReq)
+		resp, err := s.Client.Post(s.BaseURL+"/auth/password/forgot", "application/json", bytes.NewReader(forgotBody))
+		if err != nil {
+			s.Fatalf("Failed to request password reset: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		if resp.StatusCode == http.StatusAccepted {
+			continue
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			s.Fatalf("Password reset request expected 202 Accepted or 429 Too Many Requests, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			s.Fatalf("Throttled password reset request has no Retry-After header")
+		}
+		throttled = true
+		break
+	}
+	if !throttled {
+		s.Fatalf("Password reset requests were never throttled")
+	}
+
+	// 30. Throttle Guessing of Two-Factor Codes Across Challenges
+	totpLogin := registerAndLogin(s, "totpuser", "password123")
+	var totpSetup models.TOTPSetup
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/2fa/setup", "Bearer "+totpLogin.Token, nil, &totpSetup); status != http.StatusOK {
+		s.Fatalf("2FA Setup expected 200 OK, got %d", status)
+	}
+	totpCode, err := totp.Code(totpSetup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		s.Fatalf("Failed to generate totp code: %v", err)
+	}
+	confirmReq := authhandler.ConfirmTwoFactorRequest{Code: totpCode}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/2fa/confirm", "Bearer "+totpLogin.Token, confirmReq, nil); status != http.StatusOK {
+		s.Fatalf("2FA Confirm expected 200 OK, got %d", status)
+	}
+
+	// Every attempt starts with the correct password and a fresh challenge,
+	// which must not reset the failures of the rejected codes.
+	totpThrottled := false
+	for attempt := 1; attempt <= 5 && !totpThrottled; attempt++ {
+		var challenge authhandler.LoginResponse
+		status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "",
+			authhandler.LoginRequest{Login: "totpuser", Password: "password123"}, &challenge)
+		if status == http.StatusTooManyRequests {
+			totpThrottled = true
+			break
+		}
+		if status != http.StatusOK || challenge.ChallengeToken == "" {
+			s.Fatalf("Login with 2FA expected a challenge, got %d", status)
+		}
+
+		wrongCode := authhandler.LoginTwoFactorRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}
+		if wrongCode.Code == totpCode {
+			wrongCode.Code = "111111"
+		}
+		switch status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/login/2fa", "", wrongCode, nil); status {
+		case http.StatusUnauthorized:
+		case http.StatusTooManyRequests:
+			totpThrottled = true
+		default:
+			s.Fatalf("Wrong 2FA code expected 401 or 429, got %d", status)
+		}
+	}
+	if !totpThrottled {
+		s.Fatalf("Repeated wrong 2FA codes expected 429 Too Many Requests")
+	}
+
+	// 31. Re-authenticate an Account Deletion
+	leaverLogin := registerAndLogin(s, "totpleaver", "password123")
+	var leaverSetup models.TOTPSetup
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/2fa/setup", "Bearer "+leaverLogin.Token, nil, &leaverSetup); status != http.StatusOK {
+		s.Fatalf("2FA Setup expected 200 OK, got %d", status)
+	}
+	leaverCode, err := totp.Code(leaverSetup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		s.Fatalf("Failed to generate totp code: %v", err)
+	}
+	var leaverConfirm authhandler.ConfirmTwoFactorResponse
+	status = doRequest(s, http.MethodPost, s.BaseURL+"/auth/2fa/confirm", "Bearer "+leaverLogin.Token,
+		authhandler.ConfirmTwoFactorRequest{Code: leaverCode}, &leaverConfirm)
+	if status != http.StatusOK || len(leaverConfirm.RecoveryCodes) == 0 {
+		s.Fatalf("2FA Confirm expected 200 OK with recovery codes, got %d", status)
+	}
+
+	// An account with a password needs it even in a fresh session, and the
+	// second factor as well once two-factor authentication is enabled.
+	for _, tc := range []struct {
+		name   string
+		req    authhandler.DeleteAccountRequest
+		status int
+	}{
+		{"nothing", authhandler.DeleteAccountRequest{}, http.StatusForbidden},
+		{"only a recovery code", authhandler.DeleteAccountRequest{Code: leaverConfirm.RecoveryCodes[0]}, http.StatusForbidden},
+		{"only the password", authhandler.DeleteAccountRequest{Password: "password123"}, http.StatusBadRequest},
+		{"a wrong code", authhandler.DeleteAccountRequest{Password: "password123", Code: "not-a-recovery-code"}, http.StatusBadRequest},
+		{"the password and a recovery code", authhandler.DeleteAccountRequest{Password: "password123", Code: leaverConfirm.RecoveryCodes[0]}, http.StatusNoContent},
+	} {
+		if status := doRequest(s, http.MethodDelete, s.BaseURL+"/me", "Bearer "+leaverLogin.Token, tc.req, nil); status != tc.status {
+			s.Fatalf("Account deletion with %s expected %d, got %d", tc.name, tc.status, status)
+		}
+	}
+
+	// An account created through OIDC has no password; a fresh login through
+	// the provider confirms its deletion. Sessions older than
+	// ACCOUNT_REAUTH_WINDOW (3s in .env.test) are not enough.
+	oidcLogin := func(email string) authhandler.LoginResponse {
+		var loginResp authhandler.LoginResponse
+		if status := doRequest(s, http.MethodGet, oidcCallbackURL(s, email), "", nil, &loginResp); status != http.StatusOK {
+			s.Fatalf("OIDC callback expected 200 OK, got %d", status)
+		}
+		return loginResp
+	}
+	staleOIDCLogin := oidcLogin("oidcleaver@example.com")
+	time.Sleep(4 * time.Second)
+	if status := doRequest(s, http.MethodDelete, s.BaseURL+"/me", "Bearer "+staleOIDCLogin.Token, authhandler.DeleteAccountRequest{}, nil); status != http.StatusForbidden {
+		s.Fatalf("Account deletion from a stale OIDC session expected 403 Forbidden, got %d", status)
+	}
+
+	freshOIDCLogin := oidcLogin("oidcleaver@example.com")
+	if freshOIDCLogin.User.ID != staleOIDCLogin.User.ID {
+		s.Fatalf("Repeated OIDC login expected user %d, got %d", staleOIDCLogin.User.ID, freshOIDCLogin.User.ID)
+	}
+	if status := doRequest(s, http.MethodDelete, s.BaseURL+"/me", "Bearer "+freshOIDCLogin.Token, authhandler.DeleteAccountRequest{}, nil); status != http.StatusNoContent {
+		s.Fatalf("Account deletion after a fresh OIDC login expected 204 No Content, got %d", status)
+	}
+	if status := doRequest(s, http.MethodGet, s.BaseURL+"/me", "Bearer "+freshOIDCLogin.Token, nil, nil); status != http.StatusUnauthorized {
+		s.Fatalf("Get me after account deletion expected 401 Unauthorized, got %d", status)
+	}
+
+	// 32. Guess the Password of a Session
+	guesserLogin := registerAndLogin(s, "guesser", "password123")
+	guessThrottled := false
+	for attempt := 1; attempt <= 5 && !guessThrottled; attempt++ {
+		guessBody, _ := json.Marshal(authhandler.ChangePasswordRequest{CurrentPassword: fmt.Sprintf("guess%d", attempt), NewPassword: "password456"})
+		req, err := http.NewRequest(http.MethodPost, s.BaseURL+"/auth/password", bytes.NewReader(guessBody))
+		if err != nil {
+			s.Fatalf("Failed to create new request for password change: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+guesserLogin.Token)
+
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			s.Fatalf("Failed to change password: %v", err)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+		switch resp.StatusCode {
+		case http.StatusBadRequest:
+		case http.StatusTooManyRequests:
+			if resp.Header.Get("Retry-After") == "" {
+				s.Fatalf("Throttled password change expected a Retry-After header")
+			}
+			guessThrottled = true
+		default:
+			s.Fatalf("Password change with a wrong password expected 400 or 429, got %d", resp.StatusCode)
+		}
+	}
+	if !guessThrottled {
+		s.Fatalf("Repeated wrong current passwords expected 429 Too Many Requests")
+	}
+}
+
+// oidcCallbackURL starts an OIDC login and lets the stand-in provider sign in
+// the user with the email, returning the callback URL it redirects to.
+func oidcCallbackURL(s *suite.Suite, email string) string {
+	client := &http.Client{
+		Timeout: s.Client.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(s.BaseURL + "/auth/oidc/login")
+	if err != nil {
+		s.Fatalf("Failed to start OIDC login: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		s.Fatalf("OIDC login expected 302 Found, got %d", resp.StatusCode)
+	}
+
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		s.Fatalf("Failed to parse authorization URL: %v", err)
+	}
+	providerURL, err := url.Parse(s.OIDCURL)
+	if err != nil {
+		s.Fatalf("Failed to parse TEST_OIDC_URL: %v", err)
+	}
+	authURL.Scheme, authURL.Host = providerURL.Scheme, providerURL.Host
+	query := authURL.Query()
+	query.Set("login_hint", email)
+	authURL.RawQuery = query.Encode()
+
+	resp, err = client.Get(authURL.String())
+	if err != nil {
+		s.Fatalf("Failed to authorize with OIDC provider: %v", err)
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		s.Errorf("Failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		s.Fatalf("OIDC authorization expected 302 Found, got %d", resp.StatusCode)
+	}
+
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		s.Fatalf("Failed to parse callback URL: %v", err)
+	}
+	if callbackURL.Query().Get("code") == "" {
+		s.Fatalf("OIDC provider returned no code: %s", callbackURL.Query().Get("error"))
+	}
+
+	return callbackURL.String()
+}
+
+func findLeafCategory(categories []*models.Category) *models.Category {
+	for _, c := range categories {
+		if len(c.Children) == 0 {
+			return c
+		}
+		if leaf := findLeafCategory(c.Children); leaf != nil {
+			return leaf
+		}
+	}
+	return nil
+}
+
+func requiredAttributeValues(attributes []models.CategoryAttribute) map[string]any {
+	values := map[string]any{}
+	for _, a := range attributes {
+		if !a.Required {
+			continue
+		}
+		switch a.Type {
+		case models.AttributeTypeEnum:
+			values[a.Key] = a.Options[0]
+		case models.AttributeTypeInt:
+			if a.Min != nil {
+				values[a.Key] = *a.Min
+			} else {
+				values[a.Key] = 0
+			}
+		case models.AttributeTypeBool:
+			values[a.Key] = false
+		default:
+			values[a.Key] = "test"
+		}
+	}
+	return values
+}
+
+// doRequest sends a request with an optional JSON body and Authorization
+// header, decodes the JSON response into out unless it is nil and returns the
+// response status.
+func doRequest(s *suite.Suite, method, target, token string, body, out any) int {
+	s.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.Fatalf("Failed to encode request body: %v", err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, target, reqBody)
+	if err != nil {
+		s.Fatalf("Failed to create new request for %s %s: %v", method, target, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		s.Fatalf("Failed to send %s %s: %v", method, target, err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			s.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+
+	if out != nil && resp.StatusCode < http.StatusMultipleChoices {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			s.Fatalf("Failed to decode response of %s %s: %v", method, target, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+// registerAndLogin registers a user without an email and logs them in.
+func registerAndLogin(s *suite.Suite, login, password string) authhandler.LoginResponse {
+	s.Helper()
+
+	status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/register", "", authhandler.RegisterRequest{Login: login, Password: password}, nil)
+	if status != http.StatusCreated {
+		s.Fatalf("Registration of %s expected 201 Created, got %d", login, status)
+	}
+
+	var loginResp authhandler.LoginResponse
+	status = doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "", authhandler.LoginRequest{Login: login, Password: password}, &loginResp)
+	if status != http.StatusOK {
+		s.Fatalf("Login of %s expected 200 OK, got %d", login, status)
+	}
+
+	return loginResp
+}
+
+// waitForMail waits for a mail with the subject to the address to appear in
+// the outbox of the API and returns the newest one.
+func waitForMail(s *suite.Suite, to, subject string) string {
+	s.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		entries, err := os.ReadDir(s.OutboxDir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.Fatalf("Failed to read outbox: %v", err)
+		}
+
+		// The file names start with the time the mail was written.
+		for _, entry := range slices.Backward(entries) {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".eml") {
+				continue
+			}
+			message, err := os.ReadFile(filepath.Join(s.OutboxDir, entry.Name()))
+			if err != nil {
+				s.Fatalf("Failed to read mail %s: %v", entry.Name(), err)
+			}
+			header, _, _ := strings.Cut(string(message), "\r\n\r\n")
+			if strings.Contains(header, "\r\nTo: "+to+"\r\n") && strings.Contains(header, "\r\nSubject: "+subject+"\r\n") {
+				return string(message)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			s.Fatalf("No mail %q to %s in %s", subject, to, s.OutboxDir)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
 }
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	authhandler "github.com/ocenb/marketplace/internal/handlers/auth"
+	"github.com/ocenb/marketplace/tests/suite"
+)
+
+// TestStatelessRevocation checks that an instance validating access tokens
+// statelessly stops accepting the tokens of revoked sessions. Revocations
+// reach its cache through LISTEN/NOTIFY, so they are awaited briefly.
+func TestStatelessRevocation(t *testing.T) {
+	s := suite.NewStateless(t)
+
+	login := func(password string) authhandler.LoginResponse {
+		s.Helper()
+
+		var loginResp authhandler.LoginResponse
+		status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/login", "",
+			authhandler.LoginRequest{Login: "statelessuser", Password: password}, &loginResp)
+		if status != http.StatusOK {
+			s.Fatalf("Login expected 200 OK, got %d", status)
+		}
+		return loginResp
+	}
+
+	// 1. Log Out
+	loggedOut := registerAndLogin(s, "statelessuser", "password123")
+	waitForTokenStatus(s, "Bearer "+loggedOut.Token, http.StatusOK)
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/logout", "Bearer "+loggedOut.Token, nil, nil); status != http.StatusNoContent {
+		s.Fatalf("Logout expected 204 No Content, got %d", status)
+	}
+	waitForTokenStatus(s, "Bearer "+loggedOut.Token, http.StatusUnauthorized)
+
+	// 2. Replay a Used Refresh Token
+	refreshed := login("password123")
+	var tokens authhandler.RefreshResponse
+	refreshReq := authhandler.RefreshRequest{RefreshToken: refreshed.RefreshToken}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/refresh", "", refreshReq, &tokens); status != http.StatusOK {
+		s.Fatalf("Refresh expected 200 OK, got %d", status)
+	}
+	waitForTokenStatus(s, "Bearer "+tokens.Token, http.StatusOK)
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/refresh", "", refreshReq, nil); status != http.StatusUnauthorized {
+		s.Fatalf("Replayed refresh token expected 401 Unauthorized, got %d", status)
+	}
+	waitForTokenStatus(s, "Bearer "+refreshed.Token, http.StatusUnauthorized)
+	waitForTokenStatus(s, "Bearer "+tokens.Token, http.StatusUnauthorized)
+
+	// 3. Change the Password
+	other := login("password123")
+	current := login("password123")
+	waitForTokenStatus(s, "Bearer "+other.Token, http.StatusOK)
+	changeReq := authhandler.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "password456"}
+	if status := doRequest(s, http.MethodPost, s.BaseURL+"/auth/password", "Bearer "+current.Token, changeReq, nil); status != http.StatusNoContent {
+		s.Fatalf("Password change expected 204 No Content, got %d", status)
+	}
+	waitForTokenStatus(s, "Bearer "+other.Token, http.StatusUnauthorized)
+	waitForTokenStatus(s, "Bearer "+current.Token, http.StatusOK)
+}
+
+// waitForTokenStatus waits until an authenticated request with the token gets
+// the status.
+func waitForTokenStatus(s *suite.Suite, token string, want int) {
+	s.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := doRequest(s, http.MethodGet, s.BaseURL+"/auth/sessions", token, nil, nil)
+		if status == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			s.Fatalf("GET /auth/sessions expected %d, got %d", want, status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
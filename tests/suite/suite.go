@@ -12,19 +12,54 @@ import (
 type Suite struct {
 	*testing.T
 	BaseURL string
-	Client  *http.Client
-	ctx     context.Context
+	// OIDCURL is where the tests reach the stand-in OIDC provider, which the
+	// API may know under another host name.
+	OIDCURL string
+	// OutboxDir is where the tests read the mails written by the API.
+	OutboxDir string
+	Client    *http.Client
+	ctx       context.Context
 }
 
 func New(t *testing.T) *Suite {
 	t.Helper()
-	t.Parallel()
 
 	baseURL := os.Getenv("TEST_API_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:8000"
 	}
 
+	return newSuite(t, baseURL)
+}
+
+// NewStateless returns a suite for the API instance that validates access
+// tokens with JWT_VALIDATION_MODE=stateless. It shares the database with the
+// instance of New.
+func NewStateless(t *testing.T) *Suite {
+	t.Helper()
+
+	baseURL := os.Getenv("TEST_STATELESS_API_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8001"
+	}
+
+	return newSuite(t, baseURL)
+}
+
+func newSuite(t *testing.T, baseURL string) *Suite {
+	t.Helper()
+	t.Parallel()
+
+	oidcURL := os.Getenv("TEST_OIDC_URL")
+	if oidcURL == "" {
+		oidcURL = "http://localhost:9096"
+	}
+
+	outboxDir := os.Getenv("TEST_OUTBOX_DIR")
+	if outboxDir == "" {
+		outboxDir = "outbox"
+	}
+
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
@@ -37,10 +72,12 @@ func New(t *testing.T) *Suite {
 	})
 
 	s := &Suite{
-		T:       t,
-		BaseURL: baseURL,
-		Client:  client,
-		ctx:     ctx,
+		T:         t,
+		BaseURL:   baseURL,
+		OIDCURL:   oidcURL,
+		OutboxDir: outboxDir,
+		Client:    client,
+		ctx:       ctx,
 	}
 
 	s.waitForService()